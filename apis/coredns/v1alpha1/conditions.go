@@ -27,6 +27,8 @@ const ReasonRuntimeAvailable = "RuntimeAvailable"
 const ServerConditionType = "DNSServer"
 const ReasonServerActive = "HostedZoneActive"
 const ReasonServerValidationFailure = "ValidationFailed"
+const ReasonServerConflict = "Conflict"
+const ReasonServerWithdrawn = "Withdrawn"
 
 ////////////////////////////////////////////////////////////////////////////////
 
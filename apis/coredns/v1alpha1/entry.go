@@ -74,8 +74,31 @@ type CoreDNSSpec struct {
 	SRV *ServiceSpec `json:"SRV,omitempty"`
 	// +optional
 	CNAME string `json:"CNAME,omitempty"`
+	// ALIAS is a domain name whose address records are returned
+	// as A and AAAA records for the DNS names. In contrast to
+	// CNAME it can be used at the zone apex (DNS name "@").
+	// +optional
+	ALIAS string `json:"ALIAS,omitempty"`
 	// +optional
 	NS []string `json:"NS,omitempty"`
+	// PTR is a list of domain names used as PTR records
+	// for DNS names in a reverse zone. They take precedence
+	// over the PTR records generated for the addresses of
+	// other entries.
+	// +optional
+	PTR []string `json:"PTR,omitempty"`
+
+	// Order is the ordering of the address records of the DNS names
+	// in answers: none (spec order), sorted, rotate (per query)
+	// or shuffle. If not set, the ordering of the DNS server is used.
+	// +kubebuilder:validation:Enum=none;sorted;rotate;shuffle
+	// +optional
+	Order string `json:"order,omitempty"`
+
+	// NoReverse disables the generation of PTR records
+	// for the addresses of this entry in served reverse zones.
+	// +optional
+	NoReverse bool `json:"noReverse,omitempty"`
 }
 
 const PROTO_TCP = "TCP"
@@ -71,9 +71,59 @@ type HostedZoneSpec struct {
 	// +kubebuilder:validation:Minimum=10
 	MinimumTTL int `json:"minimumTTL"`
 
+	// NegativeTTL is the TTL for caching negative answers
+	// (MINIMUM field of the SOA record, RFC 2308).
+	// If not set, MinimumTTL is used.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10800
+	// +optional
+	NegativeTTL *int `json:"negativeTTL,omitempty"`
+
 	// ParantRef is the name if a local hosted zone resource it is linked to.
 	// +optional
 	ParentRef string `json:"parentRef,omitempty"`
+
+	// QueryACL restricts the clients allowed to query the zone.
+	// +optional
+	QueryACL *ACL `json:"queryACL,omitempty"`
+
+	// TransferACL additionally restricts the clients allowed to
+	// send ANY or zone transfer requests.
+	// +optional
+	TransferACL *ACL `json:"transferACL,omitempty"`
+
+	// RateLimit overrides the response rate limiting
+	// configured for the DNS server.
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+// ACL describes the clients allowed to access a zone.
+// Denied networks take precedence. If allowed networks are
+// given, only clients from these networks are allowed.
+type ACL struct {
+	// Allow is a list of allowed networks in CIDR notation.
+	// +optional
+	Allow []string `json:"allow,omitempty"`
+
+	// Deny is a list of denied networks in CIDR notation.
+	// +optional
+	Deny []string `json:"deny,omitempty"`
+}
+
+// RateLimit describes the response rate limiting for a zone.
+type RateLimit struct {
+	// ResponsesPerSecond is the number of responses of the same kind
+	// allowed per second for a client network. 0 disables the rate limiting.
+	// +kubebuilder:validation:Minimum=0
+	ResponsesPerSecond int `json:"responsesPerSecond"`
+
+	// Slip determines that every n-th dropped response is
+	// sent as truncated response, which forces legitimate clients
+	// to retry with TCP. 0 drops all responses.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Slip *int `json:"slip,omitempty"`
 }
 
 type Observed struct {
@@ -19,6 +19,7 @@
 package v1alpha1
 
 import (
+	"reflect"
 	"slices"
 )
 
@@ -28,8 +29,20 @@ func (h *HostedZoneSpec) Equal(other *HostedZoneSpec) bool {
 		h.Retry != other.Retry ||
 		h.Expire != other.Expire ||
 		h.MinimumTTL != other.MinimumTTL ||
-		slices.Compare(h.DomainNames, other.DomainNames) != 0 {
+		h.ParentRef != other.ParentRef ||
+		slices.Compare(h.DomainNames, other.DomainNames) != 0 ||
+		!h.QueryACL.Equal(other.QueryACL) ||
+		!h.TransferACL.Equal(other.TransferACL) ||
+		!reflect.DeepEqual(h.NegativeTTL, other.NegativeTTL) ||
+		!reflect.DeepEqual(h.RateLimit, other.RateLimit) {
 		return false
 	}
 	return true
 }
+
+func (a *ACL) Equal(other *ACL) bool {
+	if a == nil || other == nil {
+		return a == other
+	}
+	return slices.Equal(a.Allow, other.Allow) && slices.Equal(a.Deny, other.Deny)
+}
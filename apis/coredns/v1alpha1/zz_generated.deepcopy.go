@@ -27,6 +27,32 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ACL) DeepCopyInto(out *ACL) {
+	*out = *in
+	if in.Allow != nil {
+		in, out := &in.Allow, &out.Allow
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Deny != nil {
+		in, out := &in.Deny, &out.Deny
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ACL.
+func (in *ACL) DeepCopy() *ACL {
+	if in == nil {
+		return nil
+	}
+	out := new(ACL)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CoreDNSEntry) DeepCopyInto(out *CoreDNSEntry) {
 	*out = *in
@@ -121,6 +147,11 @@ func (in *CoreDNSSpec) DeepCopyInto(out *CoreDNSSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.PTR != nil {
+		in, out := &in.PTR, &out.PTR
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -241,6 +272,26 @@ func (in *HostedZoneSpec) DeepCopyInto(out *HostedZoneSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.NegativeTTL != nil {
+		in, out := &in.NegativeTTL, &out.NegativeTTL
+		*out = new(int)
+		**out = **in
+	}
+	if in.QueryACL != nil {
+		in, out := &in.QueryACL, &out.QueryACL
+		*out = new(ACL)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TransferACL != nil {
+		in, out := &in.TransferACL, &out.TransferACL
+		*out = new(ACL)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(RateLimit)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -303,6 +354,27 @@ func (in *Observed) DeepCopy() *Observed {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RateLimit) DeepCopyInto(out *RateLimit) {
+	*out = *in
+	if in.Slip != nil {
+		in, out := &in.Slip, &out.Slip
+		*out = new(int)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateLimit.
+func (in *RateLimit) DeepCopy() *RateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(RateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SRVRecord) DeepCopyInto(out *SRVRecord) {
 	*out = *in
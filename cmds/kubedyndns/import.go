@@ -0,0 +1,188 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	clientapi "github.com/mandelsoft/kubedyndns/client/clientset/versioned"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/zonefile"
+)
+
+// importZone converts a master file into HostedZone and CoreDNSEntry
+// manifests and optionally applies them to a cluster.
+func importZone(args []string) error {
+	var opts zonefile.Options
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.StringVar(&opts.Origin, "origin", "", "origin of the zone file (default: owner of the SOA record)")
+	fs.StringVar(&opts.Namespace, "namespace", "default", "namespace of the generated objects")
+	fs.StringVar(&opts.ZoneName, "zone", "", "name of the HostedZone object (default: derived from the origin)")
+	fs.StringVar(&opts.DomainName, "domain", "", "domain name of the HostedZone, relative to the parent zone for nested zones (default: origin)")
+	fs.StringVar(&opts.ParentRef, "parent", "", "parent HostedZone of the generated zone")
+	fs.BoolVar(&opts.Filter, "filter", false, "generate entries with absolute names for mode FilterByZones without HostedZone")
+	output := fs.String("o", "", "output file for the manifests (default: stdout)")
+	apply := fs.Bool("apply", false, "create or update the objects in the cluster")
+	kubeconfig := fs.String("kubeconfig", "", "kubeconfig used to apply the objects (default: standard kubeconfig)")
+	kubecontext := fs.String("context", "", "context of the kubeconfig")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s import [options] <zone file>\n\noptions:\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one zone file required")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	result, err := zonefile.Import(f, fs.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+	for _, w := range result.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+
+	if *apply {
+		return applyObjects(*kubeconfig, *kubecontext, result)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		o, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer o.Close()
+		w = o
+	}
+	return writeManifests(w, result)
+}
+
+// writeManifests writes the objects as multi document YAML.
+func writeManifests(w io.Writer, result *zonefile.Result) error {
+	var objs []interface{}
+	if result.Zone != nil {
+		objs = append(objs, result.Zone)
+	}
+	for _, e := range result.Entries {
+		objs = append(objs, e)
+	}
+	for i, o := range objs {
+		data, err := manifest(o)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := fmt.Fprintln(w, "---"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// manifest marshals an object without the empty
+// status and the server generated metadata.
+func manifest(o interface{}) ([]byte, error) {
+	var m map[string]interface{}
+	data, err := yaml.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	delete(m, "status")
+	if meta, ok := m["metadata"].(map[string]interface{}); ok {
+		delete(meta, "creationTimestamp")
+	}
+	return yaml.Marshal(m)
+}
+
+// applyObjects creates or updates the objects in the cluster.
+func applyObjects(kubeconfig, kubecontext string, result *zonefile.Result) error {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: kubecontext}).ClientConfig()
+	if err != nil {
+		return err
+	}
+	client, err := clientapi.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if z := result.Zone; z != nil {
+		zones := client.CorednsV1alpha1().HostedZones(z.Namespace)
+		old, err := zones.Get(ctx, z.Name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			_, err = zones.Create(ctx, z, metav1.CreateOptions{})
+		case err == nil:
+			z.ResourceVersion = old.ResourceVersion
+			_, err = zones.Update(ctx, z, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return fmt.Errorf("zone %s/%s: %w", z.Namespace, z.Name, err)
+		}
+		fmt.Printf("HostedZone %s/%s applied\n", z.Namespace, z.Name)
+	}
+	for _, e := range result.Entries {
+		if err := applyEntry(ctx, client, e); err != nil {
+			return fmt.Errorf("entry %s/%s: %w", e.Namespace, e.Name, err)
+		}
+		fmt.Printf("CoreDNSEntry %s/%s applied\n", e.Namespace, e.Name)
+	}
+	return nil
+}
+
+func applyEntry(ctx context.Context, client clientapi.Interface, e *api.CoreDNSEntry) error {
+	entries := client.CorednsV1alpha1().CoreDNSEntries(e.Namespace)
+	old, err := entries.Get(ctx, e.Name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		_, err = entries.Create(ctx, e, metav1.CreateOptions{})
+	case err == nil:
+		e.ResourceVersion = old.ResourceVersion
+		_, err = entries.Update(ctx, e, metav1.UpdateOptions{})
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/caddy/caddyfile"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"k8s.io/klog"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/filesource"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/validation"
+)
+
+// validate reads a Corefile and a set of manifests and reports the
+// effective domain names, the problems and the resulting zones of all
+// kubedyndns plugin instances. The objects are processed by the plugin
+// code on an in-memory store, so the results match the behaviour in a cluster.
+func validate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	corefile := fs.String("corefile", "Corefile", "Corefile with the kubedyndns configuration")
+	zones := fs.Bool("zones", true, "print the served zones in master file format")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s validate [options] <manifest file or directory>...\n\noptions:\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("manifest files or directories required")
+	}
+
+	clog.Discard()
+	klog.SetOutput(io.Discard)
+
+	objs, err := filesource.ReadObjects(fs.Args()...)
+	if err != nil {
+		return err
+	}
+	instances, err := parseCorefile(*corefile)
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+	for i, k := range instances {
+		n, err := validation.Check(os.Stdout, i+1, k, objs, *zones)
+		if err != nil {
+			return err
+		}
+		problems += n
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d problem(s) found", problems)
+	}
+	return nil
+}
+
+// parseCorefile parses the kubedyndns stanzas of all server blocks of a Corefile.
+func parseCorefile(path string) ([]*kubedyndns.KubeDynDNS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	blocks, err := caddyfile.Parse(path, f, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*kubedyndns.KubeDynDNS
+	for _, b := range blocks {
+		tokens := b.Tokens["kubedyndns"]
+		if len(tokens) == 0 {
+			continue
+		}
+		c := caddy.NewTestController("dns", "")
+		c.Dispenser = caddyfile.NewDispenserTokens(path, tokens)
+		c.ServerBlockKeys = b.Keys
+		ks, err := kubedyndns.Parse(c)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ks...)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no kubedyndns plugin configured in %s", path)
+	}
+	return result, nil
+}
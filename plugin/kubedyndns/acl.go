@@ -0,0 +1,79 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"context"
+	"net"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// allowed checks the access control lists for a request.
+// The ACLs of a zone object take precedence over the ones configured
+// for the plugin. The query ACL is checked for all requests,
+// ANY and zone transfer requests additionally require the transfer ACL.
+func (k *KubeDynDNS) allowed(zi *ZoneInfo, state request.Request) bool {
+	switch state.QType() {
+	case dns.TypeANY, dns.TypeAXFR, dns.TypeIXFR:
+		return k.accessAllowed(zi, net.ParseIP(state.IP()), true)
+	}
+	return k.accessAllowed(zi, net.ParseIP(state.IP()), false)
+}
+
+// transferAllowed checks whether a client may export a zone via HTTP.
+// Like a zone transfer it requires the query and the transfer ACL.
+// Without any transfer ACL, only loopback clients are allowed.
+func (k *KubeDynDNS) transferAllowed(zi *ZoneInfo, ip net.IP) bool {
+	if k.transferACL == nil && (zi.Object == nil || zi.Object.TransferAccess == nil) {
+		return ip != nil && ip.IsLoopback() && k.accessAllowed(zi, ip, false)
+	}
+	return k.accessAllowed(zi, ip, true)
+}
+
+func (k *KubeDynDNS) accessAllowed(zi *ZoneInfo, ip net.IP, xfr bool) bool {
+	query, transfer := k.queryACL, k.transferACL
+	if zi.Object != nil {
+		if zi.Object.QueryAccess != nil {
+			query = zi.Object.QueryAccess
+		}
+		if zi.Object.TransferAccess != nil {
+			transfer = zi.Object.TransferAccess
+		}
+	}
+
+	if !query.Allowed(ip) {
+		return false
+	}
+	if xfr {
+		return transfer.Allowed(ip)
+	}
+	return true
+}
+
+// refused answers a request of a client not allowed to access a zone.
+func (k *KubeDynDNS) refused(ctx context.Context, zi *ZoneInfo, state request.Request) (int, error) {
+	Log.Infof("refused %s request for %q from %s", state.Type(), state.QName(), state.IP())
+	m := new(dns.Msg)
+	m.SetRcode(state.Req, dns.RcodeRefused)
+	k.writeMsg(ctx, zi, state, m)
+	// Return success as the rcode to signal we have written to the client.
+	return dns.RcodeSuccess, nil
+}
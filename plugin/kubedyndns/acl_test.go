@@ -0,0 +1,52 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+	"github.com/miekg/dns"
+)
+
+func TestAllowedInvalidZoneACL(t *testing.T) {
+	k := &KubeDynDNS{}
+	tests := []struct {
+		zone    *objects.Zone
+		qtype   uint16
+		allowed bool
+	}{
+		{&objects.Zone{}, dns.TypeA, true},
+		{&objects.Zone{}, dns.TypeAXFR, true},
+		{&objects.Zone{QueryAccess: objects.DenyAll}, dns.TypeA, false},
+		{&objects.Zone{TransferAccess: objects.DenyAll}, dns.TypeA, true},
+		{&objects.Zone{TransferAccess: objects.DenyAll}, dns.TypeAXFR, false},
+		{&objects.Zone{TransferAccess: objects.DenyAll}, dns.TypeANY, false},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("www.example.org.", tc.qtype)
+		state := request.Request{W: &test.ResponseWriter{}, Req: m}
+		if got := k.allowed(NewZoneInfo("example.org.", tc.zone), state); got != tc.allowed {
+			t.Errorf("test %d: expected %t, got %t", i, tc.allowed, got)
+		}
+	}
+}
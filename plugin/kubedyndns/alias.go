@@ -0,0 +1,222 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+// aliasNegativeTTL is the caching time for failed upstream lookups.
+const aliasNegativeTTL = 30
+
+// aliasCacheSize is the maximum number of cached upstream results.
+// Reaching it triggers the cleanup of expired cache entries.
+const aliasCacheSize = 1000
+
+type aliasDepthKey struct{}
+
+// aliasEntry returns the entry declaring an ALIAS for a looked up name.
+func aliasEntry(l *NameLookup) *objects.Entry {
+	if !l.Exists() || l.Cut != nil {
+		return nil
+	}
+	for _, e := range l.Node.Entries {
+		if e.Error == nil && e.Alias != "" {
+			return e
+		}
+	}
+	return nil
+}
+
+// isAliasTarget checks whether an address request has to be answered
+// by resolving an ALIAS target.
+func (k *Backend) isAliasTarget(state request.Request) bool {
+	switch state.QType() {
+	case dns.TypeA, dns.TypeAAAA:
+		return aliasEntry(k.lookup) != nil
+	}
+	return false
+}
+
+// resolveAlias synthesizes the address records for an ALIAS entry.
+// Targets in zones served by this plugin instance are resolved from the cache,
+// others via the upstream. The records use the requested name as owner
+// and the minimum TTL of the entry and the target records.
+func (k *Backend) resolveAlias(ctx context.Context, state request.Request) ([]dns.RR, []dns.RR, error) {
+	e := aliasEntry(k.lookup)
+	target := absoluteName(e.Alias, k.zoneInfo.DomainName)
+
+	depth, _ := ctx.Value(aliasDepthKey{}).(int)
+	if depth >= maxCNAMEChain {
+		Log.Warningf("ALIAS chain for %s exceeds %d entries", state.QName(), maxCNAMEChain)
+		return nil, nil, nil
+	}
+	ctx = context.WithValue(ctx, aliasDepthKey{}, depth+1)
+
+	var (
+		records []dns.RR
+		err     error
+	)
+	zi := k.zoneFor(target)
+	if zi != nil {
+		var l *NameLookup
+		zi, l = k.findZone(zi, target)
+		if l.Cut == nil {
+			req := state.NewWithQuestion(target, state.QType())
+			req.Zone = zi.DomainName
+			be := &Backend{KubeDynDNS: k.KubeDynDNS, zoneInfo: zi, lookup: l}
+			records, _, err = be.Handle(ctx, req)
+			if err != nil && !k.IsNameError(err) {
+				return nil, nil, err
+			}
+		} else {
+			zi = nil
+		}
+	}
+	if zi == nil && k.upstream {
+		records = k.aliases.Lookup(ctx, k.KubeDynDNS, state, target)
+	}
+
+	ttl := objects.DefTTL(e.Ttl, k.ttl)
+	for _, rr := range records {
+		if rr.Header().Rrtype == state.QType() {
+			ttl = min(ttl, rr.Header().Ttl)
+		}
+	}
+	var result []dns.RR
+	for _, rr := range records {
+		if rr.Header().Rrtype == state.QType() {
+			rr = dns.Copy(rr)
+			rr.Header().Name = state.QName()
+			rr.Header().Ttl = ttl
+			result = append(result, rr)
+		}
+	}
+	return result, nil, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// aliasCache caches the upstream results for ALIAS targets
+// according to the TTL of the returned records.
+type aliasCache struct {
+	lock    sync.Mutex
+	entries map[aliasKey]*aliasResult
+}
+
+type aliasKey struct {
+	name  string
+	qtype uint16
+}
+
+type aliasResult struct {
+	records []dns.RR
+	expires time.Time
+}
+
+// remaining returns copies of the cached records with the TTL
+// reduced to the remaining caching time.
+func (r *aliasResult) remaining(now time.Time) []dns.RR {
+	ttl := uint32(r.expires.Sub(now).Seconds())
+	var result []dns.RR
+	for _, rr := range r.records {
+		rr = dns.Copy(rr)
+		rr.Header().Ttl = ttl
+		result = append(result, rr)
+	}
+	return result
+}
+
+func newAliasCache() *aliasCache {
+	return &aliasCache{entries: map[aliasKey]*aliasResult{}}
+}
+
+// Lookup returns the answer records for the given name and the request type
+// either from the cache or via the upstream of the plugin.
+func (c *aliasCache) Lookup(ctx context.Context, k *KubeDynDNS, state request.Request, name string) []dns.RR {
+	key := aliasKey{name: strings.ToLower(name), qtype: state.QType()}
+	now := time.Now()
+
+	c.lock.Lock()
+	r := c.entries[key]
+	c.lock.Unlock()
+	if r != nil && now.Before(r.expires) {
+		return r.remaining(now)
+	}
+
+	r = &aliasResult{}
+	ttl := uint32(aliasNegativeTTL)
+	m, err := k.Upstream.Lookup(ctx, state, name, state.QType())
+	if err != nil {
+		Log.Warningf("upstream lookup for ALIAS target %s failed: %s", name, err)
+	} else if m != nil {
+		if len(m.Answer) > 0 {
+			ttl = math.MaxUint32
+		}
+		for _, rr := range m.Answer {
+			ttl = min(ttl, rr.Header().Ttl)
+		}
+		r.records = m.Answer
+	}
+	r.expires = now.Add(time.Duration(ttl) * time.Second)
+
+	c.store(key, r, now)
+	return r.records
+}
+
+// store adds a result to the cache. For a full cache the expired
+// entries are removed before.
+func (c *aliasCache) store(key aliasKey, r *aliasResult, now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= aliasCacheSize {
+		c.cleanup(now)
+	}
+	c.entries[key] = r
+}
+
+// cleanup removes the expired entries. If none is expired,
+// the entry expiring first is evicted to limit the cache size.
+func (c *aliasCache) cleanup(now time.Time) {
+	var (
+		first   aliasKey
+		expires time.Time
+	)
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+			continue
+		}
+		if expires.IsZero() || e.expires.Before(expires) {
+			first, expires = k, e.expires
+		}
+	}
+	if len(c.entries) >= aliasCacheSize {
+		delete(c.entries, first)
+	}
+}
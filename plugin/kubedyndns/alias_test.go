@@ -0,0 +1,113 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+func aliasKeyFor(i int) aliasKey {
+	return aliasKey{name: fmt.Sprintf("host%d.example.com.", i), qtype: dns.TypeA}
+}
+
+// fillAliasCache fills the cache completely with entries expiring
+// in ascending order starting after the given duration.
+func fillAliasCache(c *aliasCache, now time.Time, d time.Duration) {
+	for i := 0; i < aliasCacheSize; i++ {
+		c.store(aliasKeyFor(i), &aliasResult{expires: now.Add(d + time.Duration(i)*time.Second)}, now)
+	}
+}
+
+func TestAliasCacheLimit(t *testing.T) {
+	now := time.Now()
+	c := newAliasCache()
+	fillAliasCache(c, now, time.Minute)
+
+	key := aliasKey{name: "new.example.com.", qtype: dns.TypeA}
+	c.store(key, &aliasResult{expires: now.Add(time.Minute)}, now)
+	if len(c.entries) != aliasCacheSize {
+		t.Errorf("cache size %d exceeds limit %d", len(c.entries), aliasCacheSize)
+	}
+	if c.entries[key] == nil {
+		t.Errorf("new entry not cached")
+	}
+	// the entry expiring first is evicted
+	if c.entries[aliasKeyFor(0)] != nil {
+		t.Errorf("entry expiring first not evicted")
+	}
+	if c.entries[aliasKeyFor(1)] == nil {
+		t.Errorf("entry unexpectedly evicted")
+	}
+}
+
+func TestAliasCacheCleanup(t *testing.T) {
+	now := time.Now()
+	c := newAliasCache()
+	fillAliasCache(c, now, -time.Hour)
+	valid := aliasKeyFor(aliasCacheSize - 1)
+	c.entries[valid].expires = now.Add(time.Minute)
+
+	key := aliasKey{name: "new.example.com.", qtype: dns.TypeA}
+	c.store(key, &aliasResult{expires: now.Add(time.Minute)}, now)
+	// all expired entries are removed
+	if len(c.entries) != 2 || c.entries[key] == nil || c.entries[valid] == nil {
+		t.Errorf("unexpected cache entries %v", c.entries)
+	}
+}
+
+func TestAliasCacheUpdate(t *testing.T) {
+	now := time.Now()
+	c := newAliasCache()
+	fillAliasCache(c, now, time.Minute)
+
+	// updating an entry of a full cache does not evict other entries
+	c.store(aliasKeyFor(1), &aliasResult{expires: now.Add(time.Hour)}, now)
+	if len(c.entries) != aliasCacheSize || c.entries[aliasKeyFor(0)] == nil {
+		t.Errorf("unexpected eviction for update")
+	}
+}
+
+func TestAliasCacheLookup(t *testing.T) {
+	c := newAliasCache()
+	records := []dns.RR{test.A("external.example.com. 300 IN A 198.51.100.1")}
+	c.store(aliasKey{name: "external.example.com.", qtype: dns.TypeA}, &aliasResult{records: records, expires: time.Now().Add(time.Minute)}, time.Now())
+
+	m := new(dns.Msg)
+	m.SetQuestion("alias.example.org.", dns.TypeA)
+	state := request.Request{W: &test.ResponseWriter{}, Req: m}
+	// cached results are used without upstream lookup, names are case-insensitive
+	got := c.Lookup(context.Background(), nil, state, "External.Example.com.")
+	if len(got) != 1 || got[0].(*dns.A).A.String() != "198.51.100.1" {
+		t.Fatalf("unexpected records %v", got)
+	}
+	// the TTL is reduced to the remaining caching time
+	if ttl := got[0].Header().Ttl; ttl > 60 || ttl < 59 {
+		t.Errorf("unexpected TTL %d", ttl)
+	}
+	if records[0].Header().Ttl != 300 {
+		t.Errorf("cached record modified")
+	}
+}
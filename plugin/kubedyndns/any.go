@@ -0,0 +1,132 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"context"
+	"net"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// modes for answering ANY requests.
+const (
+	// ANY_FULL answers with all RRsets of the requested name.
+	ANY_FULL = "full"
+	// ANY_MINIMAL answers with a single RRset (RFC 8482, section 4.1).
+	ANY_MINIMAL = "minimal"
+	// ANY_HINFO answers with a synthesized HINFO record (RFC 8482, section 4.2).
+	ANY_HINFO = "hinfo"
+)
+
+// any answers an ANY request according to the configured mode.
+// Trusted clients always get the full answer.
+func (k *Backend) any(ctx context.Context, state request.Request) ([]dns.RR, []dns.RR, error) {
+	mode := k.anyMode
+	if mode != ANY_FULL && k.anyTrusted != nil && k.anyTrusted.Allowed(net.ParseIP(state.IP())) {
+		mode = ANY_FULL
+	}
+
+	if mode == ANY_HINFO {
+		if !k.nameExists(state) {
+			return nil, nil, errNoItems
+		}
+		return []dns.RR{k.hinfo(state)}, nil, nil
+	}
+
+	records, extra, err := k.fullAny(ctx, state)
+	if err != nil || mode == ANY_FULL || len(records) == 0 {
+		return records, extra, err
+	}
+
+	// minimal: use the first RRset, only
+	t := records[0].Header().Rrtype
+	var result []dns.RR
+	for _, rr := range records {
+		if rr.Header().Rrtype == t {
+			result = append(result, rr)
+		}
+	}
+	if t != dns.TypeSRV && t != dns.TypeNS {
+		extra = nil
+	}
+	return result, extra, nil
+}
+
+// fullAny provides all RRsets for the requested name.
+// Server failures of a single type take precedence, name errors
+// are only reported if no type could be answered.
+func (k *Backend) fullAny(ctx context.Context, state request.Request) ([]dns.RR, []dns.RR, error) {
+	var (
+		records []dns.RR
+		extra   []dns.RR
+		failure error
+		nameErr error
+		found   bool
+	)
+
+	zone := k.zoneInfo.DomainName
+	add := func(r, e []dns.RR, err error) {
+		switch {
+		case err == nil:
+			found = true
+			records = append(records, r...)
+			extra = append(extra, e...)
+		case k.IsNameError(err):
+			nameErr = err
+		default:
+			failure = err
+		}
+	}
+
+	r, _, err := plugin.A(ctx, k, zone, *withType(&state, dns.TypeA), nil, plugin.Options{})
+	add(r, nil, err)
+	r, _, err = plugin.AAAA(ctx, k, zone, *withType(&state, dns.TypeAAAA), nil, plugin.Options{})
+	add(r, nil, err)
+	r, _, err = plugin.TXT(ctx, k, zone, *withType(&state, dns.TypeTXT), nil, plugin.Options{})
+	add(r, nil, err)
+	r, err = plugin.CNAME(ctx, k, zone, *withType(&state, dns.TypeCNAME), plugin.Options{})
+	add(r, nil, err)
+	r, e, err := plugin.SRV(ctx, k, zone, *withType(&state, dns.TypeSRV), plugin.Options{})
+	add(r, e, err)
+	r, err = plugin.PTR(ctx, k, zone, *withType(&state, dns.TypePTR), plugin.Options{})
+	add(r, nil, err)
+
+	if k.lookup.Apex {
+		r, e = k.apexNS(ctx, *withType(&state, dns.TypeNS))
+		add(r, e, nil)
+		add(k.SOA(ctx, k.zoneInfo, state), nil, nil)
+	}
+
+	if failure != nil {
+		return nil, nil, failure
+	}
+	if !found {
+		return nil, nil, nameErr
+	}
+	return records, extra, nil
+}
+
+// hinfo provides the synthesized HINFO record for ANY requests
+// according to RFC 8482.
+func (k *Backend) hinfo(state request.Request) dns.RR {
+	return &dns.HINFO{Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeHINFO, Class: dns.ClassINET, Ttl: k.TTL(0)}, Cpu: "RFC8482"}
+}
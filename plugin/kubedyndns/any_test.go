@@ -0,0 +1,138 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns_test
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+)
+
+func anyEntries() []*api.CoreDNSEntry {
+	return []*api.CoreDNSEntry{
+		newEntry("www", api.CoreDNSSpec{
+			DNSNames: []string{"www.example.org"},
+			A:        []string{"192.0.2.1", "192.0.2.2"},
+			AAAA:     []string{"2001:db8::1"},
+			TXT:      []string{"text"},
+		}),
+		newEntry("txt", api.CoreDNSSpec{
+			DNSNames: []string{"txt.example.org"},
+			TXT:      []string{"only text"},
+		}),
+	}
+}
+
+var (
+	anyA    = []dns.RR{test.A("www.example.org. 10 IN A 192.0.2.1"), test.A("www.example.org. 10 IN A 192.0.2.2")}
+	anyAAAA = test.AAAA("www.example.org. 10 IN AAAA 2001:db8::1")
+	anyTXT  = test.TXT(`www.example.org. 10 IN TXT "text"`)
+	anyFull = append(append([]dns.RR{}, anyA...), anyAAAA, anyTXT)
+
+	anySOA = test.SOA("example.org. 10 IN SOA ns.dns.example.org. hostmaster.example.org. 0 7200 1800 86400 10")
+)
+
+func hinfo(name string) dns.RR {
+	return &dns.HINFO{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeHINFO, Class: dns.ClassINET, Ttl: 10}, Cpu: "RFC8482"}
+}
+
+func TestAnyModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  string
+		cases []test.Case
+	}{
+		{
+			name: "full",
+			mode: "full",
+			cases: []test.Case{
+				{Qname: "www.example.org.", Qtype: dns.TypeANY, Answer: anyFull},
+				{Qname: "txt.example.org.", Qtype: dns.TypeANY, Answer: []dns.RR{test.TXT(`txt.example.org. 10 IN TXT "only text"`)}},
+				{Qname: "missing.example.org.", Qtype: dns.TypeANY, Rcode: dns.RcodeNameError, Ns: []dns.RR{anySOA}},
+			},
+		},
+		{
+			name: "minimal",
+			mode: "minimal",
+			cases: []test.Case{
+				{Qname: "www.example.org.", Qtype: dns.TypeANY, Answer: anyA},
+				{Qname: "txt.example.org.", Qtype: dns.TypeANY, Answer: []dns.RR{test.TXT(`txt.example.org. 10 IN TXT "only text"`)}},
+				{Qname: "missing.example.org.", Qtype: dns.TypeANY, Rcode: dns.RcodeNameError, Ns: []dns.RR{anySOA}},
+			},
+		},
+		{
+			name: "hinfo",
+			mode: "hinfo",
+			cases: []test.Case{
+				{Qname: "www.example.org.", Qtype: dns.TypeANY, Answer: []dns.RR{hinfo("www.example.org.")}},
+				{Qname: "txt.example.org.", Qtype: dns.TypeANY, Answer: []dns.RR{hinfo("txt.example.org.")}},
+				{Qname: "missing.example.org.", Qtype: dns.TypeANY, Rcode: dns.RcodeNameError, Ns: []dns.RR{anySOA}},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			env := start(t, "kubedyndns example.org {\n any "+tc.mode+"\n}", anyEntries()...)
+			check(t, env, tc.cases...)
+
+			// other types are not affected by the mode
+			check(t, env,
+				test.Case{Qname: "www.example.org.", Qtype: dns.TypeA, Answer: anyA},
+				test.Case{Qname: "www.example.org.", Qtype: dns.TypeTXT, Answer: []dns.RR{anyTXT}},
+			)
+		})
+	}
+}
+
+func TestAnyApex(t *testing.T) {
+	env := start(t, "kubedyndns example.org {\n any full\n nameservers ns1\n}", anyEntries()...)
+
+	check(t, env, test.Case{Qname: "example.org.", Qtype: dns.TypeANY, Answer: []dns.RR{
+		test.NS("example.org. 10 IN NS ns1.example.org."),
+		test.SOA("example.org. 10 IN SOA ns1.example.org. hostmaster.example.org. 0 7200 1800 86400 10"),
+	}})
+}
+
+func TestAnyTrusted(t *testing.T) {
+	env := start(t, "kubedyndns example.org {\n any hinfo 10.240.0.0/16 2001:db8::/32\n}", anyEntries()...)
+
+	tests := []struct {
+		client string
+		answer []dns.RR
+	}{
+		{"10.240.0.1", anyFull},
+		{"10.240.255.1", anyFull},
+		{"2001:db8::53", anyFull},
+		{"10.241.0.1", []dns.RR{hinfo("www.example.org.")}},
+		{"2001:db9::53", []dns.RR{hinfo("www.example.org.")}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.client, func(t *testing.T) {
+			m := new(dns.Msg)
+			m.SetQuestion("www.example.org.", dns.TypeANY)
+			resp := exchange(t, env, m, tc.client, false)
+			if err := test.SortAndCheck(resp, test.Case{Qname: "www.example.org.", Qtype: dns.TypeANY, Answer: tc.answer}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
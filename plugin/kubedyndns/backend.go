@@ -23,32 +23,27 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/etcd/msg"
-	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/request"
 	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
 	"github.com/miekg/dns"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ZoneInfo struct {
 	DomainName string
 	Object     *objects.Zone
+	// Reverse is set for the reverse zones of the plugin, whose names
+	// are taken from the absolute names of the entries.
+	Reverse bool
 }
 
 func NewZoneInfo(domain string, zo *objects.Zone) *ZoneInfo {
 	return &ZoneInfo{DomainName: domain, Object: zo}
 }
 
-func (i *ZoneInfo) Match(ref string, e metav1.Object) bool {
-	if i.Object == nil {
-		return true
-	}
-	return e.GetNamespace() == i.Object.Namespace && ref == i.Object.Name
-}
-
 type Backend struct {
 	*KubeDynDNS
 	zoneInfo *ZoneInfo
+	lookup   *NameLookup
 }
 
 var _ plugin.ServiceBackend = (*Backend)(nil)
@@ -62,32 +57,23 @@ func (k *Backend) Handle(ctx context.Context, state request.Request) ([]dns.RR,
 
 	zi := k.zoneInfo
 
+	if k.isAlias(state) {
+		return k.chase(ctx, state)
+	}
+	if k.isAliasTarget(state) {
+		return k.resolveAlias(ctx, state)
+	}
+
 	switch state.QType() {
 	case dns.TypeANY:
-		var r []dns.RR
-		records, _, err = plugin.A(ctx, k, zi.DomainName, *withType(&state, dns.TypeA), nil, plugin.Options{})
-
-		r, _, err = plugin.AAAA(ctx, k, zi.DomainName, *withType(&state, dns.TypeAAAA), nil, plugin.Options{})
-		records = append(records, r...)
-
-		r, _, err = plugin.TXT(ctx, k, zi.DomainName, *withType(&state, dns.TypeTXT), nil, plugin.Options{})
-		records = append(records, r...)
-
-		r, err = plugin.CNAME(ctx, k, zi.DomainName, *withType(&state, dns.TypeCNAME), plugin.Options{})
-		records = append(records, r...)
-
-		r, extra, err = plugin.SRV(ctx, k, zi.DomainName, *withType(&state, dns.TypeSRV), plugin.Options{})
-		records = append(records, r...)
-
-		if state.Name() == zi.DomainName {
-			r, extra, err = plugin.NS(ctx, k, zi.DomainName, *withType(&state, dns.TypeNS), plugin.Options{})
-			records = append(records, r...)
-		}
+		records, extra, err = k.any(ctx, state)
 
 	case dns.TypeA:
 		records, _, err = plugin.A(ctx, k, zi.DomainName, state, nil, plugin.Options{})
+		records = k.orderAddresses(records)
 	case dns.TypeAAAA:
 		records, _, err = plugin.AAAA(ctx, k, zi.DomainName, state, nil, plugin.Options{})
+		records = k.orderAddresses(records)
 	case dns.TypeTXT:
 		records, _, err = plugin.TXT(ctx, k, zi.DomainName, state, nil, plugin.Options{})
 	case dns.TypeCNAME:
@@ -99,24 +85,48 @@ func (k *Backend) Handle(ctx context.Context, state request.Request) ([]dns.RR,
 	case dns.TypeSRV:
 		records, extra, err = plugin.SRV(ctx, k, zi.DomainName, state, plugin.Options{})
 	case dns.TypeSOA:
-		records, err = k.SOA(ctx, zi, state), nil
-
+		if k.lookup.Apex {
+			records = k.SOA(ctx, zi, state)
+			break
+		}
+		fallthrough
 	case dns.TypeNS:
-		if state.Name() == zi.DomainName {
-			records, extra, err = plugin.NS(ctx, k, zi.DomainName, state, plugin.Options{})
+		if k.lookup.Apex {
+			records, extra = k.apexNS(ctx, state)
 			break
 		}
 		fallthrough
 	default:
-		// Do a fake A lookup, so we can distinguish between NODATA and NXDOMAIN
-		fake := state.NewWithQuestion(state.QName(), dns.TypeA)
-		fake.Zone = state.Zone
-		_, _, err = plugin.A(ctx, k, zi.DomainName, fake, nil, plugin.Options{})
+		// distinguish between NODATA and NXDOMAIN
+		if !k.nameExists(state) {
+			err = errNoItems
+		}
 	}
 
 	return records, extra, err
 }
 
+// apexNS provides the NS records and the glue records for the zone apex.
+// For zone objects they are taken from the zone status, otherwise
+// the configured name servers are used. Without configuration
+// the default name server with the local addresses is used.
+func (k *Backend) apexNS(ctx context.Context, state request.Request) ([]dns.RR, []dns.RR) {
+	zi := k.zoneInfo
+	if zi.Object != nil {
+		records := k.zoneNS(zi)
+		return records, k.glue(zi, records)
+	}
+	if k.soa.ns != "" || len(k.nameServers) > 0 {
+		var records []dns.RR
+		for _, n := range k.defaultNameServers(zi) {
+			records = append(records, k.NS(n, zi.DomainName, k.ttl)...)
+		}
+		return records, k.glue(zi, records)
+	}
+	records, extra, _ := plugin.NS(ctx, k, zi.DomainName, state, plugin.Options{})
+	return records, extra
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 // Services implements the ServiceBackend interface.
@@ -124,7 +134,7 @@ func (k *Backend) Services(ctx context.Context, state request.Request, exact boo
 
 	switch state.QType() {
 	case dns.TypeNS:
-		// We can only get here if the qname equals the zone, see ServeDNS in handler.go.
+		// We can only get here if the qname equals the zone, see apexNS.
 		nss := k.nsAddrs(false, state.Zone)
 		var svcs []msg.Service
 		for _, ns := range nss {
@@ -149,37 +159,31 @@ func (k *Backend) Records(ctx context.Context, state request.Request, exact bool
 	if e != nil {
 		return nil, e
 	}
-	if dnsutil.IsReverse(state.Name()) > 0 {
-		return nil, errNoItems
-	}
-
 	if r.IsServiceRequest() != (state.QType() == dns.TypeSRV) {
 		return nil, errNoItems
 	}
 	services, err := k.findEntries(r, state.QType())
+	if err == errNoItems && k.nameExists(state) {
+		// addresses with generated PTR records
+		return nil, nil
+	}
 	return services, err
 }
 
-// findServices returns the services matching r from the cache.
+// findEntries returns the services matching r from the cache.
+// Without service the domain of r is the requested name, which
+// has already been looked up in the name tree of the zone.
 func (k *Backend) findEntries(r *recordRequest, t uint16) (services []msg.Service, err error) {
-	var entries []*objects.Entry
-
 	zi := k.zoneInfo
-	if k.filtered {
-		entries = k.APIConn.EntryDNSIndex(r.domain + "." + zi.DomainName)
-		Log.Infof("find (filtered) %s.%s -> %d entries", r.domain, zi.DomainName, len(entries))
-	} else {
-		tmp := k.APIConn.EntryDNSIndex(r.domain + ".")
-		for _, e := range tmp {
-			if zi.Match(e.ZoneRef, e) {
-				entries = append(entries, e)
-			}
-		}
-		Log.Infof("find %s. -> %d entries -> %d in %s<%s>", r.domain, len(tmp), len(entries), k.zoneObject, zi.DomainName)
+	l := k.lookup
+	if r.service != "" {
+		l = k.APIConn.NameTree(zi).Lookup(r.domain)
 	}
-	if len(entries) == 0 {
+	if !l.Exists() {
 		return nil, errNoItems
 	}
+	entries := l.Node.Entries
+	Log.Infof("find %s -> %d entries in %s<%s> (wildcard: %t)", r.domain, len(entries), k.zoneObject, zi.DomainName, l.Wildcard)
 
 	if r.service != "" && r.service != "any" && r.service != "all" {
 		for _, e := range entries {
@@ -0,0 +1,646 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+// Package clustersource aggregates the CoreDNSEntry and HostedZone
+// objects of several clusters. The objects are mirrored into an
+// in-memory store used by the controller of the plugin, conflicts
+// between the clusters are resolved according to a conflict policy.
+// The status determined for the mirrored objects is written back to
+// the originating clusters.
+package clustersource
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	clientapi "github.com/mandelsoft/kubedyndns/client/clientset/versioned"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/filesource"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/utils"
+)
+
+var Log clog.P = clog.NewWithPlugin("kubedyndns")
+
+// Conflict policies for entries of different clusters
+// providing the same domain name.
+const (
+	// POLICY_PRECEDENCE serves the entries of the cluster
+	// configured first.
+	POLICY_PRECEDENCE = "precedence"
+	// POLICY_MERGE serves the entries of all clusters.
+	POLICY_MERGE = "merge"
+	// POLICY_REJECT serves none of the conflicting entries.
+	POLICY_REJECT = "reject"
+)
+
+// ValidPolicy checks for a valid conflict policy.
+func ValidPolicy(p string) bool {
+	switch p {
+	case POLICY_PRECEDENCE, POLICY_MERGE, POLICY_REJECT:
+		return true
+	}
+	return false
+}
+
+const (
+	kindEntry = "CoreDNSEntry"
+	kindZone  = "HostedZone"
+)
+
+// Cluster describes an aggregated cluster. If no client
+// is given, it is created for the config.
+type Cluster struct {
+	Name   string
+	Config *rest.Config
+	Client clientapi.Interface
+}
+
+type cluster struct {
+	name   string
+	client clientapi.Interface
+
+	entries       cache.Store
+	entryInformer cache.Controller
+	zones         cache.Store
+	zoneInformer  cache.Controller
+}
+
+// objectKey identifies an object in an aggregated cluster.
+type objectKey struct {
+	cluster   string
+	kind      string
+	namespace string
+	name      string
+}
+
+func (k objectKey) String() string {
+	return fmt.Sprintf("%s %s/%s in cluster %s", k.kind, k.namespace, k.name, k.cluster)
+}
+
+// Source provides the aggregated objects of several clusters.
+// The order of the clusters defines their precedence.
+//
+// Changes of the objects of the clusters are aggregated incrementally
+// per object: only the objects sharing a zone or a domain name with
+// a changed object are evaluated again.
+type Source struct {
+	policy   string
+	clusters []*cluster
+	store    *filesource.Store
+	synced   atomic.Bool
+	changes  workqueue.TypedInterface[objectKey]
+	queue    workqueue.TypedRateLimitingInterface[objectKey]
+
+	// claims maps the domain names to the entries providing
+	// them, names keeps the domain names provided by an entry.
+	// Both are only used by the aggregation in Run.
+	claims map[string]sets.Set[objectKey]
+	names  map[objectKey][]string
+
+	lock     sync.Mutex
+	rejected map[objectKey]string
+
+	runner utils.SharedRunner
+}
+
+// New creates a source for the given clusters. The default
+// conflict policy is POLICY_PRECEDENCE.
+func New(clusters []Cluster, policy string) (*Source, error) {
+	if policy == "" {
+		policy = POLICY_PRECEDENCE
+	}
+	if !ValidPolicy(policy) {
+		return nil, fmt.Errorf("invalid conflict policy %q", policy)
+	}
+	s := &Source{
+		policy:   policy,
+		changes:  workqueue.NewTyped[objectKey](),
+		queue:    workqueue.NewTypedRateLimitingQueue[objectKey](workqueue.DefaultTypedControllerRateLimiter[objectKey]()),
+		claims:   map[string]sets.Set[objectKey]{},
+		names:    map[objectKey][]string{},
+		rejected: map[objectKey]string{},
+	}
+	s.store = filesource.NewStore(s.statusUpdated)
+
+	for _, cfg := range clusters {
+		var err error
+		client := cfg.Client
+		if client == nil {
+			client, err = clientapi.NewForConfig(cfg.Config)
+			if err != nil {
+				return nil, fmt.Errorf("cluster %s: %w", cfg.Name, err)
+			}
+		}
+		c := &cluster{name: cfg.Name, client: client}
+		c.entries, c.entryInformer = cache.NewInformerWithOptions(cache.InformerOptions{
+			ListerWatcher: &cache.ListWatch{
+				ListWithContextFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+					return client.CorednsV1alpha1().CoreDNSEntries("").List(ctx, opts)
+				},
+				WatchFuncWithContext: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+					return client.CorednsV1alpha1().CoreDNSEntries("").Watch(ctx, opts)
+				},
+			},
+			ObjectType: &api.CoreDNSEntry{},
+			Handler:    s.handler(c.name, kindEntry),
+		})
+		c.zones, c.zoneInformer = cache.NewInformerWithOptions(cache.InformerOptions{
+			ListerWatcher: &cache.ListWatch{
+				ListWithContextFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+					return client.CorednsV1alpha1().HostedZones("").List(ctx, opts)
+				},
+				WatchFuncWithContext: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+					return client.CorednsV1alpha1().HostedZones("").Watch(ctx, opts)
+				},
+			},
+			ObjectType: &api.HostedZone{},
+			Handler:    s.handler(c.name, kindZone),
+		})
+		s.clusters = append(s.clusters, c)
+	}
+	return s, nil
+}
+
+// Client returns the client providing the aggregated objects.
+func (s *Source) Client() objects.Client {
+	return s.store
+}
+
+// KubeClient returns nil, because namespaces of
+// aggregated clusters are not supported.
+func (s *Source) KubeClient() kubernetes.Interface {
+	return nil
+}
+
+// HasSynced reports whether the objects of all clusters have been aggregated.
+func (s *Source) HasSynced() bool {
+	return s.synced.Load()
+}
+
+// Run watches the clusters until the stop channel is closed.
+// If several controllers share the source, the clusters are
+// watched until the last of them has stopped.
+func (s *Source) Run(stop <-chan struct{}) {
+	s.runner.Run(stop, s.run)
+}
+
+func (s *Source) run(stop <-chan struct{}) {
+	var synced []cache.InformerSynced
+	for _, c := range s.clusters {
+		go c.entryInformer.Run(stop)
+		go c.zoneInformer.Run(stop)
+		synced = append(synced, c.entryInformer.HasSynced, c.zoneInformer.HasSynced)
+	}
+	go s.statusWorker()
+	defer s.queue.ShutDown()
+	go func() {
+		<-stop
+		s.changes.ShutDown()
+	}()
+
+	if !cache.WaitForCacheSync(stop, synced...) {
+		return
+	}
+	// the initial objects are queued by the informers
+	for s.changes.Len() > 0 {
+		if !s.processChange() {
+			return
+		}
+	}
+	s.synced.Store(true)
+	for s.processChange() {
+	}
+}
+
+// handler provides the event handler for the objects of a kind
+// of a cluster. Updates of the status are ignored, they do not
+// influence the aggregation and are caused by the status propagation.
+func (s *Source) handler(cluster, kind string) cache.ResourceEventHandler {
+	changed := func(obj interface{}) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			return
+		}
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			return
+		}
+		k := objectKey{cluster, kind, namespace, name}
+		if kind == kindZone {
+			// zones are aggregated across all clusters
+			k.cluster = ""
+		}
+		s.changes.Add(k)
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: changed,
+		UpdateFunc: func(old, new interface{}) {
+			if !statusOnly(old.(runtime.Object), new.(runtime.Object)) {
+				changed(new)
+			}
+		},
+		DeleteFunc: changed,
+	}
+}
+
+// statusOnly checks whether only the status of an object has been changed.
+func statusOnly(old, new runtime.Object) bool {
+	om, err := meta.Accessor(old)
+	if err != nil {
+		return false
+	}
+	nm, err := meta.Accessor(new)
+	if err != nil {
+		return false
+	}
+	if !equality.Semantic.DeepEqual(om.GetLabels(), nm.GetLabels()) || !equality.Semantic.DeepEqual(om.GetAnnotations(), nm.GetAnnotations()) {
+		return false
+	}
+	switch o := old.(type) {
+	case *api.CoreDNSEntry:
+		return equality.Semantic.DeepEqual(o.Spec, new.(*api.CoreDNSEntry).Spec)
+	case *api.HostedZone:
+		return equality.Semantic.DeepEqual(o.Spec, new.(*api.HostedZone).Spec)
+	}
+	return false
+}
+
+func (s *Source) processChange() bool {
+	k, shutdown := s.changes.Get()
+	if shutdown {
+		return false
+	}
+	defer s.changes.Done(k)
+	var err error
+	switch k.kind {
+	case kindZone:
+		err = s.aggregateZone(k.namespace, k.name)
+	case kindEntry:
+		err = s.aggregateEntry(k)
+	}
+	if err != nil {
+		Log.Errorf("aggregation of %s failed: %s", k, err)
+	}
+	return true
+}
+
+// nameKey identifies a domain name provided by an entry.
+func nameKey(e *api.CoreDNSEntry, name string) string {
+	return e.Namespace + "/" + e.Spec.ZoneRef + "/" + strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// aggregateZone aggregates a zone. Identical zones may be provided
+// by several clusters, the zone of the first cluster is served.
+func (s *Source) aggregateZone(namespace, name string) error {
+	var owner *api.HostedZone
+	for _, c := range s.clusters {
+		k := objectKey{c.name, kindZone, namespace, name}
+		o, ok, err := c.zones.GetByKey(namespace + "/" + name)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !ok:
+			s.setRejected(k, "")
+		case owner == nil:
+			owner = mirror(c.name, o.(*api.HostedZone), name).(*api.HostedZone)
+			s.setRejected(k, "")
+		case !equality.Semantic.DeepEqual(owner.Spec, o.(*api.HostedZone).Spec):
+			s.setRejected(k, fmt.Sprintf("zone already provided by cluster %s", owner.Annotations[objects.ANNOTATION_CLUSTER]))
+		default:
+			s.setRejected(k, "")
+		}
+	}
+	if owner == nil {
+		s.store.Delete(&api.HostedZone{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}})
+		return nil
+	}
+	_, err := s.store.Put(owner)
+	return err
+}
+
+// aggregateEntry aggregates an entry of a cluster and the entries
+// of other clusters sharing a domain name with its old or new names.
+func (s *Source) aggregateEntry(k objectKey) error {
+	c := s.cluster(k.cluster)
+	if c == nil {
+		return nil
+	}
+	affected := sets.New(k)
+	for _, n := range s.names[k] {
+		s.claims[n].Delete(k)
+		if s.claims[n].Len() == 0 {
+			delete(s.claims, n)
+		}
+		affected = affected.Union(s.claims[n])
+	}
+	delete(s.names, k)
+
+	o, ok, err := c.entries.GetByKey(k.namespace + "/" + k.name)
+	if err != nil {
+		return err
+	}
+	if ok {
+		e := o.(*api.CoreDNSEntry)
+		names := sets.New[string]()
+		for _, n := range e.Spec.DNSNames {
+			names.Insert(nameKey(e, n))
+		}
+		for n := range names {
+			if s.claims[n] == nil {
+				s.claims[n] = sets.New[objectKey]()
+			}
+			s.claims[n].Insert(k)
+			affected = affected.Union(s.claims[n])
+		}
+		s.names[k] = sets.List(names)
+	}
+
+	for a := range affected {
+		if err := s.evaluateEntry(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluateEntry serves an entry of a cluster, if it is
+// not rejected because of entries of other clusters.
+func (s *Source) evaluateEntry(k objectKey) error {
+	i := slices.IndexFunc(s.clusters, func(c *cluster) bool { return c.name == k.cluster })
+	mirrored := &api.CoreDNSEntry{ObjectMeta: metav1.ObjectMeta{Namespace: k.namespace, Name: k.cluster + "." + k.name}}
+
+	o, ok, err := s.clusters[i].entries.GetByKey(k.namespace + "/" + k.name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.setRejected(k, "")
+		s.store.Delete(mirrored)
+		return nil
+	}
+
+	var others []int
+	for _, n := range s.names[k] {
+		for o := range s.claims[n] {
+			j := slices.IndexFunc(s.clusters, func(c *cluster) bool { return c.name == o.cluster })
+			if j != i && !slices.Contains(others, j) {
+				others = append(others, j)
+			}
+		}
+	}
+	msg := s.conflict(i, others)
+	s.setRejected(k, msg)
+	if msg != "" {
+		s.store.Delete(mirrored)
+		return nil
+	}
+	_, err = s.store.Put(mirror(k.cluster, o.(*api.CoreDNSEntry), mirrored.Name))
+	return err
+}
+
+// setRejected records the reason for not serving an object of a
+// cluster. Changes are propagated to the status of the object.
+func (s *Source) setRejected(k objectKey, msg string) {
+	s.lock.Lock()
+	old := s.rejected[k]
+	if msg == "" {
+		delete(s.rejected, k)
+	} else {
+		s.rejected[k] = msg
+	}
+	s.lock.Unlock()
+	if old != msg {
+		if msg != "" {
+			Log.Infof("%s: %s", k, msg)
+		}
+		s.queue.Add(k)
+	}
+}
+
+// conflict checks whether an entry of a cluster must not be served
+// because of entries of other clusters providing the same names.
+func (s *Source) conflict(i int, others []int) string {
+	if len(others) == 0 {
+		return ""
+	}
+	slices.Sort(others)
+	switch s.policy {
+	case POLICY_PRECEDENCE:
+		if others[0] < i {
+			return fmt.Sprintf("overridden by cluster %s", s.clusters[others[0]].name)
+		}
+	case POLICY_REJECT:
+		var names []string
+		for _, j := range others {
+			names = append(names, s.clusters[j].name)
+		}
+		return fmt.Sprintf("conflicts with cluster %s", strings.Join(names, ", "))
+	}
+	return ""
+}
+
+// mirror provides the object to store for an object of a cluster.
+func mirror(cluster string, o runtime.Object, name string) runtime.Object {
+	obj := o.DeepCopyObject()
+	m, _ := meta.Accessor(obj)
+	annotations := map[string]string{}
+	for k, v := range m.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[objects.ANNOTATION_CLUSTER] = cluster
+	annotations[objects.ANNOTATION_NAME] = m.GetName()
+	m.SetAnnotations(annotations)
+	m.SetName(name)
+	m.SetResourceVersion("")
+	m.SetUID("")
+	m.SetManagedFields(nil)
+	m.SetOwnerReferences(nil)
+	return obj
+}
+
+// origin provides the origin of a mirrored object.
+func origin(o runtime.Object) (objectKey, bool) {
+	m, err := meta.Accessor(o)
+	if err != nil {
+		return objectKey{}, false
+	}
+	a := m.GetAnnotations()
+	if a[objects.ANNOTATION_CLUSTER] == "" || a[objects.ANNOTATION_NAME] == "" {
+		return objectKey{}, false
+	}
+	k := objectKey{cluster: a[objects.ANNOTATION_CLUSTER], namespace: m.GetNamespace(), name: a[objects.ANNOTATION_NAME]}
+	switch o.(type) {
+	case *api.CoreDNSEntry:
+		k.kind = kindEntry
+	case *api.HostedZone:
+		k.kind = kindZone
+	}
+	return k, true
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// status propagation
+
+// statusUpdated is called by the store for status updates
+// of mirrored objects.
+func (s *Source) statusUpdated(o runtime.Object) {
+	k, ok := origin(o)
+	if !ok {
+		return
+	}
+	if k.kind != kindZone {
+		s.queue.Add(k)
+		return
+	}
+	// the zone may be provided by several clusters
+	for _, c := range s.clusters {
+		k.cluster = c.name
+		s.queue.Add(k)
+	}
+}
+
+func (s *Source) statusWorker() {
+	for {
+		k, shutdown := s.queue.Get()
+		if shutdown {
+			return
+		}
+		func() {
+			defer s.queue.Done(k)
+			if err := s.updateStatus(k); err != nil {
+				Log.Errorf("cannot update status of %s: %s", k, err)
+				s.queue.AddRateLimited(k)
+			} else {
+				s.queue.Forget(k)
+			}
+		}()
+	}
+}
+
+func (s *Source) cluster(name string) *cluster {
+	for _, c := range s.clusters {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// updateStatus writes the status of a mirrored object or
+// the reason for not serving it to the object in its cluster.
+func (s *Source) updateStatus(k objectKey) error {
+	ctx := context.Background()
+	c := s.cluster(k.cluster)
+	if c == nil {
+		return nil
+	}
+	s.lock.Lock()
+	msg, rejected := s.rejected[k]
+	s.lock.Unlock()
+
+	switch k.kind {
+	case kindEntry:
+		o, ok, err := c.entries.GetByKey(k.namespace + "/" + k.name)
+		if err != nil || !ok {
+			return err
+		}
+		e := o.(*api.CoreDNSEntry).DeepCopy()
+		if rejected {
+			e.Status.RootZone = ""
+			e.Status.EffectiveDomainNames = nil
+			setConflict(&e.Status.State, &e.Status.Message, &e.Status.Conditions, e.Generation, msg)
+		} else {
+			m, ok := s.store.Entry(k.namespace, k.cluster+"."+k.name)
+			if !ok {
+				return nil
+			}
+			e.Status = m.Status
+		}
+		if equality.Semantic.DeepEqual(e.Status, o.(*api.CoreDNSEntry).Status) {
+			return nil
+		}
+		_, err = c.client.CorednsV1alpha1().CoreDNSEntries(k.namespace).UpdateStatus(ctx, e, metav1.UpdateOptions{})
+		return ignoreNotFound(err)
+	case kindZone:
+		o, ok, err := c.zones.GetByKey(k.namespace + "/" + k.name)
+		if err != nil || !ok {
+			return err
+		}
+		z := o.(*api.HostedZone).DeepCopy()
+		if rejected {
+			setConflict(&z.Status.State, &z.Status.Message, &z.Status.Conditions, z.Generation, msg)
+		} else {
+			m, ok := s.store.Zone(k.namespace, k.name)
+			if !ok {
+				return nil
+			}
+			if !equality.Semantic.DeepEqual(m.Spec, z.Spec) {
+				return nil
+			}
+			z.Status = m.Status
+		}
+		if equality.Semantic.DeepEqual(z.Status, o.(*api.HostedZone).Status) {
+			return nil
+		}
+		_, err = c.client.CorednsV1alpha1().HostedZones(k.namespace).UpdateStatus(ctx, z, metav1.UpdateOptions{})
+		return ignoreNotFound(err)
+	}
+	return nil
+}
+
+// setConflict sets the status of an object not served because of a conflict.
+func setConflict(state, message *string, conditions *[]metav1.Condition, generation int64, msg string) {
+	if objects.IsPlain(*conditions) {
+		*state = "Invalid"
+		*message = msg
+		return
+	}
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               api.ServerConditionType,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             api.ReasonServerConflict,
+		Message:            msg,
+	})
+}
+
+func ignoreNotFound(err error) error {
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
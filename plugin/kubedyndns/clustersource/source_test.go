@@ -0,0 +1,201 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package clustersource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/client/clientset/versioned/fake"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+func entry(name string, names ...string) *api.CoreDNSEntry {
+	return &api.CoreDNSEntry{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       api.CoreDNSSpec{ZoneRef: "test", DNSNames: names, A: []string{"10.0.0.1"}},
+	}
+}
+
+func zone(name, email string) *api.HostedZone {
+	return &api.HostedZone{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       api.HostedZoneSpec{DomainNames: []string{"test.example.org"}, EMail: email},
+	}
+}
+
+type testClusters struct {
+	source  *Source
+	clients map[string]*fake.Clientset
+	stop    chan struct{}
+}
+
+func newTestClusters(t *testing.T, policy string, objs map[string][]runtime.Object) *testClusters {
+	t.Helper()
+	tc := &testClusters{clients: map[string]*fake.Clientset{}, stop: make(chan struct{})}
+	var clusters []Cluster
+	for _, name := range []string{"a", "b"} {
+		tc.clients[name] = fake.NewSimpleClientset(objs[name]...)
+		clusters = append(clusters, Cluster{Name: name, Client: tc.clients[name]})
+	}
+	s, err := New(clusters, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.source = s
+	go s.Run(tc.stop)
+	t.Cleanup(func() { close(tc.stop) })
+	tc.eventually(t, "synced", s.HasSynced)
+	return tc
+}
+
+func (tc *testClusters) eventually(t *testing.T, msg string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %s", msg)
+}
+
+func (tc *testClusters) served(names ...string) func() bool {
+	return func() bool {
+		list, _ := tc.source.store.ListEntries(context.Background(), "", metav1.ListOptions{})
+		if len(list.Items) != len(names) {
+			return false
+		}
+		for _, n := range names {
+			if _, ok := tc.source.store.Entry("default", n); !ok {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func (tc *testClusters) state(cluster, name string) func() bool {
+	return func() bool {
+		e, err := tc.clients[cluster].CorednsV1alpha1().CoreDNSEntries("default").Get(context.Background(), name, metav1.GetOptions{})
+		return err == nil && e.Status.State != ""
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	tc := newTestClusters(t, POLICY_PRECEDENCE, map[string][]runtime.Object{
+		"a": {entry("e1", "www"), entry("e2", "other")},
+		"b": {entry("e1", "www"), entry("e3", "mail")},
+	})
+	tc.eventually(t, "aggregation", tc.served("a.e1", "a.e2", "b.e3"))
+
+	tc.eventually(t, "conflict status", tc.state("b", "e1"))
+	e, _ := tc.clients["b"].CorednsV1alpha1().CoreDNSEntries("default").Get(context.Background(), "e1", metav1.GetOptions{})
+	if e.Status.Message != "overridden by cluster a" {
+		t.Errorf("unexpected status %#v", e.Status)
+	}
+
+	// the entries sharing a name with a deleted entry are evaluated again
+	if err := tc.clients["a"].CorednsV1alpha1().CoreDNSEntries("default").Delete(context.Background(), "e1", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	tc.eventually(t, "takeover", tc.served("b.e1", "a.e2", "b.e3"))
+
+	// a renamed entry releases its old names
+	if _, err := tc.clients["a"].CorednsV1alpha1().CoreDNSEntries("default").Update(context.Background(), entry("e2", "mail"), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	tc.eventually(t, "rename", tc.served("b.e1", "a.e2"))
+}
+
+func TestReject(t *testing.T) {
+	tc := newTestClusters(t, POLICY_REJECT, map[string][]runtime.Object{
+		"a": {entry("e1", "www"), entry("e2", "other")},
+		"b": {entry("e1", "www.")},
+	})
+	tc.eventually(t, "aggregation", tc.served("a.e2"))
+	tc.eventually(t, "conflict status", tc.state("a", "e1"))
+}
+
+func TestMerge(t *testing.T) {
+	tc := newTestClusters(t, POLICY_MERGE, map[string][]runtime.Object{
+		"a": {entry("e1", "www")},
+		"b": {entry("e1", "www")},
+	})
+	tc.eventually(t, "aggregation", tc.served("a.e1", "b.e1"))
+	e, _ := tc.source.store.Entry("default", "b.e1")
+	if e.Annotations[objects.ANNOTATION_CLUSTER] != "b" || e.Annotations[objects.ANNOTATION_NAME] != "e1" {
+		t.Errorf("unexpected annotations %v", e.Annotations)
+	}
+}
+
+func TestZones(t *testing.T) {
+	tc := newTestClusters(t, POLICY_PRECEDENCE, map[string][]runtime.Object{
+		"a": {zone("z1", "admin@example.org"), zone("z2", "admin@example.org")},
+		"b": {zone("z1", "admin@example.org"), zone("z2", "other@example.org")},
+	})
+	tc.eventually(t, "aggregation", func() bool {
+		_, ok1 := tc.source.store.Zone("default", "z1")
+		_, ok2 := tc.source.store.Zone("default", "z2")
+		return ok1 && ok2
+	})
+	tc.source.lock.Lock()
+	defer tc.source.lock.Unlock()
+	if msg := tc.source.rejected[objectKey{"b", kindZone, "default", "z2"}]; msg != "zone already provided by cluster a" {
+		t.Errorf("unexpected rejection %q", msg)
+	}
+	if msg, ok := tc.source.rejected[objectKey{"b", kindZone, "default", "z1"}]; ok {
+		t.Errorf("identical zone rejected: %q", msg)
+	}
+}
+
+func TestStatusOnlyUpdatesIgnored(t *testing.T) {
+	s, err := New(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.handler("a", kindEntry)
+
+	old := entry("e1", "www")
+	n := old.DeepCopy()
+	n.Status.State = "Ok"
+	h.OnUpdate(old, n)
+	if s.changes.Len() != 0 {
+		t.Errorf("status update must be ignored")
+	}
+
+	n = old.DeepCopy()
+	n.Spec.DNSNames = []string{"mail"}
+	h.OnUpdate(old, n)
+	if s.changes.Len() != 1 {
+		t.Errorf("spec update must be aggregated")
+	}
+
+	n = old.DeepCopy()
+	n.Labels = map[string]string{"a": "b"}
+	if statusOnly(old, n) {
+		t.Errorf("label update must be aggregated")
+	}
+}
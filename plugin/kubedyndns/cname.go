@@ -0,0 +1,160 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+// maxCNAMEChain is the maximal number of CNAME records followed
+// for a single request.
+const maxCNAMEChain = 8
+
+// cnameEntry returns the entry declaring a CNAME for a looked up name.
+func cnameEntry(l *NameLookup) *objects.Entry {
+	if !l.Exists() || l.Cut != nil {
+		return nil
+	}
+	for _, e := range l.Node.Entries {
+		if e.Error == nil && e.CNAME != "" {
+			return e
+		}
+	}
+	return nil
+}
+
+// isAlias checks whether a request has to be answered by following
+// a CNAME record. At the zone apex a CNAME is not allowed, therefore,
+// it is used to flatten address requests, only.
+func (k *Backend) isAlias(state request.Request) bool {
+	switch state.QType() {
+	case dns.TypeCNAME, dns.TypeANY:
+		return false
+	case dns.TypeA, dns.TypeAAAA:
+	default:
+		if k.lookup.Apex {
+			return false
+		}
+	}
+	return cnameEntry(k.lookup) != nil
+}
+
+// chase follows the CNAME chain for the requested name.
+// Targets in zones served by this plugin instance are resolved from the cache.
+// Other targets are resolved via the upstream, if enabled.
+// The chain is limited to maxCNAMEChain records and stops on loops.
+// At the zone apex the chain is flattened to the final address records.
+// If the chain ends at a non-existing name in a served zone, the CNAME
+// records are returned with errNoItems to answer with NXDOMAIN (RFC 6604).
+func (k *Backend) chase(ctx context.Context, state request.Request) ([]dns.RR, []dns.RR, error) {
+	var (
+		records []dns.RR
+		extra   []dns.RR
+		err     error
+	)
+
+	zi, l := k.zoneInfo, k.lookup
+	name := state.QName()
+	visited := map[string]bool{strings.ToLower(name): true}
+	ttl := uint32(math.MaxUint32)
+	for {
+		e := cnameEntry(l)
+		if e == nil {
+			break
+		}
+		if len(records) >= maxCNAMEChain {
+			Log.Warningf("CNAME chain for %s exceeds %d records", state.QName(), maxCNAMEChain)
+			return k.flatten(records, ttl), nil, nil
+		}
+		target := absoluteName(e.CNAME, zi.DomainName)
+		cttl := objects.DefTTL(e.Ttl, k.ttl)
+		ttl = min(ttl, cttl)
+		records = append(records, &dns.CNAME{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: cttl}, Target: target})
+		if visited[strings.ToLower(target)] {
+			Log.Warningf("CNAME loop for %s detected at %s", state.QName(), target)
+			return k.flatten(records, ttl), nil, nil
+		}
+		visited[strings.ToLower(target)] = true
+		name = target
+
+		zi = k.zoneFor(target)
+		if zi != nil {
+			zi, l = k.findZone(zi, target)
+		}
+		if zi == nil || l.Cut != nil {
+			// not served by this instance
+			if k.upstream {
+				m, err := k.Upstream.Lookup(ctx, state, target, state.QType())
+				if err == nil && m != nil {
+					for _, rr := range m.Answer {
+						ttl = min(ttl, rr.Header().Ttl)
+					}
+					records = append(records, m.Answer...)
+				}
+			}
+			return k.flatten(records, ttl), nil, nil
+		}
+	}
+
+	if !l.Exists() && !k.lookup.Apex {
+		return records, nil, errNoItems
+	}
+	if l.Exists() {
+		target := state.NewWithQuestion(name, state.QType())
+		target.Zone = zi.DomainName
+		be := &Backend{KubeDynDNS: k.KubeDynDNS, zoneInfo: zi, lookup: l}
+		var r []dns.RR
+		r, extra, err = be.Handle(ctx, target)
+		if err != nil && !k.IsNameError(err) {
+			return nil, nil, err
+		}
+		for _, rr := range r {
+			ttl = min(ttl, rr.Header().Ttl)
+		}
+		records = append(records, r...)
+	}
+	return k.flatten(records, ttl), extra, nil
+}
+
+// flatten replaces a CNAME chain for the zone apex by the final records
+// using the requested name as owner and the minimum TTL of the chain.
+func (k *Backend) flatten(records []dns.RR, ttl uint32) []dns.RR {
+	if !k.lookup.Apex || len(records) == 0 {
+		return records
+	}
+	owner := records[0].Header().Name
+	var result []dns.RR
+	for _, rr := range records {
+		if rr.Header().Rrtype == dns.TypeCNAME {
+			continue
+		}
+		rr = dns.Copy(rr)
+		rr.Header().Name = owner
+		rr.Header().Ttl = ttl
+		result = append(result, rr)
+	}
+	return result
+}
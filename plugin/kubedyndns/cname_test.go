@@ -0,0 +1,293 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/testenv"
+)
+
+func cnameEntry(name, target string) *api.CoreDNSEntry {
+	return newEntry(name, api.CoreDNSSpec{DNSNames: []string{name + ".example.org"}, CNAME: target})
+}
+
+func cname(name, target string) dns.RR {
+	return test.CNAME(fmt.Sprintf("%s 10 IN CNAME %s", name, target))
+}
+
+var (
+	wwwEntry = newEntry("www", api.CoreDNSSpec{DNSNames: []string{"www.example.org"}, A: []string{"192.0.2.1"}, TXT: []string{"text"}})
+	wwwA     = test.A("www.example.org. 10 IN A 192.0.2.1")
+	cnameSOA = test.SOA("example.org. 10 IN SOA ns.dns.example.org. hostmaster.example.org. 0 7200 1800 86400 10")
+)
+
+// checkChain compares an answer with a test case keeping
+// the order of the answer section given by the CNAME chain.
+func checkChain(t *testing.T, resp *dns.Msg, tc test.Case) {
+	t.Helper()
+	if err := test.Header(tc, resp); err != nil {
+		t.Errorf("%s %s: %s", tc.Qname, dns.TypeToString[tc.Qtype], err)
+		return
+	}
+	var answer, expected []string
+	for _, rr := range resp.Answer {
+		answer = append(answer, rr.String())
+	}
+	for _, rr := range tc.Answer {
+		expected = append(expected, rr.String())
+	}
+	if !slices.Equal(answer, expected) {
+		t.Errorf("%s %s: answer\n%s\nexpected\n%s", tc.Qname, dns.TypeToString[tc.Qtype], strings.Join(answer, "\n"), strings.Join(expected, "\n"))
+	}
+	if err := test.Section(tc, test.Ns, resp.Ns); err != nil {
+		t.Errorf("%s %s: %s", tc.Qname, dns.TypeToString[tc.Qtype], err)
+	}
+}
+
+// chase runs test cases for CNAME chains.
+func chase(t *testing.T, env *testenv.Environment, cases ...test.Case) {
+	t.Helper()
+	for _, tc := range cases {
+		resp, err := env.Exchange(tc.Msg(), &test.ResponseWriter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkChain(t, resp, tc)
+	}
+}
+
+func TestCNAMEChase(t *testing.T) {
+	env := start(t, `kubedyndns example.org example.com`,
+		wwwEntry,
+		cnameEntry("a", "b.example.org."),
+		cnameEntry("b", "www"),
+		cnameEntry("missing", "nothing.example.org."),
+		cnameEntry("dangling", "missing"),
+		newEntry("other", api.CoreDNSSpec{DNSNames: []string{"other.example.com"}, CNAME: "www.example.org."}),
+	)
+
+	chase(t, env,
+		test.Case{Qname: "a.example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+			cname("a.example.org.", "b.example.org."),
+			cname("b.example.org.", "www.example.org."),
+			wwwA,
+		}},
+		test.Case{Qname: "a.example.org.", Qtype: dns.TypeTXT, Answer: []dns.RR{
+			cname("a.example.org.", "b.example.org."),
+			cname("b.example.org.", "www.example.org."),
+			test.TXT(`www.example.org. 10 IN TXT "text"`),
+		}},
+		// the CNAME itself is not followed
+		test.Case{Qname: "a.example.org.", Qtype: dns.TypeCNAME, Answer: []dns.RR{
+			cname("a.example.org.", "b.example.org."),
+		}},
+		// the chain ends with a NODATA or NXDOMAIN answer of the target
+		test.Case{Qname: "a.example.org.", Qtype: dns.TypeMX, Answer: []dns.RR{
+			cname("a.example.org.", "b.example.org."),
+			cname("b.example.org.", "www.example.org."),
+		}},
+		// the rcode is taken from the last name (RFC 6604)
+		test.Case{Qname: "missing.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Answer: []dns.RR{
+			cname("missing.example.org.", "nothing.example.org."),
+		}, Ns: []dns.RR{cnameSOA}},
+		test.Case{Qname: "dangling.example.org.", Qtype: dns.TypeTXT, Rcode: dns.RcodeNameError, Answer: []dns.RR{
+			cname("dangling.example.org.", "missing.example.org."),
+			cname("missing.example.org.", "nothing.example.org."),
+		}, Ns: []dns.RR{cnameSOA}},
+		// targets in other served zones are followed
+		test.Case{Qname: "other.example.com.", Qtype: dns.TypeA, Answer: []dns.RR{
+			cname("other.example.com.", "www.example.org."),
+			wwwA,
+		}},
+	)
+}
+
+func TestCNAMEChainLimit(t *testing.T) {
+	chain := func(n int) []*api.CoreDNSEntry {
+		entries := []*api.CoreDNSEntry{wwwEntry}
+		for i := 0; i < n; i++ {
+			target := fmt.Sprintf("c%d.example.org.", i+1)
+			if i == n-1 {
+				target = "www.example.org."
+			}
+			entries = append(entries, cnameEntry(fmt.Sprintf("c%d", i), target))
+		}
+		return entries
+	}
+	records := func(n int) []dns.RR {
+		var result []dns.RR
+		for i := 0; i < n; i++ {
+			target := fmt.Sprintf("c%d.example.org.", i+1)
+			if i == 8 {
+				break
+			}
+			if i == n-1 {
+				target = "www.example.org."
+			}
+			result = append(result, cname(fmt.Sprintf("c%d.example.org.", i), target))
+		}
+		return result
+	}
+
+	// a chain of 8 CNAME records is followed completely
+	env := start(t, `kubedyndns example.org`, chain(8)...)
+	chase(t, env, test.Case{Qname: "c0.example.org.", Qtype: dns.TypeA, Answer: append(records(8), wwwA)})
+
+	// longer chains are cut after 8 records
+	env = start(t, `kubedyndns example.org`, chain(9)...)
+	chase(t, env, test.Case{Qname: "c0.example.org.", Qtype: dns.TypeA, Answer: records(9)})
+}
+
+func TestCNAMELoop(t *testing.T) {
+	env := start(t, `kubedyndns example.org`,
+		cnameEntry("a", "b.example.org."),
+		cnameEntry("b", "c.example.org."),
+		cnameEntry("c", "A.example.org."),
+		cnameEntry("self", "self.example.org."),
+	)
+
+	chase(t, env,
+		test.Case{Qname: "a.example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+			cname("a.example.org.", "b.example.org."),
+			cname("b.example.org.", "c.example.org."),
+			cname("c.example.org.", "A.example.org."),
+		}},
+		test.Case{Qname: "b.example.org.", Qtype: dns.TypeAAAA, Answer: []dns.RR{
+			cname("b.example.org.", "c.example.org."),
+			cname("c.example.org.", "A.example.org."),
+			// the owner is the target of the previous record
+			cname("A.example.org.", "b.example.org."),
+		}},
+		test.Case{Qname: "self.example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+			cname("self.example.org.", "self.example.org."),
+		}},
+	)
+}
+
+// upstreamContext provides a context with a CoreDNS server answering
+// the A requests for external.example.com used for upstream lookups.
+func upstreamContext(t *testing.T) context.Context {
+	t.Helper()
+	cfg := &dnsserver.Config{Zone: ".", Plugin: []plugin.Plugin{
+		func(next plugin.Handler) plugin.Handler {
+			return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+				m := new(dns.Msg)
+				m.SetReply(r)
+				if r.Question[0].Name == "external.example.com." && r.Question[0].Qtype == dns.TypeA {
+					m.Answer = []dns.RR{test.A("external.example.com. 5 IN A 198.51.100.1")}
+				} else {
+					m.Rcode = dns.RcodeNameError
+				}
+				w.WriteMsg(m)
+				return dns.RcodeSuccess, nil
+			})
+		},
+	}}
+	server, err := dnsserver.NewServer("dns://:53", []*dnsserver.Config{cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return context.WithValue(context.Background(), dnsserver.Key{}, server)
+}
+
+func serve(t *testing.T, ctx context.Context, env *testenv.Environment, tc test.Case) {
+	t.Helper()
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := env.Plugin.ServeDNS(ctx, rec, tc.Msg()); err != nil {
+		t.Fatal(err)
+	}
+	checkChain(t, rec.Msg, tc)
+}
+
+func TestCNAMEUpstream(t *testing.T) {
+	external := []*api.CoreDNSEntry{
+		cnameEntry("ext", "external.example.com."),
+		newEntry("apex", api.CoreDNSSpec{DNSNames: []string{"example.org"}, CNAME: "external.example.com."}),
+	}
+	ctx := upstreamContext(t)
+
+	// without upstream external targets are not resolved
+	env := start(t, `kubedyndns example.org`, external...)
+	serve(t, ctx, env, test.Case{Qname: "ext.example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+		cname("ext.example.org.", "external.example.com."),
+	}})
+
+	env = start(t, "kubedyndns example.org {\n upstream\n}", external...)
+	serve(t, ctx, env, test.Case{Qname: "ext.example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+		cname("ext.example.org.", "external.example.com."),
+		test.A("external.example.com. 5 IN A 198.51.100.1"),
+	}})
+	serve(t, ctx, env, test.Case{Qname: "ext.example.org.", Qtype: dns.TypeAAAA, Answer: []dns.RR{
+		cname("ext.example.org.", "external.example.com."),
+	}})
+	// flattened apex with the minimum TTL of the chain
+	serve(t, ctx, env, test.Case{Qname: "example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+		test.A("example.org. 5 IN A 198.51.100.1"),
+	}})
+
+	// without server, the upstream lookup fails
+	serve(t, context.Background(), env, test.Case{Qname: "ext.example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+		cname("ext.example.org.", "external.example.com."),
+	}})
+}
+
+func TestAliasUpstream(t *testing.T) {
+	alias := newEntry("apex", api.CoreDNSSpec{DNSNames: []string{"example.org"}, ALIAS: "external.example.com."})
+	ctx := upstreamContext(t)
+
+	// without upstream external targets are not resolved
+	env := start(t, `kubedyndns example.org`, alias)
+	serve(t, ctx, env, test.Case{Qname: "example.org.", Qtype: dns.TypeA, Ns: []dns.RR{cnameSOA}})
+
+	env = start(t, "kubedyndns example.org {\n upstream\n}", alias)
+	serve(t, ctx, env, test.Case{Qname: "example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+		test.A("example.org. 5 IN A 198.51.100.1"),
+	}})
+}
+
+func TestCNAMEApexFlatten(t *testing.T) {
+	env := start(t, `kubedyndns example.org`,
+		wwwEntry,
+		cnameEntry("a", "www.example.org."),
+		newEntry("apex", api.CoreDNSSpec{DNSNames: []string{"example.org"}, CNAME: "a.example.org."}),
+	)
+
+	chase(t, env,
+		// address requests are answered with the final records
+		test.Case{Qname: "example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+			test.A("example.org. 10 IN A 192.0.2.1"),
+		}},
+		test.Case{Qname: "example.org.", Qtype: dns.TypeAAAA, Ns: []dns.RR{cnameSOA}},
+		// other types are answered from the apex
+		test.Case{Qname: "example.org.", Qtype: dns.TypeTXT, Ns: []dns.RR{cnameSOA}},
+	)
+	check(t, env, test.Case{Qname: "example.org.", Qtype: dns.TypeSOA, Answer: []dns.RR{cnameSOA}})
+}
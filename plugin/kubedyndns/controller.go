@@ -22,6 +22,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"runtime/debug"
 	"slices"
 	"sync"
@@ -30,7 +31,6 @@ import (
 
 	"github.com/coredns/coredns/plugin/kubernetes/object"
 	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/utils"
-	"github.com/miekg/dns"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/util/workqueue"
 
@@ -62,6 +62,12 @@ type Controller interface {
 	EntryList() []*objects.Entry
 	EntryDNSIndex(string) []*objects.Entry
 	EntryIPIndex(idx string) []*objects.Entry
+	// EntryIPs returns the addresses and CNAME targets of all entries.
+	EntryIPs() []string
+	EffectiveDomainNames(e *objects.Entry) []string
+
+	// NameTree returns the name tree for the given served zone.
+	NameTree(zi *ZoneInfo) *NameTree
 
 	GetZone(name cache.ObjectName) *objects.Zone
 	ZoneDomainIndex(idx string) []*objects.Zone
@@ -73,8 +79,51 @@ type Controller interface {
 
 	// Modified returns the timestamp of the most recent changes
 	Modified() int64
+	// Generation returns a counter incremented for every processed
+	// event of a watched object and every finished reconciliation.
+	Generation() uint64
+	// Pending returns the number of queued or running reconciliations.
+	Pending() int
+}
+
+// DataSource provides the objects handled by a controller.
+type DataSource interface {
+	// Client returns the client used to list and watch the
+	// objects and to update their status.
+	Client() objects.Client
+	// KubeClient returns the client used to access namespaces.
+	// It is nil for sources without cluster, which do not
+	// support the selection of namespaces.
+	KubeClient() kubernetes.Interface
+	// Run runs the data source until the stop channel is closed.
+	Run(stop <-chan struct{})
+	// HasSynced reports whether the initial objects are available.
+	HasSynced() bool
+}
+
+// clusterSource provides the objects of a Kubernetes cluster.
+type clusterSource struct {
+	kubeClient kubernetes.Interface
+	client     objects.Client
+}
+
+// NewClusterSource provides a data source for the given clients.
+func NewClusterSource(kubeClient kubernetes.Interface, client clientapi.Interface) DataSource {
+	return &clusterSource{kubeClient: kubeClient, client: objects.ForClientset(client)}
+}
+
+func (s *clusterSource) Client() objects.Client {
+	return s.client
+}
+
+func (s *clusterSource) KubeClient() kubernetes.Interface {
+	return s.kubeClient
 }
 
+func (s *clusterSource) Run(stop <-chan struct{}) {}
+
+func (s *clusterSource) HasSynced() bool { return true }
+
 type controller struct {
 	ctx     context.Context
 	queue   workqueue.TypedRateLimitingInterface[RequestKey]
@@ -85,18 +134,29 @@ type controller struct {
 	// aligned ( we use sync.LoadAtomic with this )
 	modified int64
 
-	kubeclient kubernetes.Interface
-	client     clientapi.Interface
+	// generation counts the processed events and reconciliations,
+	// active the running reconciliations.
+	generation atomic.Uint64
+	active     atomic.Int64
 
-	selector labels.Selector
+	source     DataSource
+	kubeclient kubernetes.Interface
+	client     objects.Client
 
 	entryController cache.Controller
 	zoneController  cache.Controller
+	nsController    cache.Controller
 
 	entryLister cache.Indexer
 	zoneLister  cache.Indexer
 	nsLister    cache.Store
 
+	// listWatches are the list watches of the served objects,
+	// which must be listed again if the namespace selection changes.
+	listWatches []utils.ListWatch
+
+	names *nameTrees
+
 	// stopLock is used to enforce only a single call to Stop is active.
 	// Needed because we allow stopping through an http endpoint and
 	// allowing concurrent stoppers leads to stack traces.
@@ -114,23 +174,37 @@ type controlOpts struct {
 	filtered   bool
 	namespaces sets.Set[string]
 
+	// entrySelector and zoneSelector restrict the served objects,
+	// namespaceSelector the namespaces of the served objects.
+	entrySelector     labels.Selector
+	zoneSelector      labels.Selector
+	namespaceSelector labels.Selector
+
 	zoneRef *cache.ObjectName
 }
 
-type ListFuncFactory = func(c clientapi.Interface, ns string, s labels.Selector) func(context.Context, meta.ListOptions) (runtime.Object, error)
-type WatchFuncFactory = func(c clientapi.Interface, ns string, s labels.Selector) func(context.Context, meta.ListOptions) (watch.Interface, error)
+type ListFuncFactory = func(c objects.Client, ns string, s labels.Selector) func(context.Context, meta.ListOptions) (runtime.Object, error)
+type WatchFuncFactory = func(c objects.Client, ns string, s labels.Selector) func(context.Context, meta.ListOptions) (watch.Interface, error)
 
 func filterListWatch(
-	c clientapi.Interface,
+	c objects.Client,
 	l ListFuncFactory,
 	w WatchFuncFactory,
 	s labels.Selector,
+	selected func(namespace string) bool,
 	namespaces ...string) utils.ListWatch {
 
 	var f func(obj runtime.Object) bool
-	if len(namespaces) > 1 {
+	if len(namespaces) > 1 || s != nil || selected != nil {
 		f = func(obj runtime.Object) bool {
-			return slices.Contains(namespaces, obj.(meta.Object).GetNamespace())
+			o := obj.(meta.Object)
+			if len(namespaces) > 1 && !slices.Contains(namespaces, o.GetNamespace()) {
+				return false
+			}
+			if s != nil && !s.Matches(labels.Set(o.GetLabels())) {
+				return false
+			}
+			return selected == nil || selected(o.GetNamespace())
 		}
 	}
 	ns := corev1.NamespaceAll
@@ -171,20 +245,42 @@ func (k RequestKey) String() string {
 }
 
 // newController creates a controller for CoreDNS.
-func newController(ctx context.Context, kubeClient kubernetes.Interface, client clientapi.Interface, opts controlOpts) *controller {
+func newController(ctx context.Context, source DataSource, opts controlOpts) *controller {
 	cntr := controller{
 		ctx: ctx,
 		queue: workqueue.NewTypedRateLimitingQueue[RequestKey](
 			workqueue.DefaultTypedControllerRateLimiter[RequestKey](),
 		),
-		kubeclient:  kubeClient,
-		client:      client,
+		source:      source,
+		kubeclient:  source.KubeClient(),
+		client:      source.Client(),
 		stopCh:      make(chan struct{}),
+		names:       newNameTrees(opts.zoneRef != nil),
 		controlOpts: &opts,
 	}
 
+	var selected func(string) bool
+	if opts.namespaceSelector != nil {
+		Log.Infof("selecting namespaces by %s", opts.namespaceSelector.String())
+		cntr.nsLister, cntr.nsController = cache.NewInformerWithOptions(cache.InformerOptions{
+			ListerWatcher: &cache.ListWatch{
+				ListWithContextFunc:  namespaceListFunc(cntr.kubeclient, opts.namespaceSelector),
+				WatchFuncWithContext: namespaceWatchFunc(cntr.kubeclient, opts.namespaceSelector),
+			},
+			ObjectType: &corev1.Namespace{},
+			Handler: cache.ResourceEventHandlerFuncs{
+				AddFunc:    func(obj interface{}) { cntr.namespaceChanged(nil, obj) },
+				UpdateFunc: cntr.namespaceChanged,
+				DeleteFunc: func(obj interface{}) { cntr.namespaceChanged(obj, nil) },
+			},
+		})
+		selected = cntr.selectedNamespace
+	}
+
+	lw := filterListWatch(cntr.client, entryListFunc, entryWatchFunc, opts.entrySelector, selected, opts.namespaces.UnsortedList()...)
+	cntr.listWatches = append(cntr.listWatches, lw)
 	cntr.entryLister, cntr.entryController = object.NewIndexerInformer(
-		filterListWatch(cntr.client, entryListFunc, entryWatchFunc, cntr.selector, opts.namespaces.UnsortedList()...),
+		lw,
 		&api.CoreDNSEntry{},
 		cache.ResourceEventHandlerFuncs{AddFunc: cntr.Add, UpdateFunc: cntr.Update, DeleteFunc: cntr.Delete},
 		cache.Indexers{EntryDomainIndex: entryDNSIndexFunc, EntryIPIndex: entryIPIndexFunc, EntryZoneIndex: entryZoneIndexFunc},
@@ -193,8 +289,10 @@ func newController(ctx context.Context, kubeClient kubernetes.Interface, client
 
 	if cntr.zoneRef != nil {
 		Log.Infof("handling zone %s", cntr.zoneRef.String())
+		lw := filterListWatch(cntr.client, zoneListFunc, zoneWatchFunc, opts.zoneSelector, selected, opts.namespaces.UnsortedList()...)
+		cntr.listWatches = append(cntr.listWatches, lw)
 		cntr.zoneLister, cntr.zoneController = object.NewIndexerInformer(
-			filterListWatch(cntr.client, zoneListFunc, zoneWatchFunc, cntr.selector, opts.namespaces.UnsortedList()...),
+			lw,
 			&api.HostedZone{},
 			cache.ResourceEventHandlerFuncs{AddFunc: cntr.Add, UpdateFunc: cntr.Update, DeleteFunc: cntr.Delete},
 			cache.Indexers{ZoneDomainIndex: zoneIndexFunc, ZoneParentIndex: zoneParentIndexFunc},
@@ -232,28 +330,31 @@ func entryIPIndexFunc(obj interface{}) ([]string, error) {
 	if !ok {
 		return nil, errObj
 	}
-	hosts := append(e.A, e.AAAA...)
+	var hosts []string
+	for _, a := range append(slices.Clone(e.A), e.AAAA...) {
+		hosts = append(hosts, net.ParseIP(a).String())
+	}
 	if e.CNAME != "" {
 		hosts = append(hosts, e.CNAME)
 	}
 	return hosts, nil
 }
 
-func entryListFunc(c clientapi.Interface, ns string, s labels.Selector) func(context.Context, meta.ListOptions) (runtime.Object, error) {
+func entryListFunc(c objects.Client, ns string, s labels.Selector) func(context.Context, meta.ListOptions) (runtime.Object, error) {
 	return func(ctx context.Context, opts meta.ListOptions) (runtime.Object, error) {
 		if s != nil {
 			opts.LabelSelector = s.String()
 		}
-		return c.CorednsV1alpha1().CoreDNSEntries(ns).List(ctx, opts)
+		return c.ListEntries(ctx, ns, opts)
 	}
 }
 
-func entryWatchFunc(c clientapi.Interface, ns string, s labels.Selector) func(context.Context, meta.ListOptions) (watch.Interface, error) {
+func entryWatchFunc(c objects.Client, ns string, s labels.Selector) func(context.Context, meta.ListOptions) (watch.Interface, error) {
 	return func(ctx context.Context, options meta.ListOptions) (watch.Interface, error) {
 		if s != nil {
 			options.LabelSelector = s.String()
 		}
-		return c.CorednsV1alpha1().CoreDNSEntries(ns).Watch(ctx, options)
+		return c.WatchEntries(ctx, ns, options)
 	}
 }
 
@@ -276,21 +377,21 @@ func zoneParentIndexFunc(obj interface{}) ([]string, error) {
 	return []string{e.Namespace + "/" + e.ParentRef}, nil
 }
 
-func zoneListFunc(c clientapi.Interface, ns string, s labels.Selector) func(context.Context, meta.ListOptions) (runtime.Object, error) {
+func zoneListFunc(c objects.Client, ns string, s labels.Selector) func(context.Context, meta.ListOptions) (runtime.Object, error) {
 	return func(ctx context.Context, opts meta.ListOptions) (runtime.Object, error) {
 		if s != nil {
 			opts.LabelSelector = s.String()
 		}
-		return c.CorednsV1alpha1().HostedZones(ns).List(ctx, opts)
+		return c.ListZones(ctx, ns, opts)
 	}
 }
 
-func zoneWatchFunc(c clientapi.Interface, ns string, s labels.Selector) func(context.Context, meta.ListOptions) (watch.Interface, error) {
+func zoneWatchFunc(c objects.Client, ns string, s labels.Selector) func(context.Context, meta.ListOptions) (watch.Interface, error) {
 	return func(ctx context.Context, options meta.ListOptions) (watch.Interface, error) {
 		if s != nil {
 			options.LabelSelector = s.String()
 		}
-		return c.CorednsV1alpha1().HostedZones(ns).Watch(ctx, options)
+		return c.WatchZones(ctx, ns, options)
 	}
 }
 
@@ -336,6 +437,13 @@ func (cntr *controller) Run() {
 	for i := 0; i < WORKER_NO; i++ {
 		go cntr.workerFunc(i)
 	}
+	go cntr.source.Run(cntr.stopCh)
+	go cntr.names.Run(cntr.stopCh)
+	if cntr.nsController != nil {
+		// the namespace selection is required to filter the served objects
+		go cntr.nsController.Run(cntr.stopCh)
+		cache.WaitForCacheSync(cntr.stopCh, cntr.nsController.HasSynced)
+	}
 	go cntr.entryController.Run(cntr.stopCh)
 	if cntr.zoneRef != nil {
 		go cntr.zoneController.Run(cntr.stopCh)
@@ -346,7 +454,8 @@ func (cntr *controller) Run() {
 
 // HasSynced calls on all controllers.
 func (cntr *controller) HasSynced() bool {
-	a := cntr.entryController.HasSynced() && (cntr.zoneRef == nil || cntr.zoneController.HasSynced())
+	a := cntr.source.HasSynced() && cntr.entryController.HasSynced() && (cntr.zoneRef == nil || cntr.zoneController.HasSynced()) && cntr.names.Synced() &&
+		(cntr.nsController == nil || cntr.nsController.HasSynced())
 	return a
 }
 
@@ -368,21 +477,49 @@ func (cntr *controller) EntryZoneIndex(n cache.ObjectName) (entries []*objects.E
 }
 
 func (cntr *controller) EntryDNSIndex(idx string) (entries []*objects.Entry) {
-	os, err := cntr.entryLister.ByIndex(EntryDomainIndex, idx)
-	if err == nil && len(os) == 0 {
-		fields := dns.Split(idx)
-		if len(fields) > 1 {
-			idx = "*." + idx[fields[1]:]
-			os, err = cntr.entryLister.ByIndex(EntryDomainIndex, idx)
-		}
-	}
-	return utils.ConvertSlice[*objects.Entry](os, err)
+	return utils.ConvertSlice[*objects.Entry](cntr.entryLister.ByIndex(EntryDomainIndex, idx))
+}
+
+func (cntr *controller) EntryIPs() []string {
+	return cntr.entryLister.ListIndexFuncValues(EntryIPIndex)
 }
 
 func (cntr *controller) EntryIPIndex(idx string) (entries []*objects.Entry) {
 	return utils.ConvertSlice[*objects.Entry](cntr.entryLister.ByIndex(EntryIPIndex, idx))
 }
 
+// EffectiveDomainNames returns the domain names of an entry served by the
+// controller. In Primary mode they are aggregated along the zone objects up to the
+// root zone, otherwise the names of the entry are returned.
+// The names are relative to the zone of the plugin.
+func (cntr *controller) EffectiveDomainNames(e *objects.Entry) []string {
+	if e.Error != nil {
+		return nil
+	}
+	names := slices.Clone(e.DNSNames)
+	if cntr.zoneRef == nil {
+		if e.ZoneRef != "" {
+			return nil
+		}
+		return names
+	}
+	if e.ZoneRef == "" || e.Namespace != cntr.zoneRef.Namespace {
+		return nil
+	}
+	if !cntr.transitive && e.ZoneRef != cntr.zoneRef.Name {
+		return nil
+	}
+	z := cntr.GetZone(cache.NewObjectName(e.Namespace, e.ZoneRef))
+	if z == nil || z.Error != nil {
+		return nil
+	}
+	ok, _, err := cntr.responsibleForZoneObject(z, &names)
+	if !ok || err != nil {
+		return nil
+	}
+	return names
+}
+
 func (cntr *controller) GetZone(name cache.ObjectName) *objects.Zone {
 	e, _, _ := cntr.zoneLister.GetByKey(name.String())
 	if e != nil {
@@ -400,33 +537,106 @@ func (cntr *controller) ZoneParentIndex(n cache.ObjectName) (entries []*objects.
 
 }
 
+// NameTree returns the actual snapshot of the name tree for a served zone.
+func (cntr *controller) NameTree(zi *ZoneInfo) *NameTree {
+	switch {
+	case zi.Reverse:
+		return cntr.names.Get(reverseTree).Sub(zi.DomainName)
+	case zi.Object != nil:
+		return cntr.names.Get(cache.MetaObjectToName(zi.Object).String())
+	case cntr.filtered:
+		return cntr.names.Get("").Sub(zi.DomainName)
+	default:
+		return cntr.names.Get("")
+	}
+}
+
 // GetNamespaceByName returns the namespace by name. If nothing is found an error is returned.
+// Without namespace selection the namespaces are not watched and read from the cluster.
 func (cntr *controller) GetNamespaceByName(name string) (*corev1.Namespace, error) {
-	os := cntr.nsLister.List()
-	for _, o := range os {
-		ns, ok := o.(*corev1.Namespace)
-		if !ok {
-			continue
+	if cntr.nsLister == nil {
+		if cntr.kubeclient == nil {
+			return nil, fmt.Errorf("namespaces not supported by data source")
+		}
+		return cntr.kubeclient.CoreV1().Namespaces().Get(cntr.ctx, name, meta.GetOptions{})
+	}
+	o, ok, err := cntr.nsLister.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("namespace not found")
+	}
+	return o.(*corev1.Namespace), nil
+}
+
+// selectedNamespace reports whether the objects of a namespace are served
+// according to the namespace selector.
+func (cntr *controller) selectedNamespace(name string) bool {
+	ns, err := cntr.GetNamespaceByName(name)
+	if err != nil {
+		return false
+	}
+	return cntr.namespaceSelector.Matches(labels.Set(ns.Labels))
+}
+
+// namespaceChanged handles changes of the selection of a namespace.
+// The objects of newly selected namespaces are served immediately,
+// the entries of deselected namespaces are withdrawn by the workers.
+func (cntr *controller) namespaceChanged(oldObj, newObj interface{}) {
+	defer cntr.generation.Add(1)
+	matches := func(obj interface{}) (string, bool) {
+		if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = d.Obj
 		}
-		if name == ns.ObjectMeta.Name {
-			return ns, nil
+		ns, ok := obj.(*corev1.Namespace)
+		if !ok {
+			return "", false
 		}
+		return ns.Name, cntr.namespaceSelector.Matches(labels.Set(ns.Labels))
+	}
+	oldName, oldMatch := matches(oldObj)
+	newName, newMatch := matches(newObj)
+	if oldMatch == newMatch {
+		return
+	}
+	if newMatch {
+		Log.Infof("namespace %s selected", newName)
+		cntr.resync()
+	} else {
+		Log.Infof("namespace %s deselected", oldName)
+		cntr.queue.Add(NewRequestKey(objects.TYPE_NAMESPACE, "", oldName))
+	}
+}
+
+// resync lists the served objects again to apply
+// the current namespace selection.
+func (cntr *controller) resync() {
+	for _, lw := range cntr.listWatches {
+		utils.Resync(lw)
 	}
-	return nil, fmt.Errorf("namespace not found")
 }
 
 func (cntr *controller) Add(obj interface{}) {
+	defer cntr.generation.Add(1)
 	cntr.updateModifed()
+	cntr.names.Notify(nil, obj.(objects.Object))
 	cntr.queue.Add(NewRequestKeyForObject(obj.(objects.Object)))
 }
 func (cntr *controller) Delete(obj interface{}) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+	defer cntr.generation.Add(1)
 	cntr.updateModifed()
+	cntr.names.Notify(obj.(objects.Object), nil)
 	cntr.queue.Add(NewRequestKeyForObject(obj.(objects.Object)))
 }
 func (cntr *controller) Update(oldObj, newObj interface{}) { cntr.detectChanges(oldObj, newObj) }
 
 // detectChanges detects changes in objects, and updates the modified timestamp
 func (cntr *controller) detectChanges(oldObj, newObj interface{}) {
+	defer cntr.generation.Add(1)
 	// If both objects have the same resource version, they are identical.
 	if newObj != nil && oldObj != nil && (oldObj.(meta.Object).GetResourceVersion() == newObj.(meta.Object).GetResourceVersion()) {
 		return
@@ -439,11 +649,13 @@ func (cntr *controller) detectChanges(oldObj, newObj interface{}) {
 	case *objects.Entry:
 		if !(oldObj.(*objects.Entry).Equal(newObj.(*objects.Entry))) {
 			cntr.updateModifed()
+			cntr.names.Notify(oldObj.(objects.Object), ob)
 			cntr.queue.Add(NewRequestKeyForObject(ob))
 		}
 	case *objects.Zone:
 		if !(oldObj.(*objects.Zone).Equal(newObj.(*objects.Zone))) {
 			cntr.updateModifed()
+			cntr.names.Notify(oldObj.(objects.Object), ob)
 			cntr.queue.Add(NewRequestKeyForObject(ob))
 		}
 	default:
@@ -463,7 +675,10 @@ func (cntr *controller) workerFunc(no int) {
 		Log.Infof("reconcile %s on worker %d", req, no)
 
 		var err error
+		cntr.active.Add(1)
 		func() {
+			defer cntr.active.Add(-1)
+			defer cntr.generation.Add(1)
 			defer cntr.queue.Done(req)
 			defer func() {
 				if r := recover(); r != nil {
@@ -475,6 +690,8 @@ func (cntr *controller) workerFunc(no int) {
 				err = cntr.reconcileZone(cache.NewObjectName(req.Namespace, req.Name), no)
 			case objects.TYPE_ENTRY:
 				err = cntr.reconcileEntry(cache.NewObjectName(req.Namespace, req.Name), no)
+			case objects.TYPE_NAMESPACE:
+				err = cntr.reconcileNamespace(req.Name, no)
 			}
 			if err != nil {
 				Log.Errorf("reconcile %s on worker %d failed: %s", req, no, err.Error())
@@ -530,6 +747,14 @@ func (cntr *controller) Modified() int64 {
 	return unix
 }
 
+func (cntr *controller) Generation() uint64 {
+	return cntr.generation.Load()
+}
+
+func (cntr *controller) Pending() int {
+	return cntr.queue.Len() + int(cntr.active.Load())
+}
+
 // updateModified set dns.modified to the current time.
 func (cntr *controller) updateModifed() {
 	unix := time.Now().Unix()
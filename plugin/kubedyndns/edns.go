@@ -0,0 +1,147 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// ednsUDPSize is the UDP buffer size announced in responses.
+const ednsUDPSize = 1232
+
+// cookie sizes and lifetime according to RFC 7873 and RFC 9018.
+const (
+	clientCookieLen    = 8
+	serverCookieLen    = 16
+	maxServerCookieLen = 32
+	serverCookieVer    = 1
+	cookieLifetime     = time.Hour
+	cookieClockSkew    = 5 * time.Minute
+)
+
+// requestCookie returns the client and server cookie of a request.
+// An error is returned for malformed cookies.
+func requestCookie(state request.Request) (cc, sc []byte, err error) {
+	o := state.Req.IsEdns0()
+	if o == nil {
+		return nil, nil, nil
+	}
+	for _, opt := range o.Option {
+		c, ok := opt.(*dns.EDNS0_COOKIE)
+		if !ok {
+			continue
+		}
+		data, err := hex.DecodeString(c.Cookie)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(data) != clientCookieLen && (len(data) < clientCookieLen+8 || len(data) > clientCookieLen+maxServerCookieLen) {
+			return nil, nil, fmt.Errorf("invalid cookie length %d", len(data))
+		}
+		return data[:clientCookieLen], data[clientCookieLen:], nil
+	}
+	return nil, nil, nil
+}
+
+// serverCookie generates a server cookie according to RFC 9018 for
+// the given client cookie, client address and timestamp.
+// Instead of SipHash, a truncated HMAC-SHA256 is used, the cookie
+// can therefore only be verified by replicas of this plugin sharing
+// the same secret.
+func (k *KubeDynDNS) serverCookie(cc []byte, ip net.IP, ts uint32) []byte {
+	sc := make([]byte, 8, serverCookieLen)
+	sc[0] = serverCookieVer
+	binary.BigEndian.PutUint32(sc[4:], ts)
+
+	mac := hmac.New(sha256.New, k.cookieSecret)
+	mac.Write(cc)
+	mac.Write(sc)
+	mac.Write(ip)
+	return mac.Sum(sc)[:serverCookieLen]
+}
+
+// validCookie checks whether the server cookie of a request
+// has been issued by this server for the client.
+func (k *KubeDynDNS) validCookie(state request.Request, cc, sc []byte, now time.Time) bool {
+	if len(sc) != serverCookieLen || sc[0] != serverCookieVer {
+		return false
+	}
+	ts := binary.BigEndian.Uint32(sc[4:8])
+	issued := time.Unix(int64(ts), 0)
+	if issued.After(now.Add(cookieClockSkew)) || now.Sub(issued) > cookieLifetime {
+		return false
+	}
+	return hmac.Equal(sc, k.serverCookie(cc, net.ParseIP(state.IP()), ts))
+}
+
+// prepareEDNS adds the OPT record to the response, if the request uses EDNS0.
+// The NSID is added if requested by the client and configured. If cookies are
+// enabled, a client cookie is answered with a fresh server cookie.
+// It reports whether the request contained a valid server cookie.
+func (k *KubeDynDNS) prepareEDNS(state request.Request, m *dns.Msg) bool {
+	o := state.Req.IsEdns0()
+	if o == nil || m.IsEdns0() != nil {
+		return false
+	}
+	m.SetEdns0(ednsUDPSize, o.Do())
+	opt := m.IsEdns0()
+
+	valid := false
+	for _, e := range o.Option {
+		switch e.(type) {
+		case *dns.EDNS0_NSID:
+			if k.nsid != "" {
+				opt.Option = append(opt.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: hex.EncodeToString([]byte(k.nsid))})
+			}
+		case *dns.EDNS0_COOKIE:
+			if k.cookieSecret == nil {
+				continue
+			}
+			cc, sc, err := requestCookie(state)
+			if err != nil || cc == nil {
+				continue
+			}
+			now := time.Now()
+			valid = k.validCookie(state, cc, sc, now)
+			cookie := slices.Concat(cc, k.serverCookie(cc, net.ParseIP(state.IP()), uint32(now.Unix())))
+			opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: hex.EncodeToString(cookie)})
+		}
+	}
+	return valid
+}
+
+// formatError answers a request with a malformed EDNS0 option.
+func (k *KubeDynDNS) formatError(ctx context.Context, zi *ZoneInfo, state request.Request) (int, error) {
+	m := new(dns.Msg)
+	m.SetRcode(state.Req, dns.RcodeFormatError)
+	k.writeMsg(ctx, zi, state, m)
+	// Return success as the rcode to signal we have written to the client.
+	return dns.RcodeSuccess, nil
+}
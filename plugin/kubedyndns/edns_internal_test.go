@@ -0,0 +1,109 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+func TestValidCookie(t *testing.T) {
+	k := New([]string{"example.org."})
+	k.cookieSecret = []byte("secret")
+	other := New([]string{"example.org."})
+	other.cookieSecret = []byte("other")
+
+	now := time.Now()
+	cc := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	ip := net.ParseIP("10.240.0.1")
+	at := func(t time.Time) uint32 { return uint32(t.Unix()) }
+	state := request.Request{W: &test.ResponseWriter{}, Req: new(dns.Msg)}
+
+	modified := func(sc []byte, i int) []byte {
+		sc = append([]byte(nil), sc...)
+		sc[i] ^= 0xff
+		return sc
+	}
+
+	tests := []struct {
+		name  string
+		cc    []byte
+		sc    []byte
+		valid bool
+	}{
+		{"fresh", cc, k.serverCookie(cc, ip, at(now)), true},
+		{"within lifetime", cc, k.serverCookie(cc, ip, at(now.Add(-cookieLifetime+time.Minute))), true},
+		{"within clock skew", cc, k.serverCookie(cc, ip, at(now.Add(cookieClockSkew-time.Minute))), true},
+		{"expired", cc, k.serverCookie(cc, ip, at(now.Add(-cookieLifetime-time.Minute))), false},
+		{"from the future", cc, k.serverCookie(cc, ip, at(now.Add(cookieClockSkew+time.Minute))), false},
+		{"other client cookie", []byte{8, 7, 6, 5, 4, 3, 2, 1}, k.serverCookie(cc, ip, at(now)), false},
+		{"other client address", cc, k.serverCookie(cc, net.ParseIP("10.240.0.2"), at(now)), false},
+		{"other secret", cc, other.serverCookie(cc, ip, at(now)), false},
+		{"other version", cc, modified(k.serverCookie(cc, ip, at(now)), 0), false},
+		{"modified hash", cc, modified(k.serverCookie(cc, ip, at(now)), serverCookieLen-1), false},
+		{"too short", cc, k.serverCookie(cc, ip, at(now))[:8], false},
+		{"missing", cc, nil, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if v := k.validCookie(state, tc.cc, tc.sc, now); v != tc.valid {
+				t.Errorf("valid %t, expected %t", v, tc.valid)
+			}
+		})
+	}
+}
+
+func TestRequestCookie(t *testing.T) {
+	tests := []struct {
+		cookie string
+		cc, sc int
+		err    bool
+	}{
+		{cookie: "0102030405060708", cc: 8},
+		{cookie: "0102030405060708" + "0102030405060708", cc: 8, sc: 8},
+		{cookie: strings.Repeat("0102030405060708", 5), cc: 8, sc: 32},
+		{cookie: "01020304050607", err: true},
+		{cookie: "0102030405060708" + "01020304050607", err: true},
+		{cookie: strings.Repeat("0102030405060708", 5) + "01", err: true},
+		{cookie: "xx02030405060708", err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.cookie, func(t *testing.T) {
+			m := new(dns.Msg)
+			m.SetQuestion("example.org.", dns.TypeA)
+			m.SetEdns0(1232, false)
+			o := m.IsEdns0()
+			o.Option = append(o.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: tc.cookie})
+
+			cc, sc, err := requestCookie(request.Request{W: &test.ResponseWriter{}, Req: m})
+			if (err != nil) != tc.err {
+				t.Fatalf("error %v, expected %t", err, tc.err)
+			}
+			if len(cc) != tc.cc || len(sc) != tc.sc {
+				t.Errorf("client cookie %x, server cookie %x", cc, sc)
+			}
+		})
+	}
+}
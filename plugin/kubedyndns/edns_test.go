@@ -0,0 +1,217 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/testenv"
+)
+
+// largeEntry provides an entry with n addresses.
+func largeEntry(n int) *api.CoreDNSEntry {
+	e := newEntry("large", api.CoreDNSSpec{DNSNames: []string{"large.example.org"}})
+	for i := 0; i < n; i++ {
+		e.Spec.A = append(e.Spec.A, fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+	return e
+}
+
+func startEnv(t *testing.T, stanza string) *testenv.Environment {
+	t.Helper()
+	return start(t, stanza, largeEntry(300))
+}
+
+func findOption[T dns.EDNS0](m *dns.Msg) T {
+	var zero T
+	if o := m.IsEdns0(); o != nil {
+		for _, e := range o.Option {
+			if t, ok := e.(T); ok {
+				return t
+			}
+		}
+	}
+	return zero
+}
+
+func TestTruncation(t *testing.T) {
+	env := startEnv(t, `kubedyndns example.org`)
+
+	tests := []struct {
+		name string
+		edns uint16
+		tcp  bool
+		// size is the maximum message size, 0 for a complete answer.
+		size int
+	}{
+		{name: "udp", size: dns.MinMsgSize},
+		{name: "udp edns", edns: 1232, size: 1232},
+		{name: "udp edns large buffer", edns: 4096, size: 4096},
+		{name: "tcp", tcp: true},
+		{name: "tcp edns", edns: 1232, tcp: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(dns.Msg)
+			m.SetQuestion("large.example.org.", dns.TypeA)
+			if tc.edns > 0 {
+				m.SetEdns0(tc.edns, false)
+			}
+			resp := exchange(t, env, m, "", tc.tcp)
+			if resp.Rcode != dns.RcodeSuccess {
+				t.Fatalf("rcode %s", dns.RcodeToString[resp.Rcode])
+			}
+			if tc.size == 0 {
+				if resp.Truncated || len(resp.Answer) != 300 {
+					t.Errorf("truncated %t with %d answers, expected complete answer", resp.Truncated, len(resp.Answer))
+				}
+				return
+			}
+			if !resp.Truncated {
+				t.Errorf("truncation not indicated for %d answers", len(resp.Answer))
+			}
+			if l := resp.Len(); l > tc.size {
+				t.Errorf("message size %d exceeds %d", l, tc.size)
+			}
+			if (tc.edns > 0) != (resp.IsEdns0() != nil) {
+				t.Errorf("unexpected OPT record %v", resp.IsEdns0())
+			}
+		})
+	}
+}
+
+func TestNSID(t *testing.T) {
+	tests := []struct {
+		name    string
+		stanza  string
+		request bool
+		nsid    string
+	}{
+		{name: "requested", stanza: "kubedyndns example.org {\n nsid replica-1\n}", request: true, nsid: "replica-1"},
+		{name: "not requested", stanza: "kubedyndns example.org {\n nsid replica-1\n}"},
+		{name: "not configured", stanza: `kubedyndns example.org`, request: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			env := startEnv(t, tc.stanza)
+			m := new(dns.Msg)
+			m.SetQuestion("missing.example.org.", dns.TypeA)
+			m.SetEdns0(1232, false)
+			if tc.request {
+				o := m.IsEdns0()
+				o.Option = append(o.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+			}
+			resp := exchange(t, env, m, "", false)
+			if resp.Rcode != dns.RcodeNameError {
+				t.Errorf("rcode %s", dns.RcodeToString[resp.Rcode])
+			}
+			nsid := findOption[*dns.EDNS0_NSID](resp)
+			switch {
+			case tc.nsid == "" && nsid != nil:
+				t.Errorf("unexpected nsid %q", nsid.Nsid)
+			case tc.nsid != "" && nsid == nil:
+				t.Errorf("nsid missing")
+			case tc.nsid != "" && nsid.Nsid != hex.EncodeToString([]byte(tc.nsid)):
+				t.Errorf("nsid %q, expected %q", nsid.Nsid, hex.EncodeToString([]byte(tc.nsid)))
+			}
+		})
+	}
+}
+
+func cookieRequest(cookie string) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion("large.example.org.", dns.TypeA)
+	m.SetEdns0(4096, false)
+	o := m.IsEdns0()
+	o.Option = append(o.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: cookie})
+	return m
+}
+
+func TestCookies(t *testing.T) {
+	const clientCookie = "0102030405060708"
+
+	env := startEnv(t, "kubedyndns example.org {\n cookies secret\n ratelimit 1 0\n}")
+
+	// a client cookie is answered with a server cookie
+	resp := exchange(t, env, cookieRequest(clientCookie), "", false)
+	cookie := findOption[*dns.EDNS0_COOKIE](resp)
+	if cookie == nil {
+		t.Fatal("cookie missing")
+	}
+	if len(cookie.Cookie) != 2*(8+16) || cookie.Cookie[:16] != clientCookie {
+		t.Fatalf("invalid cookie %q", cookie.Cookie)
+	}
+
+	// the rate limit is exhausted for requests without valid server cookie
+	for i := 0; i < 5; i++ {
+		env.Exchange(cookieRequest(clientCookie), &test.ResponseWriter{})
+	}
+	if resp, err := env.Exchange(cookieRequest(clientCookie), &test.ResponseWriter{}); err == nil {
+		t.Fatalf("rate limit not applied: %v", resp)
+	}
+
+	// requests with a valid server cookie are not rate limited
+	for i := 0; i < 5; i++ {
+		resp := exchange(t, env, cookieRequest(cookie.Cookie), "", false)
+		if len(resp.Answer) == 0 {
+			t.Fatalf("rate limit applied for valid cookie")
+		}
+	}
+
+	// a server cookie is only valid for the issuing client
+	if resp, err := env.Exchange(cookieRequest(cookie.Cookie), &test.ResponseWriter{RemoteIP: "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	} else if findOption[*dns.EDNS0_COOKIE](resp) == nil {
+		t.Errorf("cookie missing")
+	}
+	for i := 0; i < 5; i++ {
+		env.Exchange(cookieRequest(cookie.Cookie), &test.ResponseWriter{RemoteIP: "192.0.2.1"})
+	}
+	if resp, err := env.Exchange(cookieRequest(cookie.Cookie), &test.ResponseWriter{RemoteIP: "192.0.2.1"}); err == nil {
+		t.Fatalf("rate limit not applied for foreign cookie: %v", resp)
+	}
+}
+
+func TestMalformedCookie(t *testing.T) {
+	env := startEnv(t, "kubedyndns example.org {\n cookies secret\n}")
+
+	for _, cookie := range []string{"01020304", "0102030405060708090a", "010203040506070809", "not hex"} {
+		t.Run(cookie, func(t *testing.T) {
+			resp := exchange(t, env, cookieRequest(cookie), "", false)
+			if resp.Rcode != dns.RcodeFormatError {
+				t.Errorf("rcode %s, expected FORMERR", dns.RcodeToString[resp.Rcode])
+			}
+		})
+	}
+}
+
+func TestCookiesDisabled(t *testing.T) {
+	env := startEnv(t, `kubedyndns example.org`)
+
+	resp := exchange(t, env, cookieRequest("0102030405060708"), "", false)
+	if c := findOption[*dns.EDNS0_COOKIE](resp); c != nil {
+		t.Errorf("unexpected cookie %q", c.Cookie)
+	}
+}
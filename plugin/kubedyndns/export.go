@@ -0,0 +1,250 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+// ExportedZones returns the zones served by this plugin instance.
+// In transitive mode the nested zones are included.
+func (k *KubeDynDNS) ExportedZones() []*ZoneInfo {
+	var result []*ZoneInfo
+	if k.zoneRef != nil {
+		zo := k.APIConn.GetZone(*k.zoneRef)
+		if zo != nil {
+			for _, z := range k.ServedZones {
+				for _, n := range k.rootZones(zo, z) {
+					result = k.nestedZones(append(result, NewZoneInfo(n, zo)), NewZoneInfo(n, zo))
+				}
+			}
+		}
+	} else {
+		for _, z := range k.ServedZones {
+			result = append(result, NewZoneInfo(z, nil))
+		}
+	}
+	for _, z := range k.ReverseZones {
+		result = append(result, k.reverseZone(z))
+	}
+	return result
+}
+
+// EntryDomainNames returns the fully qualified domain names
+// of an entry served by this plugin instance.
+func (k *KubeDynDNS) EntryDomainNames(e *objects.Entry) []string {
+	var result []string
+	for _, n := range k.APIConn.EffectiveDomainNames(e) {
+		if dnsutil.IsReverse(n) > 0 {
+			result = append(result, n)
+			continue
+		}
+		result = append(result, k.forwardNames(n)...)
+	}
+	return result
+}
+
+// nestedZones adds the zones nested in the given one, which are
+// served by this plugin instance in transitive mode.
+func (k *KubeDynDNS) nestedZones(result []*ZoneInfo, zi *ZoneInfo) []*ZoneInfo {
+	if !k.transitive {
+		return result
+	}
+	k.APIConn.NameTree(zi).Walk(func(name string, n *NameNode) bool {
+		if name == "" || n.Zone == nil {
+			return true
+		}
+		nested := NewZoneInfo(name+zi.DomainName, n.Zone)
+		result = k.nestedZones(append(result, nested), nested)
+		return false
+	})
+	return result
+}
+
+// Export writes the records of a served zone in RFC 1035 master file format.
+// ALIAS entries cannot be represented and are written as comments, as well
+// as the clusters providing the entries for aggregated clusters.
+func (k *KubeDynDNS) Export(ctx context.Context, zi *ZoneInfo, w io.Writer) error {
+	rrs, comments := k.zoneRecords(ctx, zi)
+	if _, err := fmt.Fprintf(w, "$ORIGIN %s\n", zi.DomainName); err != nil {
+		return err
+	}
+	for _, rr := range rrs {
+		if _, err := fmt.Fprintln(w, rr.String()); err != nil {
+			return err
+		}
+	}
+	for _, c := range comments {
+		if _, err := fmt.Fprintf(w, "; %s\n", c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ZoneRecords returns all records of a served zone starting with the
+// SOA record. Names below delegation points are replaced by the NS
+// records and the required glue records of the delegation.
+func (k *KubeDynDNS) ZoneRecords(ctx context.Context, zi *ZoneInfo) []dns.RR {
+	rrs, _ := k.zoneRecords(ctx, zi)
+	return rrs
+}
+
+func (k *KubeDynDNS) zoneRecords(ctx context.Context, zi *ZoneInfo) ([]dns.RR, []string) {
+	var (
+		state    request.Request
+		comments []string
+	)
+
+	rrs := k.SOA(ctx, zi, state)
+	rrs = append(rrs, k.apexNameServers(zi)...)
+
+	k.APIConn.NameTree(zi).Walk(func(name string, n *NameNode) bool {
+		owner := name + zi.DomainName
+		if name != "" && n.IsCut() {
+			auth, extra := k.referral(zi, &NameLookup{Cut: n, CutName: name})
+			rrs = append(rrs, auth...)
+			for _, rr := range extra {
+				// addresses above the delegation point are regular records.
+				if dns.IsSubDomain(owner, rr.Header().Name) {
+					rrs = append(rrs, rr)
+				}
+			}
+			return false
+		}
+		for _, e := range n.Entries {
+			if e.Error != nil {
+				continue
+			}
+			if e.Alias != "" {
+				comments = append(comments, fmt.Sprintf("%s ALIAS %s", owner, absoluteName(e.Alias, zi.DomainName)))
+			}
+			if e.Cluster != "" {
+				comments = append(comments, fmt.Sprintf("%s provided by cluster %s (%s/%s)", owner, e.Cluster, e.Namespace, e.Name))
+			}
+			rrs = append(rrs, k.entryRecords(owner, zi, e)...)
+		}
+		return true
+	})
+
+	if dnsutil.IsReverse(zi.DomainName) > 0 {
+		rrs = append(rrs, k.generatedPTRs(zi)...)
+	}
+	return rrs, comments
+}
+
+// apexNameServers provides the NS records for the zone apex, see apexNS.
+func (k *KubeDynDNS) apexNameServers(zi *ZoneInfo) []dns.RR {
+	if zi.Object != nil {
+		return k.zoneNS(zi)
+	}
+	var rrs []dns.RR
+	for _, n := range k.defaultNameServers(zi) {
+		rrs = append(rrs, k.NS(n, zi.DomainName, k.ttl)...)
+	}
+	if k.soa.ns == "" && len(k.nameServers) == 0 {
+		for _, rr := range k.nsAddrs(false, zi.DomainName) {
+			rr.Header().Ttl = k.TTL(0)
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
+
+// entryRecords provides the records of an entry for the given owner name.
+// NS records are only provided at delegation points.
+func (k *KubeDynDNS) entryRecords(owner string, zi *ZoneInfo, e *objects.Entry) []dns.RR {
+	var rrs []dns.RR
+
+	services := func(t uint16, p string) []msg.Service {
+		return e.Services(t, p, k.ttl, zi.DomainName)
+	}
+	for _, s := range services(dns.TypeA, "") {
+		rrs = append(rrs, s.NewA(owner, net.ParseIP(s.Host)))
+	}
+	for _, s := range services(dns.TypeAAAA, "") {
+		rrs = append(rrs, s.NewAAAA(owner, net.ParseIP(s.Host)))
+	}
+	for _, s := range services(dns.TypeCNAME, "") {
+		rrs = append(rrs, s.NewCNAME(owner, dns.Fqdn(s.Host)))
+	}
+	for _, s := range services(dns.TypeTXT, "") {
+		rrs = append(rrs, s.NewTXT(owner))
+	}
+	for _, s := range services(dns.TypePTR, "") {
+		rrs = append(rrs, s.NewPTR(owner, dns.Fqdn(s.Host)))
+	}
+	if e.Service != nil && e.Service.Service != "" {
+		protocols := sets.New[string]()
+		for _, r := range e.Service.Records {
+			protocols.Insert(r.Protocol)
+		}
+		for _, p := range sets.List(protocols) {
+			name := fmt.Sprintf("_%s._%s.%s", e.Service.Service, strings.ToLower(p), owner)
+			for _, s := range services(dns.TypeSRV, p) {
+				rrs = append(rrs, s.NewSRV(name, uint16(s.Weight)))
+			}
+		}
+	}
+	return rrs
+}
+
+// generatedPTRs provides the PTR records generated for the addresses
+// of the entries in a reverse zone. Names with explicit PTR records
+// are omitted.
+func (k *KubeDynDNS) generatedPTRs(zi *ZoneInfo) []dns.RR {
+	tree := k.APIConn.NameTree(zi)
+
+	addrs := sets.New[string]()
+	for _, e := range k.APIConn.EntryList() {
+		for _, a := range append(slices.Clone(e.A), e.AAAA...) {
+			if ip := net.ParseIP(a); ip != nil {
+				addrs.Insert(ip.String())
+			}
+		}
+	}
+
+	var rrs []dns.RR
+	for _, a := range sets.List(addrs) {
+		owner, err := dns.ReverseAddr(a)
+		if err != nil || !dns.IsSubDomain(zi.DomainName, owner) {
+			continue
+		}
+		if n := tree.Find(relativeName(owner, zi.DomainName)); n != nil && slices.ContainsFunc(n.Entries, func(e *objects.Entry) bool { return len(e.PTR) > 0 }) {
+			continue
+		}
+		for _, r := range k.reverseNames(a) {
+			rrs = append(rrs, &dns.PTR{Hdr: dns.RR_Header{Name: owner, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: r.ttl}, Ptr: r.name})
+		}
+	}
+	return rrs
+}
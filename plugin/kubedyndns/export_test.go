@@ -0,0 +1,112 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns_test
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/testenv"
+)
+
+// export provides the lines of the master file of a served zone.
+// The serial of the SOA record is set to 0.
+func export(t *testing.T, env *testenv.Environment, zone string) []string {
+	t.Helper()
+	for _, zi := range env.Plugin.ExportedZones() {
+		if zi.DomainName != zone {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := env.Plugin.Export(env.Context(), zi, &buf); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		for i, l := range lines {
+			if rr, err := dns.NewRR(l); err == nil && rr != nil {
+				if soa, ok := rr.(*dns.SOA); ok {
+					soa.Serial = 0
+				}
+				lines[i] = rr.String()
+			}
+		}
+		return lines
+	}
+	t.Fatalf("zone %s not exported", zone)
+	return nil
+}
+
+// checkExport compares the exported lines with the expected ones
+// given as records in presentation format or comments.
+func checkExport(t *testing.T, lines []string, expected ...string) {
+	t.Helper()
+	for i, e := range expected {
+		if rr, err := dns.NewRR(e); err == nil && rr != nil {
+			expected[i] = rr.String()
+		}
+	}
+	if !slices.Equal(lines, expected) {
+		t.Errorf("unexpected export:\n%s\nexpected:\n%s", strings.Join(lines, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestExport(t *testing.T) {
+	env := start(t, "kubedyndns example.org {\n nameservers ns1\n}",
+		newEntry("ns1", api.CoreDNSSpec{DNSNames: []string{"ns1.example.org"}, A: []string{"192.0.2.53"}}),
+		newEntry("www", api.CoreDNSSpec{DNSNames: []string{"www.example.org"}, A: []string{"192.0.2.1"}}),
+		newEntry("sub", api.CoreDNSSpec{DNSNames: []string{"sub.example.org"}, NS: []string{"ns.sub.example.org.", "ns1.example.org."}}),
+		newEntry("glue", api.CoreDNSSpec{DNSNames: []string{"ns.sub.example.org"}, A: []string{"192.0.2.2"}}),
+		newEntry("alias", api.CoreDNSSpec{DNSNames: []string{"alias.example.org"}, ALIAS: "www.example.org."}),
+	)
+
+	checkExport(t, export(t, env, "example.org."),
+		"$ORIGIN example.org.",
+		"example.org. 10 IN SOA ns1.example.org. hostmaster.example.org. 0 7200 1800 86400 10",
+		"example.org. 10 IN NS ns1.example.org.",
+		"ns1.example.org. 10 IN A 192.0.2.53",
+		// the glue below the delegation point is kept, the address
+		// of ns1 above it is only provided as regular record.
+		"sub.example.org. 10 IN NS ns.sub.example.org.",
+		"sub.example.org. 10 IN NS ns1.example.org.",
+		"ns.sub.example.org. 10 IN A 192.0.2.2",
+		"www.example.org. 10 IN A 192.0.2.1",
+		"; alias.example.org. ALIAS www.example.org.",
+	)
+}
+
+func TestExportReverse(t *testing.T) {
+	env := start(t, reverseStanza, append(reverseEntries,
+		newEntry("ftp", api.CoreDNSSpec{DNSNames: []string{"ftp"}, A: []string{"192.0.2.3"}}),
+		newEntry("explicit", api.CoreDNSSpec{DNSNames: []string{"1.2.0.192.in-addr.arpa"}, PTR: []string{"custom.example.org"}}),
+	)...)
+
+	checkExport(t, export(t, env, "2.0.192.in-addr.arpa."),
+		"$ORIGIN 2.0.192.in-addr.arpa.",
+		"2.0.192.in-addr.arpa. 10 IN SOA ns.dns.2.0.192.in-addr.arpa. hostmaster.2.0.192.in-addr.arpa. 0 7200 1800 86400 10",
+		"2.0.192.in-addr.arpa. 10 IN NS ns.dns.2.0.192.in-addr.arpa.",
+		// the explicit record replaces the generated ones of www and mail
+		"1.2.0.192.in-addr.arpa. 10 IN PTR custom.example.org.",
+		"3.2.0.192.in-addr.arpa. 10 IN PTR ftp.example.org.",
+	)
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+// Package filesource provides CoreDNSEntry and HostedZone objects
+// read from manifest files instead of a Kubernetes cluster.
+// The objects are kept in an in-memory store serving lists and
+// watches, so that the regular controller of the plugin can be used.
+package filesource
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/client/clientset/versioned/scheme"
+)
+
+// DefaultNamespace is used for objects without namespace.
+const DefaultNamespace = "default"
+
+var decoder = serializer.NewCodecFactory(scheme.Scheme).UniversalDeserializer()
+
+// ReadObjects reads the CoreDNSEntry and HostedZone objects from
+// the given YAML or JSON files. Directories are read non-recursively
+// considering all files with the extension .yaml, .yml or .json. Files may
+// contain multiple documents.
+func ReadObjects(paths ...string) ([]runtime.Object, error) {
+	var result []runtime.Object
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{p}
+		if fi.IsDir() {
+			files, err = ManifestFiles(p)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, f := range files {
+			objs, err := ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, objs...)
+		}
+	}
+	return result, nil
+}
+
+// ReadFile reads the objects of a single manifest file.
+func ReadFile(path string) ([]runtime.Object, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := DecodeObjects(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return objs, nil
+}
+
+// ManifestFiles returns the manifest files of a directory.
+func ManifestFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && IsManifestFile(e.Name()) {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// IsManifestFile checks whether a file name has a manifest file extension.
+func IsManifestFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return !strings.HasPrefix(filepath.Base(name), ".")
+	}
+	return false
+}
+
+// DecodeObjects decodes the CoreDNSEntry and HostedZone objects
+// described by a (multi-document) YAML or JSON manifest.
+func DecodeObjects(data []byte) ([]runtime.Object, error) {
+	var result []runtime.Object
+	r := yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		doc, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		obj, _, err := decoder.Decode(doc, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		switch obj.(type) {
+		case *api.CoreDNSEntry, *api.HostedZone:
+		default:
+			return nil, fmt.Errorf("unexpected object type %T", obj)
+		}
+		if o, ok := obj.(meta.Object); ok && o.GetNamespace() == "" {
+			o.SetNamespace(DefaultNamespace)
+		}
+		result = append(result, obj)
+	}
+}
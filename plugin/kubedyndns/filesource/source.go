@@ -0,0 +1,288 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package filesource
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/utils"
+)
+
+var Log clog.P = clog.NewWithPlugin("kubedyndns")
+
+// syncDelay is the time to wait for further file changes
+// before the directory is read again.
+const syncDelay = 200 * time.Millisecond
+
+// Source provides the objects described by the manifest files of
+// a directory. Changes of the files are detected via inotify and
+// propagated to an in-memory store. The status of the objects is logged
+// and optionally written to a status file.
+type Source struct {
+	dir        string
+	statusFile string
+	store      *Store
+
+	lock          sync.Mutex
+	files         map[string][]runtime.Object
+	statusChanged chan struct{}
+
+	runner utils.SharedRunner
+}
+
+// New creates a source for the manifest files of the given directory.
+// If a status file is given, the status of all objects is written to
+// this file whenever it changes.
+func New(dir, statusFile string) (*Source, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is no directory", dir)
+	}
+	if statusFile != "" {
+		statusFile, err = filepath.Abs(statusFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s := &Source{
+		dir:           dir,
+		statusFile:    statusFile,
+		files:         map[string][]runtime.Object{},
+		statusChanged: make(chan struct{}, 1),
+	}
+	s.store = NewStore(s.statusUpdated)
+	return s, s.Sync()
+}
+
+// Client returns the client providing the objects.
+func (s *Source) Client() objects.Client {
+	return s.store
+}
+
+// KubeClient returns nil, because namespaces are not
+// supported without cluster.
+func (s *Source) KubeClient() kubernetes.Interface {
+	return nil
+}
+
+// HasSynced reports whether the initial objects are available,
+// which is always the case after the creation of the source.
+func (s *Source) HasSynced() bool {
+	return true
+}
+
+// Run watches the directory for changes until the stop channel is closed.
+// A source shared by several controllers runs until all of them
+// have been stopped.
+func (s *Source) Run(stop <-chan struct{}) {
+	s.runner.Run(stop, s.run)
+}
+
+func (s *Source) run(stop <-chan struct{}) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		Log.Errorf("cannot watch %s: %s", s.dir, err)
+		return
+	}
+	defer w.Close()
+	if err := w.Add(s.dir); err != nil {
+		Log.Errorf("cannot watch %s: %s", s.dir, err)
+		return
+	}
+	Log.Infof("watching manifests in %s", s.dir)
+
+	timer := time.NewTimer(syncDelay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if IsManifestFile(ev.Name) && !s.isStatusFile(ev.Name) {
+				timer.Reset(syncDelay)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			Log.Errorf("watching %s: %s", s.dir, err)
+		case <-timer.C:
+			if err := s.Sync(); err != nil {
+				Log.Errorf("cannot read manifests: %s", err)
+			}
+		case <-s.statusChanged:
+			if err := s.writeStatus(); err != nil {
+				Log.Errorf("cannot write status file %s: %s", s.statusFile, err)
+			}
+		}
+	}
+}
+
+func (s *Source) isStatusFile(path string) bool {
+	if s.statusFile == "" {
+		return false
+	}
+	p, err := filepath.Abs(path)
+	return err == nil && p == s.statusFile
+}
+
+// Sync reads the manifest files and updates the objects of the store.
+// If a file cannot be read, its last successfully read objects are kept.
+func (s *Source) Sync() error {
+	files, err := ManifestFiles(s.dir)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	found := map[string][]runtime.Object{}
+	origin := map[objectKey]string{}
+	var desired []runtime.Object
+	for _, f := range files {
+		if s.isStatusFile(f) {
+			continue
+		}
+		objs, err := ReadFile(f)
+		if err != nil {
+			Log.Errorf("%s", err)
+			objs = s.files[f]
+		}
+		found[f] = objs
+		for _, o := range objs {
+			k, err := keyOf(o)
+			if err != nil {
+				Log.Errorf("%s: %s", f, err)
+				continue
+			}
+			if prev, ok := origin[k]; ok {
+				Log.Warningf("%s: %s already defined in %s", f, k, prev)
+				continue
+			}
+			origin[k] = f
+			desired = append(desired, o)
+		}
+	}
+	s.files = found
+	if err := s.store.Apply(desired); err != nil {
+		return err
+	}
+	s.notifyStatus()
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// status reporting
+
+// statusUpdated logs the status of an object. It is called by
+// the store for status updates.
+func (s *Source) statusUpdated(o runtime.Object) {
+	k, _ := keyOf(o)
+	state, msg := describe(o)
+	if msg != "" {
+		Log.Infof("status of %s: %s: %s", k, state, msg)
+	} else {
+		Log.Infof("status of %s: %s", k, state)
+	}
+	s.notifyStatus()
+}
+
+func (s *Source) notifyStatus() {
+	if s.statusFile == "" {
+		return
+	}
+	select {
+	case s.statusChanged <- struct{}{}:
+	default:
+	}
+}
+
+// describe provides the state and message of the status of an object.
+func describe(o runtime.Object) (string, string) {
+	var (
+		state, msg string
+		conditions []metav1.Condition
+	)
+	switch obj := o.(type) {
+	case *api.CoreDNSEntry:
+		state, msg, conditions = obj.Status.State, obj.Status.Message, obj.Status.Conditions
+	case *api.HostedZone:
+		state, msg, conditions = obj.Status.State, obj.Status.Message, obj.Status.Conditions
+	}
+	if c := meta.FindStatusCondition(conditions, api.ServerConditionType); c != nil {
+		return c.Reason, c.Message
+	}
+	return state, msg
+}
+
+// statusRecord is the status of an object in the status file.
+type statusRecord struct {
+	Kind      string      `json:"kind"`
+	Namespace string      `json:"namespace"`
+	Name      string      `json:"name"`
+	Status    interface{} `json:"status"`
+}
+
+// writeStatus writes the status of all objects to the status file.
+func (s *Source) writeStatus() error {
+	var records []statusRecord
+	for _, o := range s.store.Objects() {
+		k, _ := keyOf(o)
+		var status interface{}
+		switch obj := o.(type) {
+		case *api.CoreDNSEntry:
+			status = obj.Status
+		case *api.HostedZone:
+			status = obj.Status
+		}
+		records = append(records, statusRecord{k.kind, k.namespace, k.name, status})
+	}
+
+	data, err := yaml.Marshal(records)
+	if err != nil {
+		return err
+	}
+	tmp := s.statusFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.statusFile)
+}
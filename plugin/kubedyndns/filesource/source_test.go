@@ -0,0 +1,96 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package filesource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func writeEntry(t *testing.T, dir, name string) {
+	t.Helper()
+	manifest := "apiVersion: coredns.mandelsoft.org/v1alpha1\nkind: CoreDNSEntry\nmetadata:\n  name: " + name +
+		"\nspec:\n  dnsNames:\n  - " + name + ".example.org\n  A:\n  - 10.0.0.1\n"
+	if err := os.WriteFile(filepath.Join(dir, name+".yaml"), []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func hasEntry(s *Source, name string) bool {
+	list, err := s.store.ListEntries(context.Background(), "", metav1.ListOptions{})
+	if err != nil {
+		return false
+	}
+	for _, e := range list.Items {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// addEntry writes the manifest of an entry until the running source
+// has read it. The file is written again, because the watch of the
+// directory might not yet be established.
+func addEntry(t *testing.T, s *Source, dir, name string) {
+	t.Helper()
+	for timeout := time.After(5 * time.Second); !hasEntry(s, name); {
+		writeEntry(t, dir, name)
+		select {
+		case <-timeout:
+			t.Fatalf("entry %s not found", name)
+		case <-time.After(4 * syncDelay):
+		}
+	}
+}
+
+func TestSourceSharedRun(t *testing.T) {
+	dir := t.TempDir()
+	writeEntry(t, dir, "a")
+	s, err := New(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasEntry(s, "a") {
+		t.Fatal("initial entry not found")
+	}
+
+	first, second := make(chan struct{}), make(chan struct{})
+	go s.Run(first)
+	addEntry(t, s, dir, "b")
+	go s.Run(second)
+	time.Sleep(100 * time.Millisecond)
+
+	// the source keeps running for the remaining user
+	close(first)
+	addEntry(t, s, dir, "c")
+
+	close(second)
+	time.Sleep(100 * time.Millisecond)
+	writeEntry(t, dir, "d")
+	time.Sleep(4 * syncDelay)
+	if hasEntry(s, "d") {
+		t.Error("source still running after all users have been stopped")
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package filesource
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+// StaticSource provides a given set of objects kept in an
+// in-memory store. It is used to process manifests offline,
+// for example to validate them before they are deployed.
+type StaticSource struct {
+	store *Store
+}
+
+// NewStatic creates a source for the given objects. Status
+// updates are reported to the optional status handler.
+func NewStatic(status StatusHandler, objs ...runtime.Object) (*StaticSource, error) {
+	s := &StaticSource{store: NewStore(status)}
+	for _, o := range objs {
+		if _, err := s.store.Put(o); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Store returns the store holding the objects.
+func (s *StaticSource) Store() *Store {
+	return s.store
+}
+
+// Client returns the client providing the objects.
+func (s *StaticSource) Client() objects.Client {
+	return s.store
+}
+
+// KubeClient returns nil, because namespaces are not
+// supported without cluster.
+func (s *StaticSource) KubeClient() kubernetes.Interface {
+	return nil
+}
+
+// HasSynced reports whether the initial objects are available,
+// which is always the case.
+func (s *StaticSource) HasSynced() bool {
+	return true
+}
+
+// Run does nothing, because the objects are only changed
+// via the store.
+func (s *StaticSource) Run(stop <-chan struct{}) {}
@@ -0,0 +1,545 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package filesource
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+const (
+	kindEntry = "CoreDNSEntry"
+	kindZone  = "HostedZone"
+)
+
+// maxHistory is the number of changes kept for watches
+// started at a resource version.
+const maxHistory = 1000
+
+// StatusHandler is called for status updates of objects.
+type StatusHandler func(obj runtime.Object)
+
+type objectKey struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func (k objectKey) String() string {
+	return fmt.Sprintf("%s %s/%s", k.kind, k.namespace, k.name)
+}
+
+func (k objectKey) resource() schema.GroupResource {
+	if k.kind == kindZone {
+		return api.Resource("hostedzones")
+	}
+	return api.Resource("corednsentries")
+}
+
+func keyOf(o runtime.Object) (objectKey, error) {
+	switch obj := o.(type) {
+	case *api.CoreDNSEntry:
+		return objectKey{kindEntry, obj.Namespace, obj.Name}, nil
+	case *api.HostedZone:
+		return objectKey{kindZone, obj.Namespace, obj.Name}, nil
+	}
+	return objectKey{}, fmt.Errorf("unexpected object type %T", o)
+}
+
+// change is a change of a stored object. The old object
+// is nil for created objects, the new one for deleted objects.
+type change struct {
+	version uint64
+	key     objectKey
+	old     runtime.Object
+	new     runtime.Object
+}
+
+// Store keeps CoreDNSEntry and HostedZone objects in memory. Like the
+// API server it maintains resource versions and generations, provides
+// a status sub resource and serves watches, so that it can be used as
+// objects.Client by the controller of the plugin. Status updates are
+// reported to the optional status handler.
+type Store struct {
+	lock     sync.Mutex
+	version  uint64
+	objects  map[objectKey]runtime.Object
+	history  []*change
+	watchers map[*watcher]struct{}
+	status   StatusHandler
+}
+
+var _ objects.Client = (*Store)(nil)
+
+// NewStore creates an empty store. Status updates are reported
+// to the optional status handler.
+func NewStore(status StatusHandler) *Store {
+	return &Store{
+		objects:  map[objectKey]runtime.Object{},
+		watchers: map[*watcher]struct{}{},
+		status:   status,
+	}
+}
+
+// Apply adjusts the stored objects to the given ones. Objects are
+// identified by kind, namespace and name. Existing objects are only
+// updated if their spec, labels or annotations are changed, their
+// status is kept.
+func (s *Store) Apply(objs []runtime.Object) error {
+	current := map[objectKey]runtime.Object{}
+	for _, o := range s.Objects() {
+		k, _ := keyOf(o)
+		current[k] = o
+	}
+
+	for _, o := range objs {
+		k, err := keyOf(o)
+		if err != nil {
+			return err
+		}
+		old, ok := current[k]
+		if old == nil && ok {
+			// already handled
+			continue
+		}
+		current[k] = nil
+		mod, err := s.Put(o)
+		if err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+		switch {
+		case old == nil:
+			Log.Infof("%s created", k)
+		case mod:
+			Log.Infof("%s updated", k)
+		}
+	}
+	for k, o := range current {
+		if o != nil && s.Delete(o) {
+			Log.Infof("%s deleted", k)
+		}
+	}
+	return nil
+}
+
+// Objects returns all stored objects, the HostedZone objects first.
+func (s *Store) Objects() []runtime.Object {
+	var result []runtime.Object
+	for _, kind := range []string{kindZone, kindEntry} {
+		objs, _ := s.list(kind, "", labels.Everything())
+		result = append(result, objs...)
+	}
+	return result
+}
+
+// Entry returns the stored entry with the given namespace and name.
+func (s *Store) Entry(namespace, name string) (*api.CoreDNSEntry, bool) {
+	o := s.get(objectKey{kindEntry, namespace, name})
+	if o == nil {
+		return nil, false
+	}
+	return o.(*api.CoreDNSEntry), true
+}
+
+// Zone returns the stored zone with the given namespace and name.
+func (s *Store) Zone(namespace, name string) (*api.HostedZone, bool) {
+	o := s.get(objectKey{kindZone, namespace, name})
+	if o == nil {
+		return nil, false
+	}
+	return o.(*api.HostedZone), true
+}
+
+func (s *Store) get(k objectKey) runtime.Object {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if o := s.objects[k]; o != nil {
+		return o.DeepCopyObject()
+	}
+	return nil
+}
+
+// Put creates or updates an object. Existing objects are only updated
+// if their spec, labels or annotations are changed, their status is kept.
+// It reports whether the stored object has been changed.
+func (s *Store) Put(obj runtime.Object) (bool, error) {
+	k, err := keyOf(obj)
+	if err != nil {
+		return false, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	old := s.objects[k]
+	if old != nil && sameMeta(old, obj) && sameSpec(old, obj) {
+		return false, nil
+	}
+	n := obj.DeepCopyObject()
+	generation := int64(1)
+	if old != nil {
+		generation = accessor(old).GetGeneration()
+		if !sameSpec(old, obj) {
+			generation++
+		}
+		switch o := n.(type) {
+		case *api.CoreDNSEntry:
+			o.Status = *old.(*api.CoreDNSEntry).Status.DeepCopy()
+		case *api.HostedZone:
+			o.Status = *old.(*api.HostedZone).Status.DeepCopy()
+		}
+	}
+	accessor(n).SetGeneration(generation)
+	s.commit(k, old, n)
+	return true, nil
+}
+
+// Delete deletes the stored object with the kind, namespace and
+// name of the given object. It reports whether an object has been deleted.
+func (s *Store) Delete(obj runtime.Object) bool {
+	k, err := keyOf(obj)
+	if err != nil {
+		return false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	old := s.objects[k]
+	if old == nil {
+		return false
+	}
+	s.commit(k, old, nil)
+	return true
+}
+
+// commit stores a change and propagates it to the watches.
+// It must be called with the lock held.
+func (s *Store) commit(k objectKey, old, new runtime.Object) {
+	s.version++
+	c := &change{version: s.version, key: k, old: old, new: new}
+	if new != nil {
+		accessor(new).SetResourceVersion(strconv.FormatUint(s.version, 10))
+		s.objects[k] = new
+	} else {
+		delete(s.objects, k)
+	}
+	s.history = append(s.history, c)
+	if len(s.history) > 2*maxHistory {
+		s.history = append([]*change(nil), s.history[len(s.history)-maxHistory:]...)
+	}
+	for w := range s.watchers {
+		w.notify(c)
+	}
+}
+
+// list returns copies of the matching objects and the current version.
+func (s *Store) list(kind, namespace string, selector labels.Selector) ([]runtime.Object, string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []runtime.Object
+	for k, o := range s.objects {
+		if k.kind == kind && (namespace == "" || k.namespace == namespace) && selector.Matches(labels.Set(accessor(o).GetLabels())) {
+			result = append(result, o.DeepCopyObject())
+		}
+	}
+	return result, strconv.FormatUint(s.version, 10)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// objects.Client
+
+func (s *Store) ListEntries(ctx context.Context, namespace string, opts metav1.ListOptions) (*api.CoreDNSEntryList, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	objs, version := s.list(kindEntry, namespace, selector)
+	list := &api.CoreDNSEntryList{ListMeta: metav1.ListMeta{ResourceVersion: version}}
+	for _, o := range objs {
+		list.Items = append(list.Items, *o.(*api.CoreDNSEntry))
+	}
+	return list, nil
+}
+
+func (s *Store) WatchEntries(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	return s.watch(ctx, kindEntry, namespace, opts)
+}
+
+func (s *Store) UpdateEntryStatus(ctx context.Context, e *api.CoreDNSEntry) (*api.CoreDNSEntry, error) {
+	o, err := s.updateStatus(e, func(old runtime.Object) runtime.Object {
+		n := old.DeepCopyObject().(*api.CoreDNSEntry)
+		n.Status = *e.Status.DeepCopy()
+		return n
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o.(*api.CoreDNSEntry), nil
+}
+
+func (s *Store) ListZones(ctx context.Context, namespace string, opts metav1.ListOptions) (*api.HostedZoneList, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	objs, version := s.list(kindZone, namespace, selector)
+	list := &api.HostedZoneList{ListMeta: metav1.ListMeta{ResourceVersion: version}}
+	for _, o := range objs {
+		list.Items = append(list.Items, *o.(*api.HostedZone))
+	}
+	return list, nil
+}
+
+func (s *Store) WatchZones(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	return s.watch(ctx, kindZone, namespace, opts)
+}
+
+func (s *Store) UpdateZoneStatus(ctx context.Context, z *api.HostedZone) (*api.HostedZone, error) {
+	o, err := s.updateStatus(z, func(old runtime.Object) runtime.Object {
+		n := old.DeepCopyObject().(*api.HostedZone)
+		n.Status = *z.Status.DeepCopy()
+		return n
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o.(*api.HostedZone), nil
+}
+
+// updateStatus emulates the status sub resource of the API server:
+// only the status of the stored object is changed.
+func (s *Store) updateStatus(obj runtime.Object, set func(old runtime.Object) runtime.Object) (runtime.Object, error) {
+	k, err := keyOf(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	old := s.objects[k]
+	if old == nil {
+		s.lock.Unlock()
+		return nil, apierrors.NewNotFound(k.resource(), k.name)
+	}
+	if v := accessor(obj).GetResourceVersion(); v != "" && v != accessor(old).GetResourceVersion() {
+		s.lock.Unlock()
+		return nil, apierrors.NewConflict(k.resource(), k.name, fmt.Errorf("object has been modified"))
+	}
+	n := set(old)
+	if equality.Semantic.DeepEqual(old, n) {
+		s.lock.Unlock()
+		return n, nil
+	}
+	s.commit(k, old, n)
+	n = n.DeepCopyObject()
+	s.lock.Unlock()
+
+	if s.status != nil {
+		s.status(n.DeepCopyObject())
+	}
+	return n, nil
+}
+
+// watch starts a watch for the objects of a kind. Like the API server
+// all matching objects are reported as added without resource version,
+// otherwise the changes after the given version are reported.
+func (s *Store) watch(ctx context.Context, kind, namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	w := &watcher{
+		store:     s,
+		kind:      kind,
+		namespace: namespace,
+		selector:  selector,
+		result:    make(chan watch.Event),
+		signal:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch opts.ResourceVersion {
+	case "", "0":
+		for k, o := range s.objects {
+			w.notify(&change{key: k, new: o})
+		}
+	default:
+		version, err := strconv.ParseUint(opts.ResourceVersion, 10, 64)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid resource version %q", opts.ResourceVersion))
+		}
+		if version > s.version || (version < s.version && (len(s.history) == 0 || s.history[0].version > version+1)) {
+			return nil, apierrors.NewResourceExpired(fmt.Sprintf("too old resource version: %d (%d)", version, s.version))
+		}
+		for _, c := range s.history {
+			if c.version > version {
+				w.notify(c)
+			}
+		}
+	}
+	s.watchers[w] = struct{}{}
+	go w.run(ctx)
+	return w, nil
+}
+
+func (s *Store) removeWatcher(w *watcher) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.watchers, w)
+}
+
+func accessor(o runtime.Object) metav1.Object {
+	m, err := meta.Accessor(o)
+	if err != nil {
+		panic(fmt.Sprintf("unexpected object type %T", o))
+	}
+	return m
+}
+
+// sameMeta checks whether the labels and annotations of an object
+// have been changed.
+func sameMeta(old, new runtime.Object) bool {
+	om, nm := accessor(old), accessor(new)
+	return equality.Semantic.DeepEqual(om.GetLabels(), nm.GetLabels()) && equality.Semantic.DeepEqual(om.GetAnnotations(), nm.GetAnnotations())
+}
+
+// sameSpec checks whether the spec of an object has been changed.
+func sameSpec(old, new runtime.Object) bool {
+	switch o := old.(type) {
+	case *api.CoreDNSEntry:
+		n, ok := new.(*api.CoreDNSEntry)
+		return ok && equality.Semantic.DeepEqual(o.Spec, n.Spec)
+	case *api.HostedZone:
+		n, ok := new.(*api.HostedZone)
+		return ok && equality.Semantic.DeepEqual(o.Spec, n.Spec)
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// watches
+
+// watcher provides the changes of the stored objects of a kind.
+// Changes are queued, so that slow consumers do not block the store.
+type watcher struct {
+	store     *Store
+	kind      string
+	namespace string
+	selector  labels.Selector
+
+	lock    sync.Mutex
+	pending []watch.Event
+
+	result chan watch.Event
+	signal chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (w *watcher) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+func (w *watcher) Stop() {
+	w.once.Do(func() {
+		w.store.removeWatcher(w)
+		close(w.done)
+	})
+}
+
+func (w *watcher) matches(k objectKey, o runtime.Object) bool {
+	return o != nil && k.kind == w.kind && (w.namespace == "" || k.namespace == w.namespace) &&
+		w.selector.Matches(labels.Set(accessor(o).GetLabels()))
+}
+
+// notify queues the event for a change. Like the API server, objects
+// no longer matching the label selector are reported as deleted, and
+// objects starting to match as added.
+func (w *watcher) notify(c *change) {
+	var e watch.Event
+	oldMatch, newMatch := w.matches(c.key, c.old), w.matches(c.key, c.new)
+	switch {
+	case oldMatch && newMatch:
+		e = watch.Event{Type: watch.Modified, Object: c.new.DeepCopyObject()}
+	case newMatch:
+		e = watch.Event{Type: watch.Added, Object: c.new.DeepCopyObject()}
+	case oldMatch && c.new != nil:
+		e = watch.Event{Type: watch.Deleted, Object: c.new.DeepCopyObject()}
+	case oldMatch:
+		obj := c.old.DeepCopyObject()
+		accessor(obj).SetResourceVersion(strconv.FormatUint(c.version, 10))
+		e = watch.Event{Type: watch.Deleted, Object: obj}
+	default:
+		return
+	}
+	w.lock.Lock()
+	w.pending = append(w.pending, e)
+	w.lock.Unlock()
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) run(ctx context.Context) {
+	defer close(w.result)
+	for {
+		w.lock.Lock()
+		events := w.pending
+		w.pending = nil
+		w.lock.Unlock()
+
+		for _, e := range events {
+			select {
+			case w.result <- e:
+			case <-w.done:
+				return
+			case <-ctx.Done():
+				w.Stop()
+				return
+			}
+		}
+		select {
+		case <-w.signal:
+		case <-w.done:
+			return
+		case <-ctx.Done():
+			w.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,230 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package filesource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+)
+
+func entry(name string, labels map[string]string, names ...string) *api.CoreDNSEntry {
+	return &api.CoreDNSEntry{
+		ObjectMeta: metav1.ObjectMeta{Namespace: DefaultNamespace, Name: name, Labels: labels},
+		Spec:       api.CoreDNSSpec{DNSNames: names, A: []string{"10.0.0.1"}},
+	}
+}
+
+func nextEvent(t *testing.T, w watch.Interface) watch.Event {
+	t.Helper()
+	select {
+	case e, ok := <-w.ResultChan():
+		if !ok {
+			t.Fatal("watch closed")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+	return watch.Event{}
+}
+
+func expectEvent(t *testing.T, w watch.Interface, typ watch.EventType, name string) {
+	t.Helper()
+	e := nextEvent(t, w)
+	if e.Type != typ || e.Object.(metav1.Object).GetName() != name {
+		t.Fatalf("expected %s for %s, got %s for %s", typ, name, e.Type, e.Object.(metav1.Object).GetName())
+	}
+}
+
+func TestStoreListWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewStore(nil)
+
+	if _, err := s.Put(entry("a", nil, "a")); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.ListEntries(ctx, "", metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.ResourceVersion != "1" || list.Items[0].ResourceVersion != "1" || list.Items[0].Generation != 1 {
+		t.Fatalf("unexpected list %#v", list)
+	}
+
+	w, err := s.WatchEntries(ctx, "", metav1.ListOptions{ResourceVersion: list.ResourceVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	if mod, _ := s.Put(entry("a", nil, "a")); mod {
+		t.Error("unchanged object must not be updated")
+	}
+	if _, err := s.Put(entry("b", nil, "b")); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, w, watch.Added, "b")
+	if _, err := s.Put(entry("a", nil, "a", "c")); err != nil {
+		t.Fatal(err)
+	}
+	e := nextEvent(t, w)
+	if e.Type != watch.Modified || e.Object.(*api.CoreDNSEntry).Generation != 2 {
+		t.Fatalf("unexpected event %s %#v", e.Type, e.Object)
+	}
+	s.Delete(entry("b", nil))
+	expectEvent(t, w, watch.Deleted, "b")
+
+	// replay of changes after a version
+	w2, err := s.WatchEntries(ctx, "", metav1.ListOptions{ResourceVersion: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w2.Stop()
+	expectEvent(t, w2, watch.Added, "b")
+	expectEvent(t, w2, watch.Modified, "a")
+	expectEvent(t, w2, watch.Deleted, "b")
+}
+
+func TestStoreWatchSelector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewStore(nil)
+
+	w, err := s.WatchEntries(ctx, "", metav1.ListOptions{LabelSelector: "served=true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	s.Put(entry("a", nil, "a"))
+	s.Put(entry("b", map[string]string{"served": "true"}, "b"))
+	expectEvent(t, w, watch.Added, "b")
+	s.Put(entry("a", map[string]string{"served": "true"}, "a"))
+	expectEvent(t, w, watch.Added, "a")
+	s.Put(entry("b", nil, "b"))
+	expectEvent(t, w, watch.Deleted, "b")
+
+	list, err := s.ListEntries(ctx, "", metav1.ListOptions{LabelSelector: "served=true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "a" {
+		t.Errorf("unexpected list %#v", list.Items)
+	}
+}
+
+func TestStoreWatchExpired(t *testing.T) {
+	s := NewStore(nil)
+	for i := 0; i < 2*maxHistory+1; i++ {
+		s.Put(entry("a", nil, "a", string(rune('a'+i%26))))
+	}
+	_, err := s.WatchEntries(context.Background(), "", metav1.ListOptions{ResourceVersion: "1"})
+	if !apierrors.IsResourceExpired(err) {
+		t.Errorf("expected expired error, got %v", err)
+	}
+}
+
+func TestStoreStatus(t *testing.T) {
+	ctx := context.Background()
+	var updated []runtime.Object
+	s := NewStore(func(o runtime.Object) { updated = append(updated, o) })
+
+	s.Put(entry("a", nil, "a"))
+	e, _ := s.Entry(DefaultNamespace, "a")
+
+	// the status sub resource does not change the spec
+	e.Spec.DNSNames = []string{"other"}
+	e.Status.State = "Ok"
+	n, err := s.UpdateEntryStatus(ctx, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status.State != "Ok" || n.Spec.DNSNames[0] != "a" || n.ResourceVersion != "2" {
+		t.Errorf("unexpected status update result %#v", n)
+	}
+	if len(updated) != 1 {
+		t.Errorf("status handler not called")
+	}
+
+	// outdated resource version
+	if _, err := s.UpdateEntryStatus(ctx, e); !apierrors.IsConflict(err) {
+		t.Errorf("expected conflict, got %v", err)
+	}
+	// unchanged status
+	if n, err = s.UpdateEntryStatus(ctx, n); err != nil || n.ResourceVersion != "2" || len(updated) != 1 {
+		t.Errorf("unchanged status must not be updated: %v", err)
+	}
+
+	// updates keep the status
+	s.Put(entry("a", nil, "a", "b"))
+	n, _ = s.Entry(DefaultNamespace, "a")
+	if n.Status.State != "Ok" {
+		t.Errorf("status lost on update")
+	}
+
+	if _, err := s.UpdateEntryStatus(ctx, entry("b", nil)); !apierrors.IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestStoreInformer(t *testing.T) {
+	s := NewStore(nil)
+	s.Put(entry("a", nil, "a"))
+
+	store, informer := cache.NewInformerWithOptions(cache.InformerOptions{
+		ListerWatcher: &cache.ListWatch{
+			ListWithContextFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+				return s.ListEntries(ctx, "", opts)
+			},
+			WatchFuncWithContext: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+				return s.WatchEntries(ctx, "", opts)
+			},
+		},
+		ObjectType: &api.CoreDNSEntry{},
+		Handler:    cache.ResourceEventHandlerFuncs{},
+	})
+	stop := make(chan struct{})
+	defer close(stop)
+	go informer.Run(stop)
+	if !cache.WaitForCacheSync(stop, informer.HasSynced) {
+		t.Fatal("not synced")
+	}
+
+	s.Put(entry("b", nil, "b"))
+	s.Delete(entry("a", nil))
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		keys := store.ListKeys()
+		if len(keys) == 1 && keys[0] == DefaultNamespace+"/b" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("informer not updated: %v", store.ListKeys())
+}
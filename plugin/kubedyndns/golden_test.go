@@ -0,0 +1,134 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns_test
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/testenv"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+type goldenQuery struct {
+	name  string
+	qtype uint16
+}
+
+// goldenTest runs a plugin instance for the objects of a test
+// data folder and compares the answers with its golden files.
+func goldenTest(t *testing.T, dir, stanza string, queries []goldenQuery) {
+	t.Helper()
+	env, err := testenv.New(stanza)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Load(filepath.Join("testdata", "golden", dir, "objects.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer env.Stop()
+
+	for _, q := range queries {
+		name := fmt.Sprintf("%s.%s", strings.TrimSuffix(strings.ReplaceAll(q.name, "*", "_"), "."), strings.ToLower(dns.TypeToString[q.qtype]))
+		t.Run(name, func(t *testing.T) {
+			m, err := env.Query(q.name, q.qtype)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := testenv.Golden(filepath.Join("testdata", "golden", dir, name+".golden"), m, *update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestGoldenFilterByZones(t *testing.T) {
+	goldenTest(t, "filter", `kubedyndns example.org 2.0.192.in-addr.arpa {
+	mode FilterByZones
+}`, []goldenQuery{
+		{"www.example.org", dns.TypeA},
+		{"www.example.org", dns.TypeAAAA},
+		{"www.example.org", dns.TypeTXT},
+		{"www.example.org", dns.TypeMX},
+		{"_dns._udp.dns.example.org", dns.TypeSRV},
+		{"alias.example.org", dns.TypeA},
+		{"alias.example.org", dns.TypeCNAME},
+		{"host.wild.example.org", dns.TypeA},
+		{"host.sub.example.org", dns.TypeA},
+		{"missing.example.org", dns.TypeA},
+		{"example.org", dns.TypeSOA},
+		{"example.org", dns.TypeNS},
+		{"1.2.0.192.in-addr.arpa", dns.TypePTR},
+		{"5.2.0.192.in-addr.arpa", dns.TypePTR},
+	})
+}
+
+func TestGoldenSubdomains(t *testing.T) {
+	goldenTest(t, "subdomains", `kubedyndns example.org 2.0.192.in-addr.arpa {
+	mode Subdomains
+}`, []goldenQuery{
+		{"www.example.org", dns.TypeA},
+		{"www.example.org", dns.TypeAAAA},
+		{"www.example.org", dns.TypeTXT},
+		{"_dns._udp.dns.example.org", dns.TypeSRV},
+		{"alias.example.org", dns.TypeA},
+		{"host.wild.example.org", dns.TypeA},
+		{"host.sub.example.org", dns.TypeA},
+		{"missing.example.org", dns.TypeA},
+		{"example.org", dns.TypeSOA},
+		{"1.2.0.192.in-addr.arpa", dns.TypePTR},
+		{"5.2.0.192.in-addr.arpa", dns.TypePTR},
+	})
+}
+
+func TestGoldenPrimary(t *testing.T) {
+	goldenTest(t, "primary", `kubedyndns . 2.0.192.in-addr.arpa {
+	mode Primary
+	zoneobject test
+	namespaces default
+	transitive
+}`, []goldenQuery{
+		{"www.example.org", dns.TypeA},
+		{"www.example.org", dns.TypeAAAA},
+		{"www.example.org", dns.TypeTXT},
+		{"www.example.org", dns.TypeMX},
+		{"example.org", dns.TypeA},
+		{"example.org", dns.TypeSOA},
+		{"example.org", dns.TypeNS},
+		{"_dns._udp.dns.example.org", dns.TypeSRV},
+		{"alias.example.org", dns.TypeA},
+		{"host.wild.example.org", dns.TypeA},
+		{"host.sub.example.org", dns.TypeA},
+		{"missing.example.org", dns.TypeA},
+		{"host.nested.example.org", dns.TypeA},
+		{"missing.nested.example.org", dns.TypeA},
+		{"nested.example.org", dns.TypeSOA},
+		{"1.2.0.192.in-addr.arpa", dns.TypePTR},
+		{"3.2.0.192.in-addr.arpa", dns.TypePTR},
+	})
+}
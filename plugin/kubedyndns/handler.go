@@ -20,6 +20,7 @@ package kubedyndns
 
 import (
 	"context"
+	"net"
 	"slices"
 
 	"github.com/coredns/coredns/plugin"
@@ -51,35 +52,6 @@ func (k *KubeDynDNS) ServeDNS(ctx context.Context, w dns.ResponseWriter, in *dns
 	state := request.Request{W: w, Req: in}
 
 	qname := state.QName()
-	zone := plugin.Zones(k.Zones).Matches(qname)
-	if zone == "" {
-		return plugin.NextOrFailure(k.Name(), k.Next, ctx, w, in)
-	}
-	Log.Infof("serve %q", qname)
-
-	var zo *objects.Zone
-	if k.zoneRef != nil {
-		zo = k.APIConn.GetZone(*k.zoneRef)
-	}
-
-	var zones []string
-	if zone == "." {
-		zones = zo.DomainNames
-	} else {
-		for _, z := range zo.DomainNames {
-			zones = append(zones, z+zone)
-		}
-	}
-
-	if zo != nil {
-		zone = plugin.Zones(zones).Matches(qname)
-	}
-	if zone == "" {
-		return plugin.NextOrFailure(k.Name(), k.Next, ctx, w, in)
-	}
-
-	zone = qname[len(qname)-len(zone):] // maintain case of original query
-	state.Zone = zone
 
 	var (
 		records []dns.RR
@@ -88,48 +60,34 @@ func (k *KubeDynDNS) ServeDNS(ctx context.Context, w dns.ResponseWriter, in *dns
 		err     error
 	)
 
-	zi := NewZoneInfo(zone, zo)
+	zi := k.zoneFor(qname)
+	if zi == nil {
+		return plugin.NextOrFailure(k.Name(), k.Next, ctx, w, in)
+	}
+	Log.Infof("serve %q", qname)
 
-	dz, rs, zn := k.findZone(zi, qname)
-	if rs != nil {
-		for _, r := range rs {
-			for _, s := range r.NS {
-				auth = append(auth, k.NS(s, qname, r.Ttl)...)
-			}
-			if len(auth) == 0 {
-				auth = append(auth, k.NS("ns."+qname, qname, r.Ttl)...)
-			}
-		}
-	} else {
-		if dz != nil {
-			if zi != dz {
-				if k.transitive && !(state.QType() == dns.TypeNS && qname == zn) {
-					zi = dz
-					state.Zone = zn
-				} else {
-					for _, s := range dz.Object.Status.NameServers {
-						auth = append(auth, k.NS(s, qname, uint32(dz.Object.MinimumTTL))...)
-					}
-					if len(auth) == 0 {
-						auth = append(auth, k.NS("ns."+qname, qname, uint32(dz.Object.MinimumTTL))...)
-					}
-				}
-			}
-		}
-		if len(auth) == 0 {
-			// we need the ZoneInfo in the Records method, but it cannot be passed
-			// through the intermediate coredns calls.
-			// therefore we create a delegate containing this information per request
-			// which implements the required plugin.ServiceBackend interface in combination
-			// with the general methods of the KubeDynDNS object.
-			be := &Backend{zoneInfo: zi, KubeDynDNS: k}
-			records, extra, err = be.Handle(ctx, state)
-		} else {
-			if state.QType() == dns.TypeNS {
-				records = auth
-				auth = nil
-			}
-		}
+	zi, l := k.findZone(zi, qname)
+	state.Zone = zi.DomainName
+
+	if !k.allowed(zi, state) {
+		return k.refused(ctx, zi, state)
+	}
+	if _, _, err := requestCookie(state); err != nil {
+		return k.formatError(ctx, zi, state)
+	}
+
+	switch {
+	case l.Cut != nil:
+		// referral to a delegated or (non-transitively) nested zone.
+		auth, extra = k.referral(zi, l)
+	default:
+		// we need the ZoneInfo in the Records method, but it cannot be passed
+		// through the intermediate coredns calls.
+		// therefore we create a delegate containing this information per request
+		// which implements the required plugin.ServiceBackend interface in combination
+		// with the general methods of the KubeDynDNS object.
+		be := &Backend{zoneInfo: zi, lookup: l, KubeDynDNS: k}
+		records, extra, err = be.Handle(ctx, state)
 	}
 
 	if k.IsNameError(err) {
@@ -140,7 +98,7 @@ func (k *KubeDynDNS) ServeDNS(ctx context.Context, w dns.ResponseWriter, in *dns
 			// If we haven't synchronized with the kubernetes cluster, return server failure
 			return k.BackendError(ctx, zi, dns.RcodeServerFailure, state, nil)
 		}
-		return k.BackendError(ctx, zi, dns.RcodeNameError, state, nil)
+		return k.nameError(ctx, zi, state, records)
 	}
 	if err != nil {
 		return dns.RcodeServerFailure, err
@@ -148,107 +106,286 @@ func (k *KubeDynDNS) ServeDNS(ctx context.Context, w dns.ResponseWriter, in *dns
 
 	m := new(dns.Msg)
 	m.SetReply(in)
-	m.Authoritative = true
+	m.Authoritative = len(auth) == 0
 	m.Answer = append(m.Answer, records...)
 	m.Extra = append(m.Extra, extra...)
 	if len(m.Answer) == 0 {
 		if len(auth) > 0 {
 			m.Ns = auth
 		} else {
-			m.Ns = k.SOA(ctx, zi, state)
+			m.Ns = k.NegativeSOA(ctx, zi, state)
 		}
 	}
 
-	w.WriteMsg(m)
+	k.writeMsg(ctx, zi, state, m)
+	return dns.RcodeSuccess, nil
+}
+
+// nameError answers with NXDOMAIN. The answer keeps the CNAME records
+// of a chain ending at a non-existing name (RFC 6604).
+func (k *KubeDynDNS) nameError(ctx context.Context, zi *ZoneInfo, state request.Request, records []dns.RR) (int, error) {
+	m := new(dns.Msg)
+	m.SetRcode(state.Req, dns.RcodeNameError)
+	m.Authoritative = true
+	m.Answer = records
+	m.Ns = k.NegativeSOA(ctx, zi, state)
+
+	k.writeMsg(ctx, zi, state, m)
 	return dns.RcodeSuccess, nil
 }
 
-func (k *KubeDynDNS) findZone(zi *ZoneInfo, qname string) (*ZoneInfo, []*objects.Entry, string) {
-	qname = dns.Fqdn(qname)
-	zn := zi.DomainName
-	sub := qname[:len(qname)-len(zn)]
-	labels := dns.SplitDomainName(sub)
-	cur := zn
-	rel := "."
-	for i := range labels {
-		l := labels[len(labels)-1-i]
-		cur = dnsutil.Join(l, cur)
-		rel = dnsutil.Join(l, rel)
-		Log.Infof("lookup nested zone for %s/%s\n", cur, rel)
-		var ns []*objects.Entry
-		for _, e := range k.APIConn.EntryDNSIndex(rel) {
-			if zi.Match(e.ZoneRef, e) {
-				if len(e.NS) != 0 {
-					ns = append(ns, e)
-					zn = cur
-					break
+// zoneFor determines the forward or reverse zone served by this
+// plugin instance for a domain name. It returns nil, if the name is not served.
+// In Primary mode the domain names of the root zone object take precedence,
+// because they may describe a reverse zone, also.
+func (k *KubeDynDNS) zoneFor(qname string) *ZoneInfo {
+	if k.zoneRef != nil {
+		if zi := k.servedZone(qname); zi != nil {
+			return zi
+		}
+	}
+	if zi := k.reverseZone(qname); zi != nil {
+		return zi
+	}
+	return k.servedZone(qname)
+}
+
+// servedZone determines the root zone served by this plugin instance
+// for a domain name. It returns nil, if the name is not served.
+func (k *KubeDynDNS) servedZone(qname string) *ZoneInfo {
+	zone := plugin.Zones(k.ServedZones).Matches(qname)
+	if zone == "" {
+		return nil
+	}
+
+	var zo *objects.Zone
+	if k.zoneRef != nil {
+		zo = k.APIConn.GetZone(*k.zoneRef)
+		if zo == nil {
+			return nil
+		}
+		zone = plugin.Zones(k.rootZones(zo, zone)).Matches(qname)
+		if zone == "" {
+			return nil
+		}
+	}
+	zone = qname[len(qname)-len(zone):] // maintain case of original query
+	return NewZoneInfo(zone, zo)
+}
+
+// servedZones caches the domain names served for a root zone object
+// for all zones of the plugin.
+type servedZones struct {
+	zone  *objects.Zone
+	names map[string][]string
+}
+
+// rootZones returns the domain names served for the root zone object
+// below the given plugin zone. They are recalculated only if the
+// zone object changes.
+func (k *KubeDynDNS) rootZones(zo *objects.Zone, zone string) []string {
+	s := k.served.Load()
+	if s == nil || s.zone != zo {
+		s = &servedZones{zone: zo, names: map[string][]string{}}
+		for _, z := range k.Zones {
+			if z == "." {
+				s.names[z] = zo.DomainNames
+			} else {
+				for _, n := range zo.DomainNames {
+					s.names[z] = append(s.names[z], n+z)
 				}
 			}
 		}
-		if ns != nil {
-			Log.Infof("found delegated zone for %s: %s<%s>\n", cur, ns[0].Name, rel)
-			return nil, ns, cur
+		k.served.Store(s)
+	}
+	return s.names[zone]
+}
+
+// findZone determines the zone responsible for qname and looks up
+// the name in the name tree of this zone. In transitive mode nested zones
+// are served by this server, also, and therefore followed.
+func (k *KubeDynDNS) findZone(zi *ZoneInfo, qname string) (*ZoneInfo, *NameLookup) {
+	for {
+		l := k.APIConn.NameTree(zi).Lookup(relativeName(qname, zi.DomainName))
+		if l.Cut == nil || l.Cut.Zone == nil || !k.transitive {
+			return zi, l
 		}
-		for _, e := range k.APIConn.ZoneDomainIndex(rel) {
-			if zi.Match(e.ParentRef, e) {
-				Log.Infof("found nested zone for %s: %s<%s>\n", cur, e.Name, rel)
-				zn = cur
-				rel = "."
-				zi = NewZoneInfo(zn, e)
-				if !k.transitive {
-					return zi, nil, zi.DomainName
-				}
-				break
+		Log.Infof("found nested zone for %s: %s<%s>", qname, l.Cut.Zone.Name, l.CutName)
+		zi = NewZoneInfo(l.CutFqdn(zi.DomainName), l.Cut.Zone)
+	}
+}
+
+// relativeName returns the domain name relative to the given zone.
+func relativeName(name, zone string) string {
+	return dns.Fqdn(name)[:len(dns.Fqdn(name))-len(zone)]
+}
+
+// referral provides the NS records and the glue records for a
+// delegation point in the name tree of a served zone.
+func (k *KubeDynDNS) referral(zi *ZoneInfo, l *NameLookup) (auth []dns.RR, extra []dns.RR) {
+	cut := l.Cut
+	owner := l.CutFqdn(zi.DomainName)
+	if cut.Zone != nil {
+		Log.Infof("found nested zone %s<%s>", cut.Zone.Name, owner)
+		auth = k.zoneNS(NewZoneInfo(owner, cut.Zone))
+	} else {
+		for _, e := range cut.Delegations() {
+			Log.Infof("found delegated zone %s<%s>", e.Name, owner)
+			for _, s := range e.NS {
+				auth = append(auth, k.NS(absoluteName(s, zi.DomainName), owner, e.Ttl)...)
 			}
 		}
 	}
+	return auth, k.glue(zi, auth)
+}
 
-	return zi, nil, zi.DomainName
+// zoneNS provides the NS records for the apex of a zone object.
+func (k *KubeDynDNS) zoneNS(zi *ZoneInfo) []dns.RR {
+	var rrs []dns.RR
+	ttl := uint32(zi.Object.MinimumTTL)
+	for _, s := range zoneNameServers(zi) {
+		rrs = append(rrs, k.NS(s, zi.DomainName, ttl)...)
+	}
+	return rrs
 }
 
-func (k *KubeDynDNS) SOA(ctx context.Context, zi *ZoneInfo, state request.Request) []dns.RR {
-	if zi != nil {
-		ttl := uint32(min(zi.Object.MinimumTTL, 300))
-		header := dns.RR_Header{Name: zi.DomainName, Rrtype: dns.TypeSOA, Ttl: ttl, Class: dns.ClassINET}
+// zoneNameServers returns the name servers of a zone object
+// for the given apex. The first one is used as MNAME of the SOA record.
+// Without name servers in the status, the default name server
+// of the apex is used.
+func zoneNameServers(zi *ZoneInfo) []string {
+	var names []string
+	for _, s := range zi.Object.Status.NameServers {
+		names = append(names, dns.Fqdn(s))
+	}
+	if len(names) == 0 {
+		names = []string{defaultNSName + zi.DomainName}
+	}
+	return names
+}
 
-		nsrv := dnsutil.Join("ns.dns", zi.DomainName)
-		if len(zi.Object.Status.NameServers) > 0 {
-			nsrv = dns.Fqdn(zi.Object.Status.NameServers[0])
+// glue provides the address records for name servers
+// located in the given zone.
+func (k *KubeDynDNS) glue(zi *ZoneInfo, nss []dns.RR) []dns.RR {
+	var extra []dns.RR
+
+	tree := k.APIConn.NameTree(zi)
+	for _, rr := range nss {
+		ns, ok := rr.(*dns.NS)
+		if !ok || !dns.IsSubDomain(zi.DomainName, ns.Ns) {
+			continue
+		}
+		n := tree.Find(relativeName(ns.Ns, zi.DomainName))
+		if n == nil {
+			continue
+		}
+		for _, e := range n.Entries {
+			hdr := dns.RR_Header{Name: ns.Ns, Class: dns.ClassINET, Ttl: k.TTL(e.Ttl)}
+			for _, a := range e.A {
+				hdr.Rrtype = dns.TypeA
+				extra = append(extra, &dns.A{Hdr: hdr, A: net.ParseIP(a)})
+			}
+			for _, a := range e.AAAA {
+				hdr.Rrtype = dns.TypeAAAA
+				extra = append(extra, &dns.AAAA{Hdr: hdr, AAAA: net.ParseIP(a)})
+			}
 		}
+	}
+	return extra
+}
+
+// absoluteName completes a relative domain name with the given zone.
+func absoluteName(name, zone string) string {
+	if dns.IsFqdn(name) {
+		return name
+	}
+	return dnsutil.Join(name, zone)
+}
+
+// SOA provides the SOA record of a zone.
+// The TTL of the record is the TTL of the zone, the MINIMUM field is
+// the negative caching TTL according to RFC 2308.
+func (k *KubeDynDNS) SOA(ctx context.Context, zi *ZoneInfo, state request.Request) []dns.RR {
+	header := dns.RR_Header{Name: zi.DomainName, Rrtype: dns.TypeSOA, Class: dns.ClassINET}
+	if zi.Object != nil {
+		header.Ttl = k.TTL(uint32(zi.Object.MinimumTTL))
 		mbox := dnsutil.Join("hostmaster", zi.DomainName)
 		if zi.Object.EMail != "" {
 			mbox = zi.Object.EMail
 		}
-		soa := &dns.SOA{Hdr: header,
+		return []dns.RR{&dns.SOA{Hdr: header,
 			Mbox:    mbox,
-			Ns:      nsrv,
+			Ns:      zoneNameServers(zi)[0],
 			Serial:  k.Serial(state),
 			Refresh: uint32(zi.Object.Refresh),
 			Retry:   uint32(zi.Object.Retry),
 			Expire:  uint32(zi.Object.Expire),
-			Minttl:  ttl,
-		}
-		return []dns.RR{soa}
-	} else {
-		minTTL := k.MinTTL(state)
-		ttl := min(minTTL, uint32(300))
+			Minttl:  k.negativeTTL(zi, state),
+		}}
+	}
 
-		header := dns.RR_Header{Name: zi.DomainName, Rrtype: dns.TypeSOA, Ttl: ttl, Class: dns.ClassINET}
+	header.Ttl = k.TTL(0)
+	return []dns.RR{&dns.SOA{Hdr: header,
+		Mbox:    absoluteName(k.soa.mbox, zi.DomainName),
+		Ns:      k.defaultNameServers(zi)[0],
+		Serial:  k.Serial(state),
+		Refresh: k.soa.refresh,
+		Retry:   k.soa.retry,
+		Expire:  k.soa.expire,
+		Minttl:  k.negativeTTL(zi, state),
+	}}
+}
 
-		Mbox := dnsutil.Join("hostmaster", zi.DomainName)
-		Ns := dnsutil.Join("ns.dns", zi.DomainName)
+// negativeTTL determines the negative caching TTL of a zone.
+// For zone objects it is the explicitly configured negative TTL
+// or the minimum TTL of the zone. Otherwise, the minttl of the
+// soa option or the TTL of the plugin is used.
+// It is limited to maxNegativeTTL.
+func (k *KubeDynDNS) negativeTTL(zi *ZoneInfo, state request.Request) uint32 {
+	var ttl uint32
+	switch {
+	case zi.Object != nil && zi.Object.NegativeTTL != nil:
+		ttl = uint32(*zi.Object.NegativeTTL)
+	case zi.Object != nil:
+		ttl = uint32(zi.Object.MinimumTTL)
+	case k.soa.minttl > 0:
+		ttl = k.soa.minttl
+	default:
+		ttl = k.MinTTL(state)
+	}
+	return min(ttl, maxNegativeTTL)
+}
 
-		soa := &dns.SOA{Hdr: header,
-			Mbox:    Mbox,
-			Ns:      Ns,
-			Serial:  k.Serial(state),
-			Refresh: 7200,
-			Retry:   1800,
-			Expire:  86400,
-			Minttl:  minTTL,
+// NegativeSOA provides the SOA record for the authority section
+// of NXDOMAIN and NODATA responses. According to RFC 2308, section 3
+// its TTL is the minimum of the TTL of the SOA record and its MINIMUM field.
+func (k *KubeDynDNS) NegativeSOA(ctx context.Context, zi *ZoneInfo, state request.Request) []dns.RR {
+	rrs := k.SOA(ctx, zi, state)
+	for _, rr := range rrs {
+		soa := rr.(*dns.SOA)
+		soa.Hdr.Ttl = min(soa.Hdr.Ttl, soa.Minttl)
+	}
+	return rrs
+}
+
+// defaultNameServers returns the configured name servers for
+// a zone without zone object. The first one is used as MNAME of the
+// SOA record, if not configured explicitly.
+func (k *KubeDynDNS) defaultNameServers(zi *ZoneInfo) []string {
+	var names []string
+	if k.soa.ns != "" {
+		names = append(names, absoluteName(k.soa.ns, zi.DomainName))
+	}
+	for _, n := range k.nameServers {
+		n = absoluteName(n, zi.DomainName)
+		if !slices.Contains(names, n) {
+			names = append(names, n)
 		}
-		return []dns.RR{soa}
 	}
+	if len(names) == 0 {
+		names = []string{defaultNSName + zi.DomainName}
+	}
+	return names
 }
 
 func (k *KubeDynDNS) NS(nsrv, name string, ttl uint32) []dns.RR {
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+// staticController provides a fixed modification timestamp.
+// Other methods must not be used.
+type staticController struct {
+	Controller
+}
+
+func (c *staticController) Modified() int64 {
+	return 4711
+}
+
+func zoneObject(minimum int, negative *int) *objects.Zone {
+	return &objects.Zone{
+		HostedZoneSpec: &api.HostedZoneSpec{
+			EMail:       "admin.example.org.",
+			Refresh:     7200,
+			Retry:       1800,
+			Expire:      86400,
+			MinimumTTL:  minimum,
+			NegativeTTL: negative,
+		},
+		Status: &api.HostedZoneStatus{},
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestSOATTL(t *testing.T) {
+	tests := []struct {
+		name   string
+		ttl    uint32
+		minttl uint32
+		zone   *objects.Zone
+		// soa is the TTL of the SOA record, minimum its MINIMUM field
+		// and negative the TTL of the SOA record for negative answers.
+		soa, minimum, negative uint32
+	}{
+		{name: "plugin TTL", ttl: 10, soa: 10, minimum: 10, negative: 10},
+		{name: "soa minttl below TTL", ttl: 300, minttl: 60, soa: 300, minimum: 60, negative: 60},
+		{name: "soa minttl above TTL", ttl: 30, minttl: 3600, soa: 30, minimum: 3600, negative: 30},
+		{name: "soa minttl capped", ttl: 3600, minttl: 86400, soa: 3600, minimum: maxNegativeTTL, negative: 3600},
+
+		{name: "zone minimum TTL", ttl: 10, zone: zoneObject(3600, nil), soa: 3600, minimum: 3600, negative: 3600},
+		{name: "zone negative TTL below minimum TTL", ttl: 10, zone: zoneObject(3600, intPtr(300)), soa: 3600, minimum: 300, negative: 300},
+		{name: "zone negative TTL above minimum TTL", ttl: 10, zone: zoneObject(600, intPtr(7200)), soa: 600, minimum: 7200, negative: 600},
+		{name: "zone negative TTL zero", ttl: 10, zone: zoneObject(600, intPtr(0)), soa: 600, minimum: 0, negative: 0},
+		{name: "zone minimum TTL capped", ttl: 10, zone: zoneObject(86400, nil), soa: 86400, minimum: maxNegativeTTL, negative: maxNegativeTTL},
+		{name: "zone negative TTL capped", ttl: 10, zone: zoneObject(86400, intPtr(86400)), soa: 86400, minimum: maxNegativeTTL, negative: maxNegativeTTL},
+	}
+
+	state := request.Request{W: &test.ResponseWriter{}, Req: new(dns.Msg)}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			k := New([]string{"example.org."})
+			k.APIConn = &staticController{}
+			k.ttl = tc.ttl
+			k.soa.minttl = tc.minttl
+			zi := NewZoneInfo("example.org.", tc.zone)
+
+			soa := k.SOA(context.Background(), zi, state)[0].(*dns.SOA)
+			if soa.Hdr.Ttl != tc.soa || soa.Minttl != tc.minimum {
+				t.Errorf("SOA TTL %d, MINIMUM %d, expected %d, %d", soa.Hdr.Ttl, soa.Minttl, tc.soa, tc.minimum)
+			}
+			if soa.Serial != 4711 {
+				t.Errorf("serial %d", soa.Serial)
+			}
+
+			neg := k.NegativeSOA(context.Background(), zi, state)[0].(*dns.SOA)
+			if neg.Hdr.Ttl != tc.negative || neg.Minttl != tc.minimum {
+				t.Errorf("negative SOA TTL %d, MINIMUM %d, expected %d, %d", neg.Hdr.Ttl, neg.Minttl, tc.negative, tc.minimum)
+			}
+		})
+	}
+}
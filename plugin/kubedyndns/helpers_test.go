@@ -0,0 +1,80 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns_test
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/filesource"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/testenv"
+)
+
+// newEntry provides an entry in the default namespace.
+func newEntry(name string, spec api.CoreDNSSpec) *api.CoreDNSEntry {
+	return &api.CoreDNSEntry{
+		ObjectMeta: metav1.ObjectMeta{Namespace: filesource.DefaultNamespace, Name: name},
+		Spec:       spec,
+	}
+}
+
+// start runs a plugin instance for the given stanza and objects,
+// which is stopped at the end of the test.
+func start(t *testing.T, stanza string, objs ...*api.CoreDNSEntry) *testenv.Environment {
+	t.Helper()
+	env, err := testenv.New(stanza)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range objs {
+		if err := env.Apply(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := env.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { env.Stop() })
+	return env
+}
+
+// exchange sends a request from the given client address,
+// the default address is used for an empty one.
+func exchange(t *testing.T, env *testenv.Environment, m *dns.Msg, client string, tcp bool) *dns.Msg {
+	t.Helper()
+	resp, err := env.Exchange(m, &test.ResponseWriter{RemoteIP: client, TCP: tcp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// check runs test cases for the default client address.
+func check(t *testing.T, env *testenv.Environment, cases ...test.Case) {
+	t.Helper()
+	for _, tc := range cases {
+		if err := env.Check(tc); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync/atomic"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/fall"
@@ -42,6 +43,9 @@ import (
 	"k8s.io/client-go/util/cert"
 
 	clientapi "github.com/mandelsoft/kubedyndns/client/clientset/versioned"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/clustersource"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/filesource"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
 )
 
 const MODE_FILTER = "FilterByZones"
@@ -55,10 +59,34 @@ type K8SConfig struct {
 	APIClientCert string
 	APIClientKey  string
 	ClientConfig  clientcmd.ClientConfig
-	Namespaces    sets.Set[string]
-	// Label handling.
-	labelSelector *meta.LabelSelector
-	selector      labels.Selector
+	// Directory and StatusFile configure the usage of manifest
+	// files instead of a cluster.
+	Directory  string
+	StatusFile string
+	// Clusters are the aggregated clusters and ConflictPolicy
+	// the handling of entries of different clusters for the same
+	// domain names.
+	Clusters       []ClusterConfig
+	ConflictPolicy string
+	// kubeconfig and context describe the configured client config.
+	kubeconfig string
+	context    string
+	// Label handling. The entry and zone selectors default
+	// to labelSelector.
+	labelSelector          *meta.LabelSelector
+	entryLabelSelector     *meta.LabelSelector
+	zoneLabelSelector      *meta.LabelSelector
+	namespaceLabelSelector *meta.LabelSelector
+}
+
+// ClusterConfig describes an aggregated cluster. Without client
+// config the in-cluster config is used.
+type ClusterConfig struct {
+	Name         string
+	ClientConfig clientcmd.ClientConfig
+
+	kubeconfig string
+	context    string
 }
 
 // KubeDynDNS implements a plugin that connects to a Kubernetes cluster.
@@ -67,13 +95,74 @@ type KubeDynDNS struct {
 	Mode        string
 	Zones       []string
 	ServedZones []string
-	Upstream    *upstream.Upstream
-	APIConn     Controller
-	Fall        fall.F
-	ttl         uint32
-	k8s         *K8SConfig
+	// ReverseZones are the served in-addr.arpa and ip6.arpa zones
+	// providing PTR records for the addresses of the entries.
+	ReverseZones []string
+	Upstream     *upstream.Upstream
+	APIConn      Controller
+	Fall         fall.F
+	ttl          uint32
+	k8s          *K8SConfig
 	controlOpts
 	localIPs []net.IP
+	served   atomic.Pointer[servedZones]
+
+	// upstream enables the resolution of CNAME and ALIAS targets
+	// not served by this plugin instance.
+	upstream bool
+	aliases  *aliasCache
+
+	// soa and nameServers describe the zone data used
+	// for zones without zone object.
+	soa         soaConfig
+	nameServers []string
+
+	// queryACL and transferACL are the default access control
+	// lists for zones without own ACLs.
+	queryACL    *objects.ACL
+	transferACL *objects.ACL
+
+	// anyMode is the handling of ANY requests, full answers
+	// are always provided for anyTrusted clients.
+	anyMode    string
+	anyTrusted *objects.ACL
+
+	// order is the default ordering of address records,
+	// rotation is the counter for ORDER_ROTATE.
+	order    string
+	rotation atomic.Uint64
+
+	// nsid is the name server identifier (RFC 5001) of this replica.
+	nsid string
+	// cookieSecret enables DNS cookies (RFC 7873) if set.
+	cookieSecret []byte
+
+	// exportAddr is the address of the HTTP server providing
+	// the served zones as master files.
+	exportAddr string
+
+	// rrl is the default response rate limiting.
+	rrl     rateLimit
+	limiter *rateLimiter
+}
+
+// soaConfig describes the SOA record for zones without zone object.
+// Relative names are completed with the served zone.
+type soaConfig struct {
+	mbox    string
+	ns      string
+	refresh uint32
+	retry   uint32
+	expire  uint32
+	// minttl is the negative caching TTL, 0 means the TTL of the plugin.
+	minttl uint32
+}
+
+var defaultSOA = soaConfig{
+	mbox:    "hostmaster",
+	refresh: 7200,
+	retry:   1800,
+	expire:  86400,
 }
 
 // New returns a initialized Kubernetes. It default interfaceAddrFunc to return 127.0.0.1. All other
@@ -84,14 +173,17 @@ func New(zones []string) *KubeDynDNS {
 	k.ttl = defaultTTL
 	k.Mode = MODE_FILTER
 	k.namespaces = sets.New[string]()
+	k.aliases = newAliasCache()
+	k.soa = defaultSOA
+	k.anyMode = ANY_FULL
+	k.rrl.slip = defaultSlip
+	k.limiter = newRateLimiter()
 	return k
 }
 
 func (k *KubeDynDNS) assureK8SConfig() *K8SConfig {
 	if k.k8s == nil {
-		k.k8s = &K8SConfig{
-			Namespaces: sets.New[string](),
-		}
+		k.k8s = &K8SConfig{}
 	}
 	return k.k8s
 }
@@ -101,6 +193,8 @@ const (
 	DNSSchemaVersion = "1.1.0"
 	// defaultTTL to apply to all answers.
 	defaultTTL = 10
+	// maxNegativeTTL limits the negative caching TTL (RFC 2308, section 5).
+	maxNegativeTTL = 10800
 )
 
 var (
@@ -174,37 +268,168 @@ func (k *K8SConfig) getClientConfig() (*rest.Config, error) {
 
 }
 
+// hasConnection reports whether the config describes the access
+// to the objects. Otherwise, the in-cluster config is used.
+func (k *K8SConfig) hasConnection() bool {
+	return k != nil && (len(k.APIServerList) > 0 || k.APIToken != "" || k.APICertAuth != "" ||
+		k.APIClientCert != "" || k.ClientConfig != nil || k.Directory != "" || len(k.Clusters) > 0)
+}
+
+// inheritConnection takes over the access to the objects from another config.
+func (k *K8SConfig) inheritConnection(o *K8SConfig) {
+	k.APIServerList = o.APIServerList
+	k.APIToken = o.APIToken
+	k.APICertAuth = o.APICertAuth
+	k.APIClientCert = o.APIClientCert
+	k.APIClientKey = o.APIClientKey
+	k.ClientConfig = o.ClientConfig
+	k.kubeconfig = o.kubeconfig
+	k.context = o.context
+	k.Directory = o.Directory
+	k.StatusFile = o.StatusFile
+	k.Clusters = o.Clusters
+	k.ConflictPolicy = o.ConflictPolicy
+}
+
+// connectionKey identifies the access to the objects. Configs with
+// the same key can share a data source.
+func (k *K8SConfig) connectionKey() string {
+	if !k.hasConnection() {
+		return ""
+	}
+	key := []string{
+		strings.Join(k.APIServerList, ","), k.APIToken, k.APICertAuth, k.APIClientCert, k.APIClientKey,
+		k.kubeconfig, k.context, k.Directory, k.StatusFile, k.ConflictPolicy,
+	}
+	if k.ClientConfig != nil && k.kubeconfig == "" {
+		key = append(key, fmt.Sprintf("%p", k.ClientConfig))
+	}
+	for _, c := range k.Clusters {
+		key = append(key, c.Name, c.kubeconfig, c.context)
+	}
+	return fmt.Sprintf("%q", key)
+}
+
+// controllerKey identifies the controller required by a plugin instance.
+// Instances with the same key can share a controller.
+func (k *KubeDynDNS) controllerKey() string {
+	var selectors []string
+	if k.k8s != nil {
+		for _, ls := range []*meta.LabelSelector{k.k8s.labelSelector, k.k8s.entryLabelSelector, k.k8s.zoneLabelSelector, k.k8s.namespaceLabelSelector} {
+			selectors = append(selectors, meta.FormatLabelSelector(ls))
+		}
+	}
+	zoneRef := ""
+	if k.zoneRef != nil {
+		zoneRef = k.zoneRef.String()
+	}
+	return fmt.Sprintf("%s|%q|%s|%t|%t|%t|%q|%s", k.k8s.connectionKey(), selectors,
+		k.zoneObject, k.transitive, k.slave, k.filtered, sets.List(k.namespaces), zoneRef)
+}
+
 // InitKubeCache initializes a new Kubernetes cache.
+// If a directory is configured, the objects are read from the
+// manifest files of this directory instead of a cluster.
 func (k *KubeDynDNS) InitKubeCache(ctx context.Context) (err error) {
-	config, err := k.k8s.getClientConfig()
+	src, err := k.k8s.newDataSource()
 	if err != nil {
 		return err
 	}
+	return k.InitCache(ctx, src)
+}
+
+// newDataSource creates the data source for the configured access
+// to the objects.
+func (k *K8SConfig) newDataSource() (DataSource, error) {
+	if k != nil && k.Directory != "" {
+		src, err := filesource.New(k.Directory, k.StatusFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifests: %w", err)
+		}
+		return src, nil
+	}
+	if k != nil && len(k.Clusters) > 0 {
+		var clusters []clustersource.Cluster
+		for _, c := range k.Clusters {
+			var (
+				config *rest.Config
+				err    error
+			)
+			if c.ClientConfig != nil {
+				config, err = c.ClientConfig.ClientConfig()
+			} else {
+				config, err = rest.InClusterConfig()
+			}
+			if err != nil {
+				return nil, fmt.Errorf("cluster %s: %w", c.Name, err)
+			}
+			clusters = append(clusters, clustersource.Cluster{Name: c.Name, Config: config})
+		}
+		return clustersource.New(clusters, k.ConflictPolicy)
+	}
+
+	config, err := k.getClientConfig()
+	if err != nil {
+		return nil, err
+	}
 
 	kubeClient, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return fmt.Errorf("failed to create kubernetes notification controller: %q", err)
+		return nil, fmt.Errorf("failed to create kubernetes notification controller: %q", err)
 	}
 	apiClient, err := clientapi.NewForConfig(config)
 	if err != nil {
-		return fmt.Errorf("failed to create kubernetes notification controller: %q", err)
+		return nil, fmt.Errorf("failed to create kubernetes notification controller: %q", err)
 	}
+	return NewClusterSource(kubeClient, apiClient), nil
+}
 
-	if k.k8s.labelSelector != nil {
-		var selector labels.Selector
-		selector, err = meta.LabelSelectorAsSelector(k.k8s.labelSelector)
-		if err != nil {
-			return fmt.Errorf("unable to create Selector for LabelSelector '%s': %q", k.k8s.labelSelector, err)
+// InitKubeCacheForClients initializes the controller for the given clients.
+// It is used to run the plugin without real cluster access, for example
+// with fake clientsets.
+func (k *KubeDynDNS) InitKubeCacheForClients(ctx context.Context, kubeClient kubernetes.Interface, apiClient clientapi.Interface) (err error) {
+	return k.InitCache(ctx, NewClusterSource(kubeClient, apiClient))
+}
+
+// InitCache initializes the controller for the given data source.
+func (k *KubeDynDNS) InitCache(ctx context.Context, source DataSource) (err error) {
+	if k.k8s != nil {
+		entries, zones := k.k8s.entryLabelSelector, k.k8s.zoneLabelSelector
+		if entries == nil {
+			entries = k.k8s.labelSelector
+		}
+		if zones == nil {
+			zones = k.k8s.labelSelector
+		}
+		if k.entrySelector, err = asSelector(entries); err != nil {
+			return err
+		}
+		if k.zoneSelector, err = asSelector(zones); err != nil {
+			return err
+		}
+		if k.namespaceSelector, err = asSelector(k.k8s.namespaceLabelSelector); err != nil {
+			return err
 		}
-		k.k8s.selector = selector
 	}
 
 	Log.Infof("using mode %s: %v", k.Mode, k.ServedZones)
-	k.APIConn = newController(ctx, kubeClient, apiClient, k.controlOpts)
+	k.APIConn = newController(ctx, source, k.controlOpts)
 
 	return err
 }
 
+// asSelector converts an optional label selector.
+func asSelector(ls *meta.LabelSelector) (labels.Selector, error) {
+	if ls == nil {
+		return nil, nil
+	}
+	selector, err := meta.LabelSelectorAsSelector(ls)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Selector for LabelSelector '%s': %q", ls, err)
+	}
+	return selector, nil
+}
+
 func (k *KubeDynDNS) TTL(ttl uint32) uint32 {
 	if ttl > 0 {
 		return ttl
@@ -238,9 +463,9 @@ func (k *KubeDynDNS) BackendError(ctx context.Context, zi *ZoneInfo, rcode int,
 	m := new(dns.Msg)
 	m.SetRcode(state.Req, rcode)
 	m.Authoritative = true
-	m.Ns = k.SOA(ctx, zi, state)
+	m.Ns = k.NegativeSOA(ctx, zi, state)
 
-	state.W.WriteMsg(m)
+	k.writeMsg(ctx, zi, state, m)
 	// Return success as the rcode to signal we have written to the client.
 	return dns.RcodeSuccess, err
 }
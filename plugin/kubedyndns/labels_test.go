@@ -0,0 +1,110 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns_test
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/filesource"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/testenv"
+)
+
+// labeledEntries provides entries with matching,
+// non-matching and without labels.
+func labeledEntries() []*api.CoreDNSEntry {
+	var entries []*api.CoreDNSEntry
+	for name, team := range map[string]string{"match": "dns", "other": "web", "none": ""} {
+		e := newEntry(name, api.CoreDNSSpec{DNSNames: []string{name + ".example.org"}, A: []string{"192.0.2.1"}})
+		if team != "" {
+			e.Labels = map[string]string{"team": team}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestEntryLabels(t *testing.T) {
+	soa := test.SOA("example.org. 10 IN SOA ns.dns.example.org. hostmaster.example.org. 0 7200 1800 86400 10")
+
+	for _, directive := range []string{"labels", "entrylabels"} {
+		t.Run(directive, func(t *testing.T) {
+			env := start(t, "kubedyndns example.org {\n "+directive+" team=dns\n}", labeledEntries()...)
+			check(t, env,
+				test.Case{Qname: "match.example.org.", Qtype: dns.TypeA, Answer: []dns.RR{test.A("match.example.org. 10 IN A 192.0.2.1")}},
+				test.Case{Qname: "other.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{soa}},
+				test.Case{Qname: "none.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{soa}},
+			)
+		})
+	}
+}
+
+func TestZoneLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		team   string
+		served bool
+	}{
+		{name: "matching zone", team: "dns", served: true},
+		{name: "non-matching zone", team: "web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone := &api.HostedZone{
+				ObjectMeta: metav1.ObjectMeta{Namespace: filesource.DefaultNamespace, Name: "test", Labels: map[string]string{"team": tt.team}},
+				Spec: api.HostedZoneSpec{
+					DomainNames: []string{"example.org"},
+					EMail:       "hostmaster@example.org",
+					Refresh:     7200,
+					Retry:       3600,
+					Expire:      1209600,
+					MinimumTTL:  600,
+				},
+			}
+			entry := newEntry("www", api.CoreDNSSpec{ZoneRef: "test", DNSNames: []string{"www"}, A: []string{"192.0.2.1"}})
+			env, err := testenv.New("kubedyndns . {\n mode Primary\n zoneobject test\n namespaces default\n zonelabels team=dns\n}", zone, entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := env.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer env.Stop()
+
+			resp, err := env.Query("www.example.org.", dns.TypeA)
+			if !tt.served {
+				// the request is passed to the next plugin
+				if err == nil {
+					t.Errorf("unexpected answer %v", resp)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Answer) != 1 {
+				t.Errorf("unexpected answer %v", resp.Answer)
+			}
+		})
+	}
+}
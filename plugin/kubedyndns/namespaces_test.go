@@ -0,0 +1,119 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/testenv"
+)
+
+func namespace(name, team string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": team}}}
+}
+
+func namespacedEntry(namespace, name, address string) *api.CoreDNSEntry {
+	return &api.CoreDNSEntry{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       api.CoreDNSSpec{DNSNames: []string{name + ".example.org"}, A: []string{address}},
+	}
+}
+
+// selectionTimeout is the time to wait for a changed namespace selection.
+// The selection is applied by listing the objects again, which is delayed
+// by the backoff of the reflectors for every relist.
+const selectionTimeout = 15 * time.Second
+
+// eventually runs the test cases until they succeed or the
+// timeout is reached, because namespace changes are handled
+// asynchronously by the controller.
+func eventually(t *testing.T, env *testenv.Environment, cases ...test.Case) {
+	t.Helper()
+	var err error
+	for start := time.Now(); time.Since(start) < selectionTimeout; time.Sleep(10 * time.Millisecond) {
+		err = nil
+		for _, tc := range cases {
+			if err = env.Check(tc); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			return
+		}
+	}
+	t.Error(err)
+}
+
+func TestNamespaceSelection(t *testing.T) {
+	soa := test.SOA("example.org. 10 IN SOA ns.dns.example.org. hostmaster.example.org. 0 7200 1800 86400 10")
+	served := func(name, address string) test.Case {
+		return test.Case{Qname: name + ".example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+			test.A(fmt.Sprintf("%s.example.org. 10 IN A %s", name, address)),
+		}}
+	}
+	missing := func(name string) test.Case {
+		return test.Case{Qname: name + ".example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{soa}}
+	}
+
+	env, err := testenv.New("kubedyndns example.org {\n namespacelabels team=dns\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Apply(
+		namespace("a", "dns"),
+		namespace("b", "other"),
+		namespacedEntry("a", "host-a", "192.0.2.1"),
+		namespacedEntry("b", "host-b", "192.0.2.2"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { env.Stop() })
+
+	check(t, env, served("host-a", "192.0.2.1"), missing("host-b"))
+
+	// relabel namespace b into the selection
+	if err := env.Apply(namespace("b", "dns")); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, env, served("host-a", "192.0.2.1"), served("host-b", "192.0.2.2"))
+
+	// relabel namespace a out of the selection
+	if err := env.Apply(namespace("a", "other")); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, env, missing("host-a"), served("host-b", "192.0.2.2"))
+
+	// deleted namespaces are not selected anymore
+	if err := env.Delete(namespace("b", "dns")); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, env, missing("host-a"), missing("host-b"))
+}
@@ -0,0 +1,265 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/miekg/dns"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+// NameTree is an in-memory tree of all domain names of a served zone.
+// The names are kept relative to the zone apex, which is represented
+// by the root node.
+// Every node represents an existing domain name. Nodes without entries
+// are empty non-terminals. Nodes with NS entries or a nested zone are
+// delegation points (zone cuts).
+//
+// A NameTree is an immutable snapshot. Modifications are done with
+// a NameTreeEditor creating a new snapshot sharing all unmodified
+// nodes with the original one.
+type NameTree struct {
+	root *NameNode
+}
+
+// NameNode is a single domain name in a NameTree.
+type NameNode struct {
+	Entries  []*objects.Entry
+	Zone     *objects.Zone
+	children map[string]*NameNode
+
+	// version is the edit version the node has been created for.
+	// Nodes of the actual edit version may be modified in place.
+	version uint64
+}
+
+var emptyNameTree = &NameTree{root: &NameNode{}}
+
+func NewNameTree() *NameTree {
+	return emptyNameTree
+}
+
+// Sub returns the sub tree for the given domain name.
+// If the name does not exist, an empty tree is returned.
+func (t *NameTree) Sub(name string) *NameTree {
+	n := t.Find(name)
+	if n == nil {
+		return emptyNameTree
+	}
+	return &NameTree{root: n}
+}
+
+// Find returns the node for the given relative domain name
+// without considering delegation points or wildcards.
+func (t *NameTree) Find(name string) *NameNode {
+	labels := dns.SplitDomainName(strings.ToLower(name))
+	cur := t.root
+	for i := len(labels) - 1; i >= 0 && cur != nil; i-- {
+		cur = cur.children[labels[i]]
+	}
+	return cur
+}
+
+// NameLookup is the result of a name lookup in a NameTree.
+type NameLookup struct {
+	// Node is the node answering the request. It is nil
+	// if the name does not exist. For a wildcard match it
+	// is the source of synthesis.
+	Node *NameNode
+	// Wildcard indicates, that Node is the source of synthesis
+	// for the requested name.
+	Wildcard bool
+	// Apex indicates a request for the zone apex.
+	Apex bool
+	// Cut is the delegation point at or above the requested name.
+	Cut *NameNode
+	// CutName is the domain name of the delegation point
+	// relative to the zone apex.
+	CutName string
+}
+
+// Exists reports whether the looked up name exists, either
+// directly, as empty non-terminal or synthesized by a wildcard.
+func (l *NameLookup) Exists() bool {
+	return l.Node != nil
+}
+
+// CutFqdn returns the absolute domain name of the delegation point
+// for the given zone apex.
+func (l *NameLookup) CutFqdn(zone string) string {
+	return l.CutName + zone
+}
+
+// Lookup looks up a relative domain name according to RFC 1034, section 4.3.2
+// and RFC 4592. The descent stops at delegation points below the zone apex.
+func (t *NameTree) Lookup(name string) *NameLookup {
+	labels := dns.SplitDomainName(strings.ToLower(name))
+	cur := t.root
+	for i := len(labels) - 1; i >= 0; i-- {
+		if cur != t.root && cur.IsCut() {
+			return &NameLookup{Cut: cur, CutName: dnsutil.Join(labels[i+1:]...)}
+		}
+		next := cur.children[labels[i]]
+		if next == nil {
+			// cur is the closest encloser
+			if w := cur.children["*"]; w != nil {
+				return &NameLookup{Node: w, Wildcard: true}
+			}
+			return &NameLookup{}
+		}
+		cur = next
+	}
+	if cur == t.root {
+		return &NameLookup{Node: cur, Apex: true}
+	}
+	if cur.IsCut() {
+		return &NameLookup{Node: cur, Cut: cur, CutName: dnsutil.Join(labels...)}
+	}
+	return &NameLookup{Node: cur}
+}
+
+// IsCut reports whether the node is a delegation point.
+func (n *NameNode) IsCut() bool {
+	return n.Zone != nil || len(n.Delegations()) > 0
+}
+
+// Delegations returns the entries declaring NS records.
+func (n *NameNode) Delegations() []*objects.Entry {
+	var result []*objects.Entry
+	for _, e := range n.Entries {
+		if len(e.NS) > 0 {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Walk visits the nodes of the tree in canonical order starting with the
+// root node. The given function gets the domain name of the node relative to
+// the zone apex ("" for the apex) and reports whether the children of the
+// node should be visited.
+func (t *NameTree) Walk(f func(name string, n *NameNode) bool) {
+	t.root.walk("", f)
+}
+
+func (n *NameNode) walk(name string, f func(name string, n *NameNode) bool) {
+	if !f(name, n) {
+		return
+	}
+	for _, l := range slices.Sorted(maps.Keys(n.children)) {
+		n.children[l].walk(dnsutil.Join(l, name), f)
+	}
+}
+
+func (n *NameNode) isEmpty() bool {
+	return len(n.Entries) == 0 && n.Zone == nil && len(n.children) == 0
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// NameTreeEditor creates a new NameTree snapshot from a given one.
+// Nodes are copied on their first modification, only.
+type NameTreeEditor struct {
+	version uint64
+	root    *NameNode
+}
+
+// Edit starts the creation of a modified snapshot of the tree.
+// The version must be unique for every edit operation on a set of
+// snapshots sharing nodes.
+func (t *NameTree) Edit(version uint64) *NameTreeEditor {
+	e := &NameTreeEditor{version: version}
+	e.root = e.own(t.root)
+	return e
+}
+
+// Tree returns the new snapshot. The editor must not be used afterwards.
+func (e *NameTreeEditor) Tree() *NameTree {
+	return &NameTree{root: e.root}
+}
+
+func (e *NameTreeEditor) own(n *NameNode) *NameNode {
+	if n.version == e.version {
+		return n
+	}
+	return &NameNode{
+		Entries:  n.Entries,
+		Zone:     n.Zone,
+		children: maps.Clone(n.children),
+		version:  e.version,
+	}
+}
+
+// modify applies a modification to the node for the given relative
+// domain name. The node and all its parents are created if required.
+// Nodes becoming empty are removed afterward.
+func (e *NameTreeEditor) modify(name string, mod func(n *NameNode)) {
+	labels := dns.SplitDomainName(strings.ToLower(name))
+	path := []*NameNode{e.root}
+	cur := e.root
+	for i := len(labels) - 1; i >= 0; i-- {
+		next := cur.children[labels[i]]
+		if next == nil {
+			next = &NameNode{version: e.version}
+		} else {
+			next = e.own(next)
+		}
+		if cur.children == nil {
+			cur.children = map[string]*NameNode{}
+		}
+		cur.children[labels[i]] = next
+		cur = next
+		path = append(path, cur)
+	}
+	mod(cur)
+
+	// cleanup empty non-terminals
+	for i := len(path) - 1; i > 0 && path[i].isEmpty(); i-- {
+		delete(path[i-1].children, labels[len(labels)-i])
+	}
+}
+
+// AddEntry adds an entry for the given relative domain name.
+func (e *NameTreeEditor) AddEntry(name string, entry *objects.Entry) {
+	e.modify(name, func(n *NameNode) {
+		n.Entries = append(slices.Clip(n.Entries), entry)
+	})
+}
+
+// RemoveEntry removes an entry for the given relative domain name.
+func (e *NameTreeEditor) RemoveEntry(name string, entry *objects.Entry) {
+	e.modify(name, func(n *NameNode) {
+		n.Entries = slices.DeleteFunc(slices.Clone(n.Entries), func(o *objects.Entry) bool {
+			return o.Namespace == entry.Namespace && o.Name == entry.Name
+		})
+	})
+}
+
+// SetZone sets the nested zone for the given relative domain name.
+// A nil zone removes a nested zone.
+func (e *NameTreeEditor) SetZone(name string, z *objects.Zone) {
+	e.modify(name, func(n *NameNode) {
+		n.Zone = z
+	})
+}
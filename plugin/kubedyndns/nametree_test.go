@@ -0,0 +1,302 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/miekg/dns"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+const benchmarkEntries = 100000
+
+// benchmarkNames provides domain names distributed over
+// 100 sub domains with a wildcard per sub domain.
+func benchmarkNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("host%d.sub%d", i, i%100)
+	}
+	for i := 0; i < 100 && i < n; i++ {
+		names[i] = fmt.Sprintf("*.sub%d", i)
+	}
+	return names
+}
+
+func benchmarkTree(names []string) *NameTree {
+	e := NewNameTree().Edit(1)
+	for i, n := range names {
+		e.AddEntry(n, &objects.Entry{Namespace: "default", Name: fmt.Sprintf("e%d", i), DNSNames: []string{n}})
+	}
+	return e.Tree()
+}
+
+func BenchmarkNameTreeBuild(b *testing.B) {
+	names := benchmarkNames(benchmarkEntries)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchmarkTree(names)
+	}
+}
+
+func BenchmarkNameTreeEdit(b *testing.B) {
+	tree := benchmarkTree(benchmarkNames(benchmarkEntries))
+	entry := &objects.Entry{Namespace: "default", Name: "new", DNSNames: []string{"new.sub42"}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e := tree.Edit(uint64(i + 2))
+		if i%2 == 0 {
+			e.AddEntry("new.sub42", entry)
+		} else {
+			e.RemoveEntry("new.sub42", entry)
+		}
+		tree = e.Tree()
+	}
+}
+
+func BenchmarkNameTreesNotify(b *testing.B) {
+	trees := newNameTrees(false)
+	for i, n := range benchmarkNames(benchmarkEntries) {
+		trees.Notify(nil, &objects.Entry{Namespace: "default", Name: fmt.Sprintf("e%d", i), DNSNames: []string{n}})
+	}
+	trees.Get("")
+	entry := &objects.Entry{Namespace: "default", Name: "new", DNSNames: []string{"new.sub42"}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%2 == 0 {
+			trees.Notify(nil, entry)
+		} else {
+			trees.Notify(entry, nil)
+		}
+		trees.Get("")
+	}
+}
+
+func BenchmarkNameTreeLookup(b *testing.B) {
+	tree := benchmarkTree(benchmarkNames(benchmarkEntries))
+	queries := []string{"host4711.sub11", "unknown.sub12", "a.b.sub13", "sub14", "unknown"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if l := tree.Lookup(queries[i%len(queries)]); l == nil {
+			b.Fatal("no lookup result")
+		}
+	}
+}
+
+// BenchmarkIndexerLookup is the baseline replaced by the name trees.
+// For every label of the query the entry and zone indexers of the
+// informer cache are consulted to detect delegation points and nested
+// zones, finally the entries of the name are taken from the entry indexer.
+func BenchmarkIndexerLookup(b *testing.B) {
+	key := func(obj interface{}) (string, error) {
+		o := obj.(objects.Object)
+		return o.GetNamespace() + "/" + o.GetName(), nil
+	}
+	entries := cache.NewIndexer(key, cache.Indexers{EntryDomainIndex: func(obj interface{}) ([]string, error) {
+		return obj.(*objects.Entry).DNSNames, nil
+	}})
+	zones := cache.NewIndexer(key, cache.Indexers{ZoneDomainIndex: func(obj interface{}) ([]string, error) {
+		return obj.(*objects.Zone).DomainNames, nil
+	}})
+	for i, n := range benchmarkNames(benchmarkEntries) {
+		entries.Add(&objects.Entry{Namespace: "default", Name: fmt.Sprintf("e%d", i), DNSNames: []string{n + "."}})
+	}
+	queries := []string{"host4711.sub11", "unknown.sub12", "a.b.sub13", "sub14", "unknown"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q := queries[i%len(queries)]
+		labels := dns.SplitDomainName(q)
+		rel := "."
+	walk:
+		for j := len(labels) - 1; j >= 0; j-- {
+			rel = dnsutil.Join(labels[j], rel)
+			found, _ := entries.ByIndex(EntryDomainIndex, rel)
+			for _, o := range found {
+				if len(o.(*objects.Entry).NS) != 0 {
+					break walk
+				}
+			}
+			zones.ByIndex(ZoneDomainIndex, rel)
+		}
+		entries.ByIndex(EntryDomainIndex, dns.Fqdn(q))
+	}
+}
+
+func TestNameTreesReadAfterNotify(t *testing.T) {
+	trees := newNameTrees(false)
+	entry := &objects.Entry{Namespace: "default", Name: "e", DNSNames: []string{"a.b"}}
+
+	trees.Notify(nil, entry)
+	if trees.Synced() {
+		t.Fatal("pending change not reported")
+	}
+	if n := trees.Get("").Find("a.b"); n == nil || len(n.Entries) != 1 {
+		t.Fatal("notified entry not visible")
+	}
+	if !trees.Synced() {
+		t.Fatal("applied change still pending")
+	}
+
+	trees.Notify(entry, nil)
+	if n := trees.Get("").Find("a.b"); n != nil {
+		t.Fatal("removed entry still visible")
+	}
+	if n := trees.Get("").Find("b"); n != nil {
+		t.Fatal("empty non-terminal not removed")
+	}
+}
+
+// lookupTree provides the example zone of RFC 4592, section 2.2.1,
+// relative to the zone apex, extended by a nested zone and a wildcard
+// below a delegation point.
+func lookupTree() *NameTree {
+	e := NewNameTree().Edit(1)
+	for i, n := range []string{"*", "sub.*", "_ssh._tcp.host1", "_ssh._tcp.host2", "*.deep.sub", "www.nested"} {
+		e.AddEntry(n, &objects.Entry{Namespace: "default", Name: fmt.Sprintf("e%d", i), DNSNames: []string{n}})
+	}
+	e.AddEntry("subdel", &objects.Entry{Namespace: "default", Name: "subdel", DNSNames: []string{"subdel"}, NS: []string{"ns.example.com."}})
+	e.AddEntry("*.subdel", &objects.Entry{Namespace: "default", Name: "wildcard", DNSNames: []string{"*.subdel"}})
+	e.SetZone("nested", &objects.Zone{})
+	return e.Tree()
+}
+
+func TestNameTreeLookup(t *testing.T) {
+	tree := lookupTree()
+
+	tests := []struct {
+		name string
+		// node is the name of the expected node, "-" for no node.
+		node     string
+		wildcard bool
+		apex     bool
+		// cut is the name of the expected delegation point, "-" for none.
+		cut string
+	}{
+		{name: "", node: "", apex: true, cut: "-"},
+		// wildcard matches (RFC 4592, section 2.2.1)
+		{name: "host3", node: "*", wildcard: true, cut: "-"},
+		{name: "foo.bar", node: "*", wildcard: true, cut: "-"},
+		{name: "HOST3", node: "*", wildcard: true, cut: "-"},
+		{name: "a.b.deep.sub", node: "*.deep.sub", wildcard: true, cut: "-"},
+		// existing names, including empty non-terminals, block the wildcard
+		{name: "host1", node: "host1", cut: "-"},
+		{name: "_tcp.host1", node: "_tcp.host1", cut: "-"},
+		{name: "_ssh._tcp.host1", node: "_ssh._tcp.host1", cut: "-"},
+		{name: "sub", node: "sub", cut: "-"},
+		{name: "deep.sub", node: "deep.sub", cut: "-"},
+		// the wildcard name itself and names below it
+		{name: "*", node: "*", cut: "-"},
+		{name: "sub.*", node: "sub.*", cut: "-"},
+		{name: "ghost.*", node: "-", cut: "-"},
+		// no wildcard at the closest encloser
+		{name: "_telnet._tcp.host1", node: "-", cut: "-"},
+		{name: "x.sub", node: "-", cut: "-"},
+		{name: "x.sub.*", node: "-", cut: "-"},
+		// delegation points stop the descent
+		{name: "subdel", node: "subdel", cut: "subdel"},
+		{name: "host.subdel", node: "-", cut: "subdel"},
+		{name: "a.b.subdel", node: "-", cut: "subdel"},
+		{name: "*.subdel", node: "-", cut: "subdel"},
+		{name: "nested", node: "nested", cut: "nested"},
+		{name: "www.nested", node: "-", cut: "nested"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := tree.Lookup(tc.name)
+			if tc.node == "-" {
+				if l.Exists() {
+					t.Errorf("unexpected node %+v", l.Node)
+				}
+			} else if n := tree.Find(tc.node); l.Node != n || n == nil {
+				t.Errorf("expected node %q, found %+v", tc.node, l.Node)
+			}
+			if l.Wildcard != tc.wildcard {
+				t.Errorf("wildcard %t, expected %t", l.Wildcard, tc.wildcard)
+			}
+			if l.Apex != tc.apex {
+				t.Errorf("apex %t, expected %t", l.Apex, tc.apex)
+			}
+			if tc.cut == "-" {
+				if l.Cut != nil {
+					t.Errorf("unexpected cut %q", l.CutName)
+				}
+			} else if l.Cut != tree.Find(tc.cut) || l.CutName != tc.cut+"." {
+				t.Errorf("expected cut %q, found %q", tc.cut, l.CutName)
+			}
+			if tc.cut != "-" && l.CutFqdn("example.") != tc.cut+".example." {
+				t.Errorf("cut fqdn %q", l.CutFqdn("example."))
+			}
+		})
+	}
+}
+
+func TestNameTreeWildcardEdit(t *testing.T) {
+	tree := lookupTree()
+	wildcard := tree.Find("*").Entries[0]
+
+	e := tree.Edit(2)
+	e.RemoveEntry("*", wildcard)
+	modified := e.Tree()
+
+	// the wildcard node is kept as empty non-terminal for sub.*
+	if l := modified.Lookup("host3"); !l.Wildcard || len(l.Node.Entries) != 0 {
+		t.Errorf("expected empty wildcard node, found %+v", l.Node)
+	}
+
+	e = modified.Edit(3)
+	e.RemoveEntry("sub.*", tree.Find("sub.*").Entries[0])
+	modified = e.Tree()
+	if l := modified.Lookup("host3"); l.Exists() {
+		t.Errorf("removed wildcard still matches: %+v", l.Node)
+	}
+	if n := modified.Find("*"); n != nil {
+		t.Errorf("empty wildcard node not removed")
+	}
+
+	// the original snapshot is unchanged
+	if l := tree.Lookup("host3"); !l.Wildcard || len(l.Node.Entries) != 1 {
+		t.Errorf("original snapshot modified: %+v", l.Node)
+	}
+}
+
+func TestNameTreeWalk(t *testing.T) {
+	var names []string
+	lookupTree().Walk(func(name string, n *NameNode) bool {
+		names = append(names, name)
+		return !n.IsCut()
+	})
+	expected := []string{
+		"", "*.", "sub.*.", "host1.", "_tcp.host1.", "_ssh._tcp.host1.", "host2.", "_tcp.host2.", "_ssh._tcp.host2.",
+		"nested.", "sub.", "deep.sub.", "*.deep.sub.", "subdel.",
+	}
+	if fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Errorf("walked %v\nexpected %v", names, expected)
+	}
+}
@@ -0,0 +1,221 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+	"k8s.io/client-go/tools/cache"
+)
+
+// nameTrees maintains the name trees for all zones incrementally
+// based on the informer events.
+// In Primary mode there is a tree per zone object containing the entries
+// referring to this zone and the nested zones. Otherwise, there is a single tree
+// (key "") containing all entries without a zone reference.
+// Names of entries in reverse zones are kept with their absolute names
+// in a separate tree (key reverseTree) for all modes.
+//
+// Changes are collected and applied in batches, resulting in new tree
+// snapshots, which are atomically replaced. Pending changes are applied
+// in the background and before any read access, so lookups always see
+// all notified changes and work on a consistent snapshot.
+type nameTrees struct {
+	// primary selects the tree layout for the Primary mode.
+	primary bool
+
+	lock    sync.Mutex
+	pending []treeChange
+	dirty   atomic.Bool
+	signal  chan struct{}
+
+	// applyLock serializes the application of batches.
+	applyLock sync.Mutex
+	version   uint64
+
+	trees atomic.Pointer[map[string]*NameTree]
+}
+
+// reverseTree is the key of the tree containing the names in reverse zones.
+const reverseTree = "<reverse>"
+
+// treeChange describes the change of an entry or zone object.
+// For additions old is nil, for deletions new is nil.
+type treeChange struct {
+	old objects.Object
+	new objects.Object
+}
+
+func newNameTrees(primary bool) *nameTrees {
+	t := &nameTrees{
+		primary: primary,
+		signal:  make(chan struct{}, 1),
+	}
+	t.trees.Store(&map[string]*NameTree{})
+	return t
+}
+
+// Get returns the actual snapshot of the tree with the given key.
+// Pending changes are applied before.
+func (t *nameTrees) Get(key string) *NameTree {
+	if t.dirty.Load() {
+		t.apply()
+	}
+	if tree := (*t.trees.Load())[key]; tree != nil {
+		return tree
+	}
+	return NewNameTree()
+}
+
+// Synced reports whether all notified changes have been applied.
+func (t *nameTrees) Synced() bool {
+	return !t.dirty.Load()
+}
+
+// Notify registers an object change.
+func (t *nameTrees) Notify(old, new objects.Object) {
+	t.lock.Lock()
+	t.pending = append(t.pending, treeChange{old: old, new: new})
+	t.dirty.Store(true)
+	t.lock.Unlock()
+
+	select {
+	case t.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Run applies the notified changes until the stop channel is closed.
+func (t *nameTrees) Run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-t.signal:
+			t.apply()
+		}
+	}
+}
+
+// apply applies all pending changes as a single batch.
+func (t *nameTrees) apply() {
+	t.applyLock.Lock()
+	defer t.applyLock.Unlock()
+
+	t.lock.Lock()
+	changes := t.pending
+	t.pending = nil
+	t.lock.Unlock()
+	if len(changes) == 0 {
+		return
+	}
+
+	t.version++
+	trees := maps.Clone(*t.trees.Load())
+	editors := map[string]*NameTreeEditor{}
+	editor := func(key string) *NameTreeEditor {
+		e := editors[key]
+		if e == nil {
+			tree := trees[key]
+			if tree == nil {
+				tree = NewNameTree()
+			}
+			e = tree.Edit(t.version)
+			editors[key] = e
+		}
+		return e
+	}
+
+	for _, c := range changes {
+		if c.old != nil {
+			t.remove(editor, c.old)
+		}
+		if c.new != nil {
+			t.add(editor, c.new)
+		}
+	}
+
+	for key, e := range editors {
+		trees[key] = e.Tree()
+	}
+	t.trees.Store(&trees)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.dirty.Store(len(t.pending) > 0)
+}
+
+func (t *nameTrees) add(editor func(string) *NameTreeEditor, o objects.Object) {
+	switch obj := o.(type) {
+	case *objects.Entry:
+		key, ok := t.entryKey(obj)
+		for _, n := range obj.DNSNames {
+			switch {
+			case dnsutil.IsReverse(n) > 0:
+				editor(reverseTree).AddEntry(n, obj)
+			case ok:
+				editor(key).AddEntry(n, obj)
+			}
+		}
+	case *objects.Zone:
+		if obj.ParentRef != "" {
+			e := editor(cache.NewObjectName(obj.Namespace, obj.ParentRef).String())
+			for _, n := range obj.DomainNames {
+				e.SetZone(n, obj)
+			}
+		}
+	}
+}
+
+func (t *nameTrees) remove(editor func(string) *NameTreeEditor, o objects.Object) {
+	switch obj := o.(type) {
+	case *objects.Entry:
+		key, ok := t.entryKey(obj)
+		for _, n := range obj.DNSNames {
+			switch {
+			case dnsutil.IsReverse(n) > 0:
+				editor(reverseTree).RemoveEntry(n, obj)
+			case ok:
+				editor(key).RemoveEntry(n, obj)
+			}
+		}
+	case *objects.Zone:
+		if obj.ParentRef != "" {
+			e := editor(cache.NewObjectName(obj.Namespace, obj.ParentRef).String())
+			for _, n := range obj.DomainNames {
+				e.SetZone(n, nil)
+			}
+		}
+	}
+}
+
+// entryKey determines the tree responsible for an entry.
+func (t *nameTrees) entryKey(e *objects.Entry) (string, bool) {
+	if t.primary {
+		if e.ZoneRef == "" {
+			return "", false
+		}
+		return cache.NewObjectName(e.Namespace, e.ZoneRef).String(), true
+	}
+	return "", e.ZoneRef == ""
+}
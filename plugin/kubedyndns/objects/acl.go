@@ -0,0 +1,116 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package objects
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+)
+
+// ACL is a parsed access control list for clients.
+// Denied networks take precedence. If allowed networks are
+// given, only clients from these networks are allowed.
+type ACL struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+// DenyAll is an ACL denying all clients. It replaces
+// ACLs, which cannot be parsed, so that invalid ACLs fail closed.
+var DenyAll = &ACL{Deny: []*net.IPNet{
+	{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 8*net.IPv4len)},
+	{IP: net.IPv6zero, Mask: net.CIDRMask(0, 8*net.IPv6len)},
+}}
+
+// ParseACL parses the allowed and denied networks in CIDR notation.
+// A plain address is used as single host network.
+func ParseACL(allow, deny []string) (*ACL, error) {
+	var err error
+
+	acl := &ACL{}
+	acl.Allow, err = parseNets(allow)
+	if err != nil {
+		return nil, err
+	}
+	acl.Deny, err = parseNets(deny)
+	if err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+// ToACL converts an api.ACL. A nil ACL is returned for a nil api.ACL.
+func ToACL(a *api.ACL) (*ACL, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return ParseACL(a.Allow, a.Deny)
+}
+
+func parseNets(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", c)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", c, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Allowed checks whether a client address is allowed by the ACL.
+// A nil ACL allows all clients.
+func (a *ACL) Allowed(ip net.IP) bool {
+	if a == nil {
+		return true
+	}
+	if ip == nil {
+		return len(a.Allow) == 0 && len(a.Deny) == 0
+	}
+	for _, n := range a.Deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(a.Allow) == 0 {
+		return true
+	}
+	for _, n := range a.Allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package objects
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestACLAllowed(t *testing.T) {
+	acl, err := ParseACL([]string{"10.0.0.0/8", "fd00::1"}, []string{"10.1.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		acl     *ACL
+		ip      string
+		allowed bool
+	}{
+		{nil, "192.168.0.1", true},
+		{acl, "10.0.0.1", true},
+		{acl, "10.1.0.1", false},
+		{acl, "192.168.0.1", false},
+		{acl, "fd00::1", true},
+		{acl, "fd00::2", false},
+		{DenyAll, "10.0.0.1", false},
+		{DenyAll, "::1", false},
+		{DenyAll, "", false},
+	}
+	for i, tc := range tests {
+		if got := tc.acl.Allowed(net.ParseIP(tc.ip)); got != tc.allowed {
+			t.Errorf("test %d: expected %t for %q, got %t", i, tc.allowed, tc.ip, got)
+		}
+	}
+}
+
+func TestInvalidZoneACLFailsClosed(t *testing.T) {
+	tests := []struct {
+		name string
+		spec api.HostedZoneSpec
+	}{
+		{"query", api.HostedZoneSpec{QueryACL: &api.ACL{Allow: []string{"10.0.0.0/33"}}}},
+		{"transfer", api.HostedZoneSpec{TransferACL: &api.ACL{Deny: []string{"no address"}}}},
+	}
+	for _, tc := range tests {
+		spec := tc.spec
+		spec.DomainNames = []string{"example.org"}
+		spec.EMail = "admin@example.org"
+		o, err := ToZone(context.Background(), nil, false, false)(&api.HostedZone{
+			ObjectMeta: metav1.ObjectMeta{Name: "zone", Namespace: "default"},
+			Spec:       spec,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		z := o.(*Zone)
+		if z.Error == nil {
+			t.Errorf("%s: expected error for invalid ACL", tc.name)
+		}
+		acl := z.QueryAccess
+		if tc.name == "transfer" {
+			acl = z.TransferAccess
+		}
+		for _, ip := range []string{"10.0.0.1", "192.168.0.1", "::1"} {
+			if acl.Allowed(net.ParseIP(ip)) {
+				t.Errorf("%s: invalid ACL must refuse %s", tc.name, ip)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package objects
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	clientapi "github.com/mandelsoft/kubedyndns/client/clientset/versioned"
+)
+
+// Client provides access to the CoreDNSEntry and HostedZone objects
+// handled by the plugin. It is provided for the clientset of a cluster
+// (see ForClientset) and by in-memory stores used without cluster.
+type Client interface {
+	ListEntries(ctx context.Context, namespace string, opts metav1.ListOptions) (*api.CoreDNSEntryList, error)
+	WatchEntries(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error)
+	// UpdateEntryStatus updates the status of an entry.
+	// The rest of the object is not changed.
+	UpdateEntryStatus(ctx context.Context, e *api.CoreDNSEntry) (*api.CoreDNSEntry, error)
+
+	ListZones(ctx context.Context, namespace string, opts metav1.ListOptions) (*api.HostedZoneList, error)
+	WatchZones(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error)
+	// UpdateZoneStatus updates the status of a zone.
+	// The rest of the object is not changed.
+	UpdateZoneStatus(ctx context.Context, z *api.HostedZone) (*api.HostedZone, error)
+}
+
+// ForClientset provides a Client for the clientset of a cluster.
+func ForClientset(c clientapi.Interface) Client {
+	return &clientset{c}
+}
+
+type clientset struct {
+	client clientapi.Interface
+}
+
+func (c *clientset) ListEntries(ctx context.Context, namespace string, opts metav1.ListOptions) (*api.CoreDNSEntryList, error) {
+	return c.client.CorednsV1alpha1().CoreDNSEntries(namespace).List(ctx, opts)
+}
+
+func (c *clientset) WatchEntries(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	return c.client.CorednsV1alpha1().CoreDNSEntries(namespace).Watch(ctx, opts)
+}
+
+func (c *clientset) UpdateEntryStatus(ctx context.Context, e *api.CoreDNSEntry) (*api.CoreDNSEntry, error) {
+	return c.client.CorednsV1alpha1().CoreDNSEntries(e.Namespace).UpdateStatus(ctx, e, metav1.UpdateOptions{})
+}
+
+func (c *clientset) ListZones(ctx context.Context, namespace string, opts metav1.ListOptions) (*api.HostedZoneList, error) {
+	return c.client.CorednsV1alpha1().HostedZones(namespace).List(ctx, opts)
+}
+
+func (c *clientset) WatchZones(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	return c.client.CorednsV1alpha1().HostedZones(namespace).Watch(ctx, opts)
+}
+
+func (c *clientset) UpdateZoneStatus(ctx context.Context, z *api.HostedZone) (*api.HostedZone, error) {
+	return c.client.CorednsV1alpha1().HostedZones(z.Namespace).UpdateStatus(ctx, z, metav1.UpdateOptions{})
+}
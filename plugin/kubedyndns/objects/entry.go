@@ -28,9 +28,9 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
-	clientapi "github.com/mandelsoft/kubedyndns/client/clientset/versioned"
 	"github.com/miekg/dns"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -41,6 +41,30 @@ import (
 
 var Log clog.P
 
+// ApexName is the DNS name used by entries to denote the zone apex.
+const ApexName = "@"
+
+// Annotations describing the origin of objects aggregated from several clusters.
+const (
+	ANNOTATION_CLUSTER = api.GroupName + "/cluster"
+	ANNOTATION_NAME    = api.GroupName + "/name"
+)
+
+// orderings for the address records of a DNS name.
+const ORDER_NONE = "none"
+const ORDER_SORTED = "sorted"
+const ORDER_ROTATE = "rotate"
+const ORDER_SHUFFLE = "shuffle"
+
+// ValidOrder checks whether the given ordering is supported.
+func ValidOrder(o string) bool {
+	switch o {
+	case ORDER_NONE, ORDER_SORTED, ORDER_ROTATE, ORDER_SHUFFLE:
+		return true
+	}
+	return false
+}
+
 // Entry is a stripped down api.CoreDNSEntry with only the items we need for CoreDNS.
 type Entry struct {
 	Plain     bool
@@ -48,6 +72,7 @@ type Entry struct {
 	Name      string
 	Namespace string
 	ZoneRef   string
+	Cluster   string
 	Error     error
 	Ttl       uint32
 	DNSNames  []string
@@ -55,11 +80,16 @@ type Entry struct {
 	A     []string
 	AAAA  []string
 	CNAME string
+	Alias string
 
 	Text    []string
 	NS      []string
+	PTR     []string
 	Service *api.ServiceSpec
 
+	Order     string
+	NoReverse bool
+
 	Status api.CoreDNSStatus
 	*object.Empty
 }
@@ -87,7 +117,7 @@ func normalizeRecords(recs []api.SRVRecord, zone string) []api.SRVRecord {
 }
 
 // ToEntry returns a client specific converter for converting an api.CoreDNSEntry to a *Entry.
-func ToEntry(ctx context.Context, client clientapi.Interface, slave bool) func(obj metav1.Object) (metav1.Object, error) {
+func ToEntry(ctx context.Context, client Client, slave bool) func(obj metav1.Object) (metav1.Object, error) {
 	return func(obj metav1.Object) (metav1.Object, error) {
 		e, ok := obj.(*api.CoreDNSEntry)
 		if !ok {
@@ -99,13 +129,19 @@ func ToEntry(ctx context.Context, client clientapi.Interface, slave bool) func(o
 			Name:      e.GetName(),
 			Namespace: e.GetNamespace(),
 			ZoneRef:   e.Spec.ZoneRef,
+			Cluster:   e.Annotations[ANNOTATION_CLUSTER],
+			Order:     e.Spec.Order,
+			NoReverse: e.Spec.NoReverse,
 		}
 		e.Status.DeepCopyInto(&s.Status)
 
 		var err error
 
 		for _, n := range e.Spec.DNSNames {
-			fmt.Printf("cache %q\n", plugin.Name(n).Normalize())
+			if n == ApexName {
+				n = "."
+			}
+			Log.Debugf("cache %q", plugin.Name(n).Normalize())
 			s.DNSNames = append(s.DNSNames, plugin.Name(n).Normalize())
 		}
 
@@ -133,8 +169,18 @@ func ToEntry(ctx context.Context, client clientapi.Interface, slave bool) func(o
 		if len(e.Spec.CNAME) > 0 {
 			s.CNAME = e.Spec.CNAME
 		}
+		if len(e.Spec.ALIAS) > 0 {
+			s.Alias = e.Spec.ALIAS
+			if len(e.Spec.CNAME) > 0 || len(e.Spec.A) > 0 || len(e.Spec.AAAA) > 0 {
+				err = fmt.Errorf("ALIAS cannot be combined with A, AAAA or CNAME records")
+			}
+		}
 
 		set(&s.Text, e.Spec.TXT)
+		set(&s.NS, e.Spec.NS)
+		for _, p := range e.Spec.PTR {
+			s.PTR = append(s.PTR, dns.Fqdn(p))
+		}
 		if e.Spec.SRV != nil {
 			s.Service = &api.ServiceSpec{Service: e.Spec.SRV.Service}
 			set(&s.Service.Records, slices.Clone(e.Spec.SRV.Records))
@@ -143,9 +189,21 @@ func ToEntry(ctx context.Context, client clientapi.Interface, slave bool) func(o
 		if len(e.Spec.DNSNames) == 0 {
 			err = fmt.Errorf("at least one DNS name is required")
 		}
-		if len(e.Spec.A) == 0 && len(e.Spec.AAAA) == 0 && len(e.Spec.CNAME) == 0 && len(e.Spec.TXT) == 0 && len(e.Spec.NS) == 0 && (e.Spec.SRV == nil || len(e.Spec.SRV.Records) == 0) {
+		if len(e.Spec.A) == 0 && len(e.Spec.AAAA) == 0 && len(e.Spec.CNAME) == 0 && len(e.Spec.ALIAS) == 0 && len(e.Spec.TXT) == 0 && len(e.Spec.NS) == 0 && len(e.Spec.PTR) == 0 && (e.Spec.SRV == nil || len(e.Spec.SRV.Records) == 0) {
 			err = fmt.Errorf("no record defined")
 		}
+		if s.Order != "" && !ValidOrder(s.Order) {
+			err = fmt.Errorf("invalid order %q", s.Order)
+		}
+		// Names of entries of zone objects are relative to the zone,
+		// they are checked after resolving them against the zone.
+		if len(s.PTR) > 0 && s.ZoneRef == "" {
+			for _, n := range s.DNSNames {
+				if dnsutil.IsReverse(n) == 0 {
+					err = fmt.Errorf("PTR records require DNS names in a reverse zone (%s)", n)
+				}
+			}
+		}
 		if e.Spec.SRV != nil {
 			if len(e.Spec.SRV.Records) != 0 && len(e.Spec.SRV.Service) == 0 {
 				err = fmt.Errorf("service name required for SRV record")
@@ -182,7 +240,12 @@ func (s *Entry) DeepCopyObject() runtime.Object {
 	set(&s1.A, s.A)
 	set(&s1.AAAA, s.AAAA)
 	set(&s1.Text, s.Text)
+	set(&s1.NS, s.NS)
+	set(&s1.PTR, s.PTR)
 	s1.CNAME = s.CNAME
+	s1.Alias = s.Alias
+	s1.Order = s.Order
+	s1.NoReverse = s.NoReverse
 	if s.Service.Service != "" {
 		s1.Service.Service = s.Service.Service
 		set(&s1.Service.Records, s.Service.Records)
@@ -209,6 +272,12 @@ func (e *Entry) Equal(b *Entry) bool {
 	if !slices.Equal(e.Text, b.Text) {
 		return false
 	}
+	if !slices.Equal(e.NS, b.NS) {
+		return false
+	}
+	if !slices.Equal(e.PTR, b.PTR) {
+		return false
+	}
 	if e.Service != nil || b.Service != nil {
 		if e.Service != b.Service && e.Service.Service != b.Service.Service {
 			return false
@@ -218,6 +287,18 @@ func (e *Entry) Equal(b *Entry) bool {
 	if e.CNAME != b.CNAME {
 		return false
 	}
+	if e.Alias != b.Alias {
+		return false
+	}
+	if e.Order != b.Order {
+		return false
+	}
+	if e.NoReverse != b.NoReverse {
+		return false
+	}
+	if e.Cluster != b.Cluster {
+		return false
+	}
 	if e.Service != nil && e.Service.Service != "" {
 		if len(e.Service.Records) != len(b.Service.Records) {
 			return false
@@ -254,7 +335,7 @@ func (s *Entry) Services(t uint16, p string, defttl uint32, zone string) []msg.S
 	case dns.TypeANY:
 		result = s.serviceForHosts(defttl, s.A...)
 		result = append(result, s.serviceForHosts(defttl, s.AAAA...)...)
-		result = append(result, s.serviceForHosts(defttl, s.CNAME)...)
+		result = append(result, s.Services(dns.TypeCNAME, p, defttl, zone)...)
 		result = append(result, s.Services(dns.TypeTXT, p, defttl, zone)...)
 		result = append(result, s.Services(dns.TypeSRV, p, defttl, zone)...)
 	case dns.TypeA:
@@ -262,7 +343,9 @@ func (s *Entry) Services(t uint16, p string, defttl uint32, zone string) []msg.S
 	case dns.TypeAAAA:
 		result = s.serviceForHosts(defttl, s.AAAA...)
 	case dns.TypeCNAME:
-		result = s.serviceForHosts(defttl, s.CNAME)
+		if s.CNAME != "" {
+			result = s.serviceForHosts(defttl, normalizeHost(s.CNAME, zone))
+		}
 	case dns.TypeTXT:
 		for _, h := range s.Text {
 			result = append(result, msg.Service{
@@ -273,6 +356,8 @@ func (s *Entry) Services(t uint16, p string, defttl uint32, zone string) []msg.S
 				Key:  coredns,
 			})
 		}
+	case dns.TypePTR:
+		result = s.serviceForHosts(defttl, s.PTR...)
 	case dns.TypeSRV:
 		if s.Service.Service != "" {
 			for _, h := range s.Service.Records {
@@ -332,6 +417,8 @@ func (s *Entry) MatchType(t uint16) bool {
 		return s.Service != nil
 	case dns.TypeNS:
 		return len(s.NS) > 0
+	case dns.TypePTR:
+		return len(s.PTR) > 0
 	case dns.TypeMX:
 		return false
 	}
@@ -370,7 +457,7 @@ func DefTTL(ttl, def uint32) uint32 {
 
 const coredns = "c" // used as a fake key prefix in msg.Service
 
-func (e *Entry) UpdateStatus(ctx context.Context, client clientapi.Interface, zn string, names []string, err error) error {
+func (e *Entry) UpdateStatus(ctx context.Context, client Client, zn string, names []string, err error) error {
 	var o api.CoreDNSEntry
 	o.ResourceVersion = e.GetResourceVersion()
 	o.Name = e.GetName()
@@ -429,7 +516,7 @@ func (e *Entry) UpdateStatus(ctx context.Context, client clientapi.Interface, zn
 		}
 	}
 	if mod {
-		_, err := client.CorednsV1alpha1().CoreDNSEntries(e.Namespace).UpdateStatus(ctx, &o, metav1.UpdateOptions{})
+		_, err := client.UpdateEntryStatus(ctx, &o)
 		if err != nil {
 			Log.Errorf("error updating entry status %s/%s: %s", o.Namespace, o.Name, err)
 		} else {
@@ -439,3 +526,34 @@ func (e *Entry) UpdateStatus(ctx context.Context, client clientapi.Interface, zn
 	}
 	return nil
 }
+
+// Withdraw marks an entry as not served anymore.
+func (e *Entry) Withdraw(ctx context.Context, client Client, msg string) error {
+	var o api.CoreDNSEntry
+	o.ResourceVersion = e.GetResourceVersion()
+	o.Name = e.GetName()
+	o.Namespace = e.GetNamespace()
+	e.Status.DeepCopyInto(&o.Status)
+
+	o.Status.RootZone = ""
+	o.Status.EffectiveDomainNames = nil
+	if e.Plain {
+		o.Status.State = "Withdrawn"
+		o.Status.Message = msg
+	} else {
+		meta.SetStatusCondition(&o.Status.Conditions, metav1.Condition{
+			Type:               api.ServerConditionType,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: o.ObjectMeta.Generation,
+			Reason:             api.ReasonServerWithdrawn,
+			Message:            msg,
+		})
+	}
+	_, err := client.UpdateEntryStatus(ctx, &o)
+	if err != nil {
+		Log.Errorf("error withdrawing entry %s/%s: %s", o.Namespace, o.Name, err)
+	} else {
+		Log.Infof("entry %s/%s withdrawn: %s", o.Namespace, o.Name, msg)
+	}
+	return err
+}
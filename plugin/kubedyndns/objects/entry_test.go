@@ -0,0 +1,68 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package objects
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+)
+
+func TestEntryEqualCluster(t *testing.T) {
+	a := &Entry{Cluster: "a", DNSNames: []string{"www."}, A: []string{"10.0.0.1"}}
+	b := &Entry{Cluster: "a", DNSNames: []string{"www."}, A: []string{"10.0.0.1"}}
+	if !a.Equal(b) {
+		t.Errorf("identical entries must be equal")
+	}
+	b.Cluster = "b"
+	if a.Equal(b) {
+		t.Errorf("entries of different clusters must not be equal")
+	}
+}
+
+func TestToEntryPTR(t *testing.T) {
+	tests := []struct {
+		name    string
+		zoneRef string
+		names   []string
+		err     bool
+	}{
+		{name: "reverse name", names: []string{"5.2.0.192.in-addr.arpa"}},
+		{name: "forward name", names: []string{"www.example.org"}, err: true},
+		{name: "relative name of zone object", zoneRef: "test", names: []string{"5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &api.CoreDNSEntry{Spec: api.CoreDNSSpec{ZoneRef: tt.zoneRef, DNSNames: tt.names, PTR: []string{"host.example.org"}}}
+			o, err := ToEntry(context.Background(), nil, false)(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := o.(*Entry)
+			if (s.Error != nil) != tt.err {
+				t.Errorf("unexpected error: %v", s.Error)
+			}
+			if !slices.Equal(s.PTR, []string{"host.example.org."}) {
+				t.Errorf("unexpected PTR records %v", s.PTR)
+			}
+		})
+	}
+}
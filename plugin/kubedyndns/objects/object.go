@@ -24,6 +24,7 @@ import (
 
 const TYPE_ZONE = "HostedZone"
 const TYPE_ENTRY = "CoreDNSEntry"
+const TYPE_NAMESPACE = "Namespace"
 
 type Object interface {
 	meta.Object
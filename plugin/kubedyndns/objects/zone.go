@@ -32,7 +32,6 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
-	clientapi "github.com/mandelsoft/kubedyndns/client/clientset/versioned"
 
 	"github.com/coredns/coredns/plugin/kubernetes/object"
 )
@@ -47,6 +46,11 @@ type Zone struct {
 
 	*api.HostedZoneSpec
 
+	// QueryAccess and TransferAccess are the parsed
+	// access control lists of the spec.
+	QueryAccess    *ACL
+	TransferAccess *ACL
+
 	Status *api.HostedZoneStatus
 
 	*object.Empty
@@ -64,7 +68,7 @@ func (z *Zone) String() string {
 }
 
 // ToZone returns a client specific converter for converting an api.HostedZone to a *Zone.
-func ToZone(ctx context.Context, client clientapi.Interface, transitive bool, slave bool) func(obj meta.Object) (meta.Object, error) {
+func ToZone(ctx context.Context, client Client, transitive bool, slave bool) func(obj meta.Object) (meta.Object, error) {
 	return func(obj meta.Object) (meta.Object, error) {
 		e, ok := obj.(*api.HostedZone)
 		if !ok {
@@ -98,6 +102,18 @@ func ToZone(ctx context.Context, client clientapi.Interface, transitive bool, sl
 				s.EMail = dns.Fqdn(strings.Replace(comps[0], ".", "\\.", -1) + "." + comps[1])
 			}
 		}
+		if acl, aerr := ToACL(e.Spec.QueryACL); aerr != nil {
+			err = fmt.Errorf("invalid query ACL: %w", aerr)
+			s.QueryAccess = DenyAll
+		} else {
+			s.QueryAccess = acl
+		}
+		if acl, aerr := ToACL(e.Spec.TransferACL); aerr != nil {
+			err = fmt.Errorf("invalid transfer ACL: %w", aerr)
+			s.TransferAccess = DenyAll
+		} else {
+			s.TransferAccess = acl
+		}
 		if !transitive {
 			if e.Spec.ParentRef != "" {
 				err = fmt.Errorf("nested zones not supported in non-transitive mode")
@@ -124,7 +140,7 @@ func IsPlain(conditions []meta.Condition) bool {
 	return plain
 }
 
-func (z *Zone) UpdateStatus(ctx context.Context, client clientapi.Interface) (bool, error) {
+func (z *Zone) UpdateStatus(ctx context.Context, client Client) (bool, error) {
 	var o api.HostedZone
 
 	if z.Plain {
@@ -180,7 +196,7 @@ func (z *Zone) UpdateStatus(ctx context.Context, client clientapi.Interface) (bo
 		}
 	}
 	if mod {
-		_, err := client.CorednsV1alpha1().HostedZones(o.Namespace).UpdateStatus(ctx, &o, meta.UpdateOptions{})
+		_, err := client.UpdateZoneStatus(ctx, &o)
 		if err != nil {
 			Log.Errorf("error updating zone status %s/%s: %s", o.Namespace, o.Name, err)
 		} else {
@@ -202,6 +218,8 @@ func (z *Zone) DeepCopyObject() runtime.Object {
 	}
 	s1.Status = z.Status.DeepCopy()
 	s1.HostedZoneSpec = z.HostedZoneSpec.DeepCopy()
+	s1.QueryAccess = z.QueryAccess
+	s1.TransferAccess = z.TransferAccess
 	return s1
 }
 
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+// addressOrder determines the ordering of the address records for
+// the looked up name. The ordering of the first entry (by namespace and name)
+// declaring one is used, otherwise the ordering configured for the plugin.
+func (k *Backend) addressOrder() string {
+	if k.lookup.Exists() {
+		var found *objects.Entry
+		for _, e := range k.lookup.Node.Entries {
+			if e.Order == "" {
+				continue
+			}
+			if found == nil || strings.Compare(e.Namespace+"/"+e.Name, found.Namespace+"/"+found.Name) < 0 {
+				found = e
+			}
+		}
+		if found != nil {
+			return found.Order
+		}
+	}
+	return k.order
+}
+
+// orderAddresses reorders the A and AAAA records of an answer according to the
+// ordering for the looked up name. Other records keep their position.
+// Except for ORDER_NONE the addresses are sorted first to provide a stable base
+// independent of the order of the entries.
+func (k *Backend) orderAddresses(records []dns.RR) []dns.RR {
+	order := k.addressOrder()
+	if order == objects.ORDER_NONE || order == "" {
+		return records
+	}
+
+	var idx []int
+	var addrs []dns.RR
+	for i, rr := range records {
+		switch rr.Header().Rrtype {
+		case dns.TypeA, dns.TypeAAAA:
+			idx = append(idx, i)
+			addrs = append(addrs, rr)
+		}
+	}
+	if len(addrs) < 2 {
+		return records
+	}
+
+	slices.SortStableFunc(addrs, func(a, b dns.RR) int {
+		return bytes.Compare(address(a).To16(), address(b).To16())
+	})
+	switch order {
+	case objects.ORDER_ROTATE:
+		n := int(k.rotation.Add(1) % uint64(len(addrs)))
+		addrs = append(addrs[n:], addrs[:n]...)
+	case objects.ORDER_SHUFFLE:
+		rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	}
+
+	result := slices.Clone(records)
+	for i, n := range idx {
+		result[n] = addrs[i]
+	}
+	return result
+}
+
+func address(rr dns.RR) net.IP {
+	switch a := rr.(type) {
+	case *dns.A:
+		return a.A
+	case *dns.AAAA:
+		return a.AAAA
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/testenv"
+)
+
+var (
+	orderAddresses  = []string{"192.0.2.3", "192.0.2.1", "192.0.2.10", "192.0.2.2"}
+	sortedAddresses = []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.10"}
+)
+
+func orderEntry(name, order string, addresses ...string) *api.CoreDNSEntry {
+	return newEntry(name, api.CoreDNSSpec{DNSNames: []string{"www.example.org"}, A: addresses, Order: order})
+}
+
+// addresses queries the addresses of www.example.org in the order of the answer.
+func addresses(t *testing.T, env *testenv.Environment) []string {
+	t.Helper()
+	resp, err := env.Query("www.example.org.", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []string
+	for _, rr := range resp.Answer {
+		result = append(result, rr.(*dns.A).A.String())
+	}
+	return result
+}
+
+func rotated(s []string, n int) []string {
+	n %= len(s)
+	return append(slices.Clone(s[n:]), s[:n]...)
+}
+
+func TestOrderNone(t *testing.T) {
+	for _, stanza := range []string{"kubedyndns example.org {\n order none\n}", "kubedyndns example.org"} {
+		env := start(t, stanza, orderEntry("www", "", orderAddresses...))
+		for i := 0; i < 3; i++ {
+			if a := addresses(t, env); !slices.Equal(a, orderAddresses) {
+				t.Errorf("%q: addresses %v, expected %v", stanza, a, orderAddresses)
+			}
+		}
+	}
+}
+
+func TestOrderSorted(t *testing.T) {
+	env := start(t, "kubedyndns example.org {\n order sorted\n}", orderEntry("www", "", orderAddresses...))
+	for i := 0; i < 3; i++ {
+		if a := addresses(t, env); !slices.Equal(a, sortedAddresses) {
+			t.Errorf("addresses %v, expected %v", a, sortedAddresses)
+		}
+	}
+}
+
+func TestOrderRotate(t *testing.T) {
+	env := start(t, "kubedyndns example.org {\n order rotate\n}", orderEntry("www", "", orderAddresses...))
+
+	first := addresses(t, env)
+	n := slices.Index(sortedAddresses, first[0])
+	if n < 0 || !slices.Equal(first, rotated(sortedAddresses, n)) {
+		t.Fatalf("addresses %v are no rotation of %v", first, sortedAddresses)
+	}
+	for i := 1; i <= 2*len(sortedAddresses); i++ {
+		if a := addresses(t, env); !slices.Equal(a, rotated(sortedAddresses, n+i)) {
+			t.Errorf("query %d: addresses %v, expected %v", i, a, rotated(sortedAddresses, n+i))
+		}
+	}
+}
+
+func TestOrderShuffle(t *testing.T) {
+	env := start(t, "kubedyndns example.org {\n order shuffle\n}", orderEntry("www", "", orderAddresses...))
+
+	orders := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		a := addresses(t, env)
+		if s := slices.Sorted(slices.Values(a)); !slices.Equal(s, slices.Sorted(slices.Values(orderAddresses))) {
+			t.Fatalf("addresses %v are no permutation of %v", a, orderAddresses)
+		}
+		orders[fmt.Sprint(a)] = true
+	}
+	// the probability of a single order for 50 queries is 24^-49
+	if len(orders) < 2 {
+		t.Errorf("addresses not shuffled: %v", orders)
+	}
+}
+
+func TestOrderEntry(t *testing.T) {
+	// the order of an entry overrides the one of the plugin
+	env := start(t, "kubedyndns example.org {\n order rotate\n}", orderEntry("www", "sorted", orderAddresses...))
+	for i := 0; i < 3; i++ {
+		if a := addresses(t, env); !slices.Equal(a, sortedAddresses) {
+			t.Errorf("addresses %v, expected %v", a, sortedAddresses)
+		}
+	}
+
+	// the order of the first entry declaring one is used
+	// for the addresses of all entries
+	env = start(t, "kubedyndns example.org {\n order rotate\n}",
+		orderEntry("a", "", "192.0.2.3", "192.0.2.1"),
+		orderEntry("b", "sorted", "192.0.2.10"),
+		orderEntry("c", "none", "192.0.2.2"),
+	)
+	for i := 0; i < 3; i++ {
+		if a := addresses(t, env); !slices.Equal(a, sortedAddresses) {
+			t.Errorf("addresses %v, expected %v", a, sortedAddresses)
+		}
+	}
+}
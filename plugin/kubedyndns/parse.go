@@ -52,7 +52,8 @@ func parseRequest(name, zone string) (*recordRequest, error) {
 	segs := dns.SplitDomainName(base)
 	last := len(segs) - 1
 	if last < 0 {
-		return nil, errInvalidRequest
+		// zone apex
+		return &recordRequest{}, nil
 	}
 	// return NODATA for apex queries
 	if segs[0] == "_tcp" || segs[0] == "_upd" {
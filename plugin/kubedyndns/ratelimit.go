@@ -0,0 +1,211 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// rrlTableSize is the table size triggering the cleanup of
+// idle rate limiting buckets.
+const rrlTableSize = 10000
+
+// client prefix lengths used to aggregate client addresses.
+const (
+	rrlIPv4Prefix = 24
+	rrlIPv6Prefix = 56
+)
+
+// defaultSlip is the default slip rate for rate limited responses.
+const defaultSlip = 2
+
+// response kinds used for the rate limiting.
+const (
+	rrlAnswer   = "answer"
+	rrlNoData   = "nodata"
+	rrlNXDomain = "nxdomain"
+	rrlReferral = "referral"
+	rrlError    = "error"
+)
+
+// rateLimit configures the response rate limiting.
+// A rate of 0 disables the rate limiting.
+type rateLimit struct {
+	rate int
+	slip int
+}
+
+// rateLimiter implements the response rate limiting with
+// a token bucket per client network, response kind and zone.
+type rateLimiter struct {
+	lock    sync.Mutex
+	buckets map[rateKey]*rateBucket
+}
+
+type rateKey struct {
+	prefix string
+	kind   string
+	zone   string
+}
+
+type rateBucket struct {
+	tokens  float64
+	last    time.Time
+	dropped int
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: map[rateKey]*rateBucket{}}
+}
+
+// rateAction is the result of a rate limiting check.
+type rateAction int
+
+const (
+	rateSend rateAction = iota
+	rateDrop
+	rateSlip
+)
+
+// check accounts a response and determines, whether it should be sent,
+// dropped or sent as truncated response.
+func (r *rateLimiter) check(key rateKey, cfg rateLimit, now time.Time) rateAction {
+	rate := float64(cfg.rate)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	b := r.buckets[key]
+	if b == nil {
+		if len(r.buckets) >= rrlTableSize {
+			r.cleanup(now)
+		}
+		b = &rateBucket{tokens: rate, last: now}
+		r.buckets[key] = b
+	} else {
+		b.tokens = min(rate, b.tokens+now.Sub(b.last).Seconds()*rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return rateSend
+	}
+	b.dropped++
+	if cfg.slip > 0 && b.dropped%cfg.slip == 0 {
+		return rateSlip
+	}
+	return rateDrop
+}
+
+// cleanup removes all buckets, which have been idle long enough
+// to be refilled completely.
+func (r *rateLimiter) cleanup(now time.Time) {
+	for k, b := range r.buckets {
+		if now.Sub(b.last) >= time.Second {
+			delete(r.buckets, k)
+		}
+	}
+}
+
+// rateLimitFor determines the rate limiting for a zone.
+// The settings of a zone object take precedence over
+// the ones configured for the plugin.
+func (k *KubeDynDNS) rateLimitFor(zi *ZoneInfo) rateLimit {
+	if zi != nil && zi.Object != nil && zi.Object.RateLimit != nil {
+		cfg := rateLimit{rate: zi.Object.RateLimit.ResponsesPerSecond, slip: defaultSlip}
+		if zi.Object.RateLimit.Slip != nil {
+			cfg.slip = *zi.Object.RateLimit.Slip
+		}
+		return cfg
+	}
+	return k.rrl
+}
+
+// writeMsg writes a response to the client obeying the response rate limiting.
+// The response is completed with the EDNS0 options and truncated
+// to the buffer size of the client.
+// Responses via TCP and for clients with a valid server cookie are not limited.
+func (k *KubeDynDNS) writeMsg(ctx context.Context, zi *ZoneInfo, state request.Request, m *dns.Msg) {
+	verified := k.prepareEDNS(state, m)
+	m = state.Scrub(m)
+
+	cfg := k.rateLimitFor(zi)
+	if cfg.rate <= 0 || state.Proto() == "tcp" || verified {
+		state.W.WriteMsg(m)
+		return
+	}
+
+	zone := "."
+	if zi != nil {
+		zone = strings.ToLower(zi.DomainName)
+	}
+	key := rateKey{prefix: clientPrefix(state.IP()), kind: responseKind(m), zone: zone}
+	switch k.limiter.check(key, cfg, time.Now()) {
+	case rateSend:
+		state.W.WriteMsg(m)
+	case rateSlip:
+		rrlSlipped.WithLabelValues(metrics.WithServer(ctx), zone, key.kind).Inc()
+		tc := new(dns.Msg)
+		tc.SetReply(state.Req)
+		tc.Rcode = m.Rcode
+		tc.Authoritative = m.Authoritative
+		tc.Truncated = true
+		k.prepareEDNS(state, tc)
+		state.W.WriteMsg(tc)
+	default:
+		rrlDropped.WithLabelValues(metrics.WithServer(ctx), zone, key.kind).Inc()
+	}
+}
+
+// responseKind classifies a response for the rate limiting.
+func responseKind(m *dns.Msg) string {
+	switch {
+	case m.Rcode == dns.RcodeNameError:
+		return rrlNXDomain
+	case m.Rcode != dns.RcodeSuccess:
+		return rrlError
+	case len(m.Answer) > 0:
+		return rrlAnswer
+	case !m.Authoritative && len(m.Ns) > 0:
+		return rrlReferral
+	default:
+		return rrlNoData
+	}
+}
+
+// clientPrefix returns the network of a client address used
+// to aggregate the clients for the rate limiting.
+func clientPrefix(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(rrlIPv4Prefix, 8*net.IPv4len)).String()
+	}
+	return ip.Mask(net.CIDRMask(rrlIPv6Prefix, 8*net.IPv6len)).String()
+}
@@ -0,0 +1,275 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+func actions(r *rateLimiter, key rateKey, cfg rateLimit, now time.Time, n int) []rateAction {
+	var result []rateAction
+	for i := 0; i < n; i++ {
+		result = append(result, r.check(key, cfg, now))
+	}
+	return result
+}
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	r := newRateLimiter()
+	key := rateKey{prefix: "10.0.0.0", kind: rrlAnswer, zone: "example.org."}
+	cfg := rateLimit{rate: 3}
+	now := time.Now()
+
+	// the initial bucket allows a burst of rate responses
+	if a := actions(r, key, cfg, now, 4); !slices.Equal(a, []rateAction{rateSend, rateSend, rateSend, rateDrop}) {
+		t.Errorf("initial burst %v", a)
+	}
+	// tokens are refilled proportional to the elapsed time
+	now = now.Add(500 * time.Millisecond)
+	if a := actions(r, key, cfg, now, 2); !slices.Equal(a, []rateAction{rateSend, rateDrop}) {
+		t.Errorf("after 500ms %v", a)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if a := actions(r, key, cfg, now, 2); !slices.Equal(a, []rateAction{rateSend, rateSend}) {
+		t.Errorf("after 1s %v", a)
+	}
+	// the bucket is limited to rate tokens
+	now = now.Add(time.Minute)
+	if a := actions(r, key, cfg, now, 4); !slices.Equal(a, []rateAction{rateSend, rateSend, rateSend, rateDrop}) {
+		t.Errorf("after idle period %v", a)
+	}
+
+	// buckets are separated by client network, response kind and zone
+	for _, other := range []rateKey{
+		{prefix: "10.0.1.0", kind: rrlAnswer, zone: "example.org."},
+		{prefix: "10.0.0.0", kind: rrlNXDomain, zone: "example.org."},
+		{prefix: "10.0.0.0", kind: rrlAnswer, zone: "example.com."},
+	} {
+		if a := r.check(other, cfg, now); a != rateSend {
+			t.Errorf("%+v: %v", other, a)
+		}
+	}
+}
+
+func TestRateLimiterSlip(t *testing.T) {
+	for _, slip := range []int{0, 1, 2, 3} {
+		t.Run(fmt.Sprintf("slip %d", slip), func(t *testing.T) {
+			r := newRateLimiter()
+			key := rateKey{prefix: "10.0.0.0", kind: rrlAnswer, zone: "example.org."}
+			cfg := rateLimit{rate: 1, slip: slip}
+			now := time.Now()
+
+			expected := []rateAction{rateSend}
+			for i := 1; i <= 6; i++ {
+				if slip > 0 && i%slip == 0 {
+					expected = append(expected, rateSlip)
+				} else {
+					expected = append(expected, rateDrop)
+				}
+			}
+			if a := actions(r, key, cfg, now, 7); !slices.Equal(a, expected) {
+				t.Errorf("actions %v, expected %v", a, expected)
+			}
+		})
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	r := newRateLimiter()
+	cfg := rateLimit{rate: 1}
+	now := time.Now()
+
+	for i := 0; i < rrlTableSize-1; i++ {
+		r.check(rateKey{prefix: fmt.Sprintf("10.%d.%d.0", i/256, i%256)}, cfg, now)
+	}
+	active := rateKey{prefix: "10.255.0.0"}
+	r.check(active, cfg, now.Add(500*time.Millisecond))
+	if len(r.buckets) != rrlTableSize {
+		t.Fatalf("%d buckets", len(r.buckets))
+	}
+
+	// idle buckets are removed when the table is full
+	r.check(rateKey{prefix: "10.255.1.0"}, cfg, now.Add(time.Second))
+	if len(r.buckets) != 2 || r.buckets[active] == nil {
+		t.Errorf("%d buckets after cleanup", len(r.buckets))
+	}
+}
+
+func TestClientPrefix(t *testing.T) {
+	tests := []struct{ addr, prefix string }{
+		{"192.0.2.17", "192.0.2.0"},
+		{"::ffff:192.0.2.17", "192.0.2.0"},
+		{"2001:db8:1:2ff::1", "2001:db8:1:200::"},
+		{"invalid", "invalid"},
+	}
+	for _, tc := range tests {
+		if p := clientPrefix(tc.addr); p != tc.prefix {
+			t.Errorf("%s: prefix %s, expected %s", tc.addr, p, tc.prefix)
+		}
+	}
+}
+
+func TestResponseKind(t *testing.T) {
+	a := test.A("www.example.org. 10 IN A 192.0.2.1")
+	ns := test.NS("sub.example.org. 10 IN NS ns.sub.example.org.")
+	soa := test.SOA("example.org. 10 IN SOA ns.example.org. hostmaster.example.org. 1 7200 1800 86400 10")
+	tests := []struct {
+		name string
+		msg  *dns.Msg
+		kind string
+	}{
+		{"answer", &dns.Msg{MsgHdr: dns.MsgHdr{Authoritative: true}, Answer: []dns.RR{a}}, rrlAnswer},
+		{"nodata", &dns.Msg{MsgHdr: dns.MsgHdr{Authoritative: true}, Ns: []dns.RR{soa}}, rrlNoData},
+		{"nxdomain", &dns.Msg{MsgHdr: dns.MsgHdr{Authoritative: true, Rcode: dns.RcodeNameError}, Ns: []dns.RR{soa}}, rrlNXDomain},
+		{"referral", &dns.Msg{Ns: []dns.RR{ns}}, rrlReferral},
+		{"error", &dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeRefused}}, rrlError},
+	}
+	for _, tc := range tests {
+		if k := responseKind(tc.msg); k != tc.kind {
+			t.Errorf("%s: kind %s, expected %s", tc.name, k, tc.kind)
+		}
+	}
+}
+
+func TestRateLimitFor(t *testing.T) {
+	k := New([]string{"example.org."})
+	k.rrl = rateLimit{rate: 10, slip: 3}
+	zone := func(rl *api.RateLimit) *ZoneInfo {
+		return NewZoneInfo("example.org.", &objects.Zone{HostedZoneSpec: &api.HostedZoneSpec{RateLimit: rl}})
+	}
+	slip := 0
+
+	tests := []struct {
+		name string
+		zi   *ZoneInfo
+		cfg  rateLimit
+	}{
+		{"no zone", nil, rateLimit{rate: 10, slip: 3}},
+		{"zone without object", NewZoneInfo("example.org.", nil), rateLimit{rate: 10, slip: 3}},
+		{"zone without rate limit", zone(nil), rateLimit{rate: 10, slip: 3}},
+		{"zone rate limit", zone(&api.RateLimit{ResponsesPerSecond: 5}), rateLimit{rate: 5, slip: defaultSlip}},
+		{"zone slip", zone(&api.RateLimit{ResponsesPerSecond: 5, Slip: &slip}), rateLimit{rate: 5, slip: 0}},
+		{"zone disables rate limit", zone(&api.RateLimit{}), rateLimit{rate: 0, slip: defaultSlip}},
+	}
+	for _, tc := range tests {
+		if cfg := k.rateLimitFor(tc.zi); cfg != tc.cfg {
+			t.Errorf("%s: %+v, expected %+v", tc.name, cfg, tc.cfg)
+		}
+	}
+}
+
+// written sends a response for the given request via writeMsg and
+// returns the written message, nil if the response has been dropped.
+func written(k *KubeDynDNS, w dns.ResponseWriter, req *dns.Msg) *dns.Msg {
+	state := request.Request{W: w, Req: req}
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Authoritative = true
+	m.Answer = []dns.RR{test.A("www.example.org. 10 IN A 192.0.2.1")}
+
+	rec := dnstest.NewRecorder(w)
+	state.W = rec
+	k.writeMsg(context.Background(), NewZoneInfo("example.org.", nil), state, m)
+	return rec.Msg
+}
+
+func query() *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	return m
+}
+
+func TestWriteMsgRateLimit(t *testing.T) {
+	k := New([]string{"example.org."})
+	k.rrl = rateLimit{rate: 1, slip: 2}
+
+	if m := written(k, &test.ResponseWriter{}, query()); m == nil || len(m.Answer) != 1 || m.Truncated {
+		t.Fatalf("first response not sent: %v", m)
+	}
+	if m := written(k, &test.ResponseWriter{}, query()); m != nil {
+		t.Errorf("response not dropped: %v", m)
+	}
+	m := written(k, &test.ResponseWriter{}, query())
+	if m == nil || !m.Truncated || len(m.Answer) != 0 || !m.Authoritative {
+		t.Errorf("no truncated response: %v", m)
+	}
+
+	// TCP responses are not limited, because the client address is verified
+	for i := 0; i < 3; i++ {
+		if m := written(k, &test.ResponseWriter{TCP: true}, query()); m == nil || len(m.Answer) != 1 {
+			t.Errorf("TCP response limited: %v", m)
+		}
+	}
+
+	// other clients are not affected
+	if m := written(k, &test.ResponseWriter{RemoteIP: "192.0.2.1"}, query()); m == nil || len(m.Answer) != 1 {
+		t.Errorf("response for other client limited: %v", m)
+	}
+}
+
+func cookieQuery(cc, sc []byte) *dns.Msg {
+	m := query()
+	m.SetEdns0(1232, false)
+	o := m.IsEdns0()
+	o.Option = append(o.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: hex.EncodeToString(slices.Concat(cc, sc))})
+	return m
+}
+
+func TestWriteMsgValidCookie(t *testing.T) {
+	k := New([]string{"example.org."})
+	k.rrl = rateLimit{rate: 1, slip: 0}
+	k.cookieSecret = []byte("secret")
+
+	cc := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	valid := k.serverCookie(cc, net.ParseIP("10.240.0.1"), uint32(time.Now().Unix()))
+	invalid := k.serverCookie(cc, net.ParseIP("10.240.0.2"), uint32(time.Now().Unix()))
+
+	written(k, &test.ResponseWriter{}, cookieQuery(cc, nil))
+	if m := written(k, &test.ResponseWriter{}, cookieQuery(cc, nil)); m != nil {
+		t.Errorf("response without server cookie not dropped: %v", m)
+	}
+	if m := written(k, &test.ResponseWriter{}, cookieQuery(cc, invalid)); m != nil {
+		t.Errorf("response with invalid server cookie not dropped: %v", m)
+	}
+	for i := 0; i < 3; i++ {
+		if m := written(k, &test.ResponseWriter{}, cookieQuery(cc, valid)); m == nil || len(m.Answer) != 1 {
+			t.Errorf("response with valid server cookie limited: %v", m)
+		}
+	}
+
+	// without enabled cookies, server cookies are not verified
+	k.cookieSecret = nil
+	if m := written(k, &test.ResponseWriter{}, cookieQuery(cc, valid)); m != nil {
+		t.Errorf("response not dropped: %v", m)
+	}
+}
@@ -18,5 +18,41 @@
 
 package kubedyndns
 
+import (
+	"fmt"
+	"time"
+)
+
 // Ready implements the ready.Readiness interface.
 func (k *KubeDynDNS) Ready() bool { return k.APIConn.HasSynced() }
+
+// WaitForSync waits until the controller is synced and all pending
+// changes are processed or the timeout is reached. Changes are
+// considered processed if no reconciliation is pending and no further
+// events have been processed since the previous check.
+func (k *KubeDynDNS) WaitForSync(timeout time.Duration) error {
+	limit := time.After(timeout)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	c := k.APIConn
+	last := uint64(0)
+	settled := false
+	for {
+		select {
+		case <-ticker.C:
+			g := c.Generation()
+			if c.HasSynced() && c.Pending() == 0 {
+				if settled && g == last {
+					return nil
+				}
+				settled = true
+			} else {
+				settled = false
+			}
+			last = g
+		case <-limit:
+			return fmt.Errorf("controller not synced after %s", timeout)
+		}
+	}
+}
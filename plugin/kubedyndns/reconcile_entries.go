@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"slices"
 
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
 	"k8s.io/client-go/tools/cache"
 )
@@ -89,6 +90,9 @@ func (cntr *controller) reconcileEntry(key cache.ObjectName, no int) error {
 			return e.UpdateStatus(cntr.ctx, cntr.client, zone, names, fmt.Errorf("zone failure: %s", z.Status.Message))
 		}
 		zone = root.Name
+		if err := validateReverseNames(e, names); err != nil {
+			return e.UpdateStatus(cntr.ctx, cntr.client, zone, names, err)
+		}
 	} else {
 		if cntr.zoneRef != nil {
 			return nil
@@ -103,3 +107,18 @@ func (cntr *controller) reconcileEntry(key cache.ObjectName, no int) error {
 func (cntr *controller) enqueueEntry(key cache.ObjectName) {
 	cntr.queue.Add(NewRequestKey(objects.TYPE_ENTRY, key.Namespace, key.Name))
 }
+
+// validateReverseNames checks that PTR records are only used
+// with domain names in a reverse zone. The names must already be
+// resolved against the zone of the entry.
+func validateReverseNames(e *objects.Entry, names []string) error {
+	if len(e.PTR) == 0 {
+		return nil
+	}
+	for _, n := range names {
+		if dnsutil.IsReverse(n) == 0 {
+			return fmt.Errorf("PTR records require DNS names in a reverse zone (%s)", n)
+		}
+	}
+	return nil
+}
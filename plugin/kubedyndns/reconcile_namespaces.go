@@ -0,0 +1,65 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+// maxWithdrawRetries limits the retries for marking the
+// entries of a deselected namespace before they are removed.
+const maxWithdrawRetries = 5
+
+// reconcileNamespace withdraws the entries of a namespace, which is
+// not selected anymore, and removes its objects from the served objects.
+func (cntr *controller) reconcileNamespace(name string, no int) error {
+	if cntr.selectedNamespace(name) {
+		return nil
+	}
+	Log.Infof("reconcile deselected namespace %q", name)
+
+	var errs []error
+	msg := fmt.Sprintf("namespace %s not selected", name)
+	for _, o := range cntr.entryLister.List() {
+		e := o.(*objects.Entry)
+		if e.Namespace != name || !cntr.maintainsEntry(e) {
+			continue
+		}
+		if err := e.Withdraw(cntr.ctx, cntr.client, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 && cntr.queue.NumRequeues(NewRequestKey(objects.TYPE_NAMESPACE, "", name)) < maxWithdrawRetries {
+		return errors.Join(errs...)
+	}
+	cntr.resync()
+	return nil
+}
+
+// maintainsEntry reports whether the status of an entry
+// is maintained by this controller.
+func (cntr *controller) maintainsEntry(e *objects.Entry) bool {
+	if cntr.zoneRef != nil {
+		return e.ZoneRef != "" && e.Namespace == cntr.zoneRef.Namespace && e.Status.RootZone == cntr.zoneRef.Name
+	}
+	return e.ZoneRef == ""
+}
@@ -132,7 +132,11 @@ func aggregateNames(z *objects.Zone, names *[]string) {
 
 	for _, n := range *names {
 		for _, suf := range z.DomainNames {
-			result = append(result, dns.Fqdn(n)+dns.Fqdn(suf))
+			if n == "." {
+				result = append(result, dns.Fqdn(suf))
+			} else {
+				result = append(result, dns.Fqdn(n)+dns.Fqdn(suf))
+			}
 		}
 	}
 	*names = result
@@ -20,38 +20,168 @@ package kubedyndns
 
 import (
 	"context"
+	"net"
+	"slices"
+	"strings"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/etcd/msg"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
 )
 
 // Reverse implements the ServiceBackend interface.
+// Explicit PTR records of entries for the requested name take precedence
+// over the records generated for the entries using the requested address.
 func (k *Backend) Reverse(ctx context.Context, state request.Request, exact bool, opt plugin.Options) ([]msg.Service, error) {
+	var svcs []msg.Service
 
-	ip := dnsutil.ExtractAddressFromReverse(state.Name())
-	if ip == "" {
-		_, e := k.Records(ctx, state, exact)
-		return nil, e
+	if k.lookup.Exists() && dnsutil.IsReverse(k.zoneInfo.DomainName) > 0 {
+		for _, e := range k.lookup.Node.Entries {
+			svcs = append(svcs, e.Services(dns.TypePTR, "", k.ttl, k.zoneInfo.DomainName)...)
+		}
 	}
-
-	records := k.serviceRecordForIP(ip, state.Name())
-	if len(records) == 0 {
-		return records, errNoItems
+	if len(svcs) == 0 {
+		svcs = k.serviceRecordForIP(dnsutil.ExtractAddressFromReverse(state.Name()), state.Name())
 	}
-	return records, nil
+	if len(svcs) == 0 && !k.nameExists(state) {
+		return nil, errNoItems
+	}
+	return svcs, nil
 }
 
-// serviceRecordForIP gets a service record with a cluster ip matching the ip argument
-// If a service cluster ip does not match, it checks all endpoints
+// serviceRecordForIP gets the service records for all domain names
+// of the entries with an address matching the ip argument.
 func (k *Backend) serviceRecordForIP(ip, name string) []msg.Service {
-	// First check services with cluster ips
-	for _, service := range k.APIConn.EntryIPIndex(ip) {
-		if len(service.DNSNames) > 0 {
-			domain := service.DNSNames[0]
-			return []msg.Service{{Host: domain, TTL: k.ttl}}
+	var svcs []msg.Service
+	for _, ptr := range k.reverseNames(ip) {
+		svcs = append(svcs, msg.Service{Host: ptr.name, TTL: ptr.ttl})
+	}
+	return svcs
+}
+
+// nameExists reports whether the requested name exists. Besides the names
+// of entries, in reverse zones this are the addresses with generated PTR
+// records and the empty non-terminals above them.
+func (k *Backend) nameExists(state request.Request) bool {
+	if k.lookup.Exists() {
+		return true
+	}
+	if dnsutil.IsReverse(k.zoneInfo.DomainName) == 0 {
+		return false
+	}
+	if ip := dnsutil.ExtractAddressFromReverse(state.Name()); ip != "" {
+		return len(k.reverseNames(ip)) > 0
+	}
+	return k.reverseNonTerminal(state.Name())
+}
+
+// reverseNonTerminal checks whether a name in a reverse zone is an
+// ancestor of the owner of a generated PTR record.
+func (k *KubeDynDNS) reverseNonTerminal(name string) bool {
+	for _, ip := range k.APIConn.EntryIPs() {
+		if net.ParseIP(ip) == nil {
+			// CNAME targets
+			continue
+		}
+		owner, err := dns.ReverseAddr(ip)
+		if err != nil || !dns.IsSubDomain(name, owner) {
+			continue
+		}
+		if len(k.reverseNames(ip)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// reverseZone determines the served reverse zone for a domain name.
+// It returns nil, if the name is not in a served reverse zone.
+// In Primary mode the root zone object is used to provide the
+// zone data.
+func (k *KubeDynDNS) reverseZone(qname string) *ZoneInfo {
+	zone := plugin.Zones(k.ReverseZones).Matches(qname)
+	if zone == "" {
+		return nil
+	}
+	var zo *objects.Zone
+	if k.zoneRef != nil {
+		zo = k.APIConn.GetZone(*k.zoneRef)
+	}
+	zone = qname[len(qname)-len(zone):] // maintain case of original query
+	zi := NewZoneInfo(zone, zo)
+	zi.Reverse = true
+	return zi
+}
+
+// reverseName is the target of a PTR record.
+type reverseName struct {
+	name string
+	ttl  uint32
+}
+
+// reverseNames determines the fully qualified domain names of all entries
+// using the given address, which are served by this plugin instance.
+// Entries may opt out from the PTR generation.
+func (k *KubeDynDNS) reverseNames(ip string) []reverseName {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil
+	}
+
+	var result []reverseName
+	for _, e := range k.APIConn.EntryIPIndex(addr.String()) {
+		if e.NoReverse || !hasAddress(e, addr) {
+			continue
+		}
+		ttl := k.TTL(e.Ttl)
+		for _, n := range k.APIConn.EffectiveDomainNames(e) {
+			for _, fqdn := range k.forwardNames(n) {
+				if !slices.ContainsFunc(result, func(r reverseName) bool { return strings.EqualFold(r.name, fqdn) }) {
+					result = append(result, reverseName{name: fqdn, ttl: ttl})
+				}
+			}
+		}
+	}
+	slices.SortFunc(result, func(a, b reverseName) int { return strings.Compare(a.name, b.name) })
+	return result
+}
+
+// forwardNames completes an effective domain name of an entry with the
+// served forward zones. In FilterByZones mode names are already absolute
+// and are used, if they belong to a served zone.
+func (k *KubeDynDNS) forwardNames(name string) []string {
+	if k.filtered {
+		if plugin.Zones(k.ServedZones).Matches(name) == "" {
+			return nil
+		}
+		return []string{name}
+	}
+	var result []string
+	for _, z := range k.ServedZones {
+		switch {
+		case name == ".":
+			result = append(result, z)
+		case z == ".":
+			result = append(result, dns.Fqdn(name))
+		default:
+			result = append(result, dns.Fqdn(name)+z)
+		}
+	}
+	return result
+}
+
+// hasAddress checks whether an entry uses the given address for an A or AAAA record.
+func hasAddress(e *objects.Entry, addr net.IP) bool {
+	for _, a := range append(slices.Clone(e.A), e.AAAA...) {
+		if addr.Equal(net.ParseIP(a)) {
+			return true
 		}
 	}
-	return nil
+	return false
 }
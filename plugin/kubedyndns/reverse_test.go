@@ -0,0 +1,186 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/filesource"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/testenv"
+)
+
+const reverseStanza = "kubedyndns example.org 2.0.192.in-addr.arpa 8.b.d.0.1.0.0.2.ip6.arpa {\n mode Subdomains\n}"
+
+const ip6Reverse = "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."
+
+var reverseSOA = test.SOA("2.0.192.in-addr.arpa. 10 IN SOA ns.dns.2.0.192.in-addr.arpa. hostmaster.2.0.192.in-addr.arpa. 0 7200 1800 86400 10")
+
+var reverseEntries = []*api.CoreDNSEntry{
+	newEntry("www", api.CoreDNSSpec{DNSNames: []string{"www"}, A: []string{"192.0.2.1"}, AAAA: []string{"2001:db8::1"}}),
+	newEntry("mail", api.CoreDNSSpec{DNSNames: []string{"mail"}, A: []string{"192.0.2.1"}}),
+	newEntry("hidden", api.CoreDNSSpec{DNSNames: []string{"hidden"}, A: []string{"192.0.2.2"}, NoReverse: true}),
+}
+
+func TestReverseGenerated(t *testing.T) {
+	env := start(t, reverseStanza, reverseEntries...)
+
+	check(t, env,
+		// a record for every name using the address
+		test.Case{Qname: "1.2.0.192.in-addr.arpa.", Qtype: dns.TypePTR, Answer: []dns.RR{
+			test.PTR("1.2.0.192.in-addr.arpa. 10 IN PTR mail.example.org."),
+			test.PTR("1.2.0.192.in-addr.arpa. 10 IN PTR www.example.org."),
+		}},
+		test.Case{Qname: ip6Reverse, Qtype: dns.TypePTR, Answer: []dns.RR{
+			test.PTR(ip6Reverse + " 10 IN PTR www.example.org."),
+		}},
+		// other types for a used address
+		test.Case{Qname: "1.2.0.192.in-addr.arpa.", Qtype: dns.TypeA, Ns: []dns.RR{reverseSOA}},
+		// unused address
+		test.Case{Qname: "9.2.0.192.in-addr.arpa.", Qtype: dns.TypePTR, Rcode: dns.RcodeNameError, Ns: []dns.RR{reverseSOA}},
+	)
+}
+
+func TestReverseInvalidName(t *testing.T) {
+	env := start(t, reverseStanza, reverseEntries...)
+
+	ip6SOA := test.SOA("8.b.d.0.1.0.0.2.ip6.arpa. 10 IN SOA ns.dns.8.b.d.0.1.0.0.2.ip6.arpa. hostmaster.8.b.d.0.1.0.0.2.ip6.arpa. 0 7200 1800 86400 10")
+	check(t, env,
+		// names not describing an address do not exist
+		test.Case{Qname: "foo.2.0.192.in-addr.arpa.", Qtype: dns.TypePTR, Rcode: dns.RcodeNameError, Ns: []dns.RR{reverseSOA}},
+		test.Case{Qname: "1.1.2.0.192.in-addr.arpa.", Qtype: dns.TypePTR, Rcode: dns.RcodeNameError, Ns: []dns.RR{reverseSOA}},
+		// empty non-terminals above a generated record exist
+		test.Case{Qname: "0.8.b.d.0.1.0.0.2.ip6.arpa.", Qtype: dns.TypePTR, Ns: []dns.RR{ip6SOA}},
+		test.Case{Qname: "f.8.b.d.0.1.0.0.2.ip6.arpa.", Qtype: dns.TypePTR, Rcode: dns.RcodeNameError, Ns: []dns.RR{ip6SOA}},
+		// the zone apex exists
+		test.Case{Qname: "2.0.192.in-addr.arpa.", Qtype: dns.TypePTR, Ns: []dns.RR{reverseSOA}},
+	)
+}
+
+func TestReverseNoReverse(t *testing.T) {
+	env := start(t, reverseStanza, reverseEntries...)
+
+	check(t, env,
+		test.Case{Qname: "2.2.0.192.in-addr.arpa.", Qtype: dns.TypePTR, Rcode: dns.RcodeNameError, Ns: []dns.RR{reverseSOA}},
+		// the forward records are not affected
+		test.Case{Qname: "hidden.example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+			test.A("hidden.example.org. 10 IN A 192.0.2.2"),
+		}},
+	)
+}
+
+func TestReverseExplicit(t *testing.T) {
+	env := start(t, reverseStanza, append(reverseEntries,
+		newEntry("explicit", api.CoreDNSSpec{DNSNames: []string{"1.2.0.192.in-addr.arpa"}, PTR: []string{"custom.example.org"}}),
+		newEntry("unused", api.CoreDNSSpec{DNSNames: []string{"7.2.0.192.in-addr.arpa"}, PTR: []string{"unused.example.org."}}),
+	)...)
+
+	check(t, env,
+		// explicit records take precedence over the generated ones
+		test.Case{Qname: "1.2.0.192.in-addr.arpa.", Qtype: dns.TypePTR, Answer: []dns.RR{
+			test.PTR("1.2.0.192.in-addr.arpa. 10 IN PTR custom.example.org."),
+		}},
+		test.Case{Qname: "7.2.0.192.in-addr.arpa.", Qtype: dns.TypePTR, Answer: []dns.RR{
+			test.PTR("7.2.0.192.in-addr.arpa. 10 IN PTR unused.example.org."),
+		}},
+		test.Case{Qname: ip6Reverse, Qtype: dns.TypePTR, Answer: []dns.RR{
+			test.PTR(ip6Reverse + " 10 IN PTR www.example.org."),
+		}},
+	)
+}
+
+// startPrimary runs a plugin instance in mode Primary for the zone
+// object test with the given domain name.
+func startPrimary(t *testing.T, domain string, objs ...runtime.Object) *testenv.Environment {
+	t.Helper()
+	zone := &api.HostedZone{
+		ObjectMeta: metav1.ObjectMeta{Namespace: filesource.DefaultNamespace, Name: "test"},
+		Spec: api.HostedZoneSpec{
+			DomainNames: []string{domain},
+			EMail:       "hostmaster@example.org",
+			Refresh:     7200,
+			Retry:       3600,
+			Expire:      1209600,
+			MinimumTTL:  600,
+		},
+	}
+	env, err := testenv.New("kubedyndns . {\n mode Primary\n zoneobject test\n namespaces default\n}", append(objs, zone)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { env.Stop() })
+	return env
+}
+
+func TestReversePrimary(t *testing.T) {
+	env := startPrimary(t, "2.0.192.in-addr.arpa",
+		newEntry("host", api.CoreDNSSpec{ZoneRef: "test", DNSNames: []string{"5"}, PTR: []string{"host.example.org."}}),
+	)
+
+	check(t, env, test.Case{Qname: "5.2.0.192.in-addr.arpa.", Qtype: dns.TypePTR, Answer: []dns.RR{
+		test.PTR("5.2.0.192.in-addr.arpa. 10 IN PTR host.example.org."),
+	}})
+	if msg := entryMessage(t, env, "host"); msg != "" {
+		t.Errorf("unexpected entry message %q", msg)
+	}
+}
+
+func TestReversePrimaryForward(t *testing.T) {
+	env := startPrimary(t, "example.org",
+		newEntry("host", api.CoreDNSSpec{ZoneRef: "test", DNSNames: []string{"host"}, PTR: []string{"other.example.org."}}),
+	)
+
+	resp, err := env.Query("host.example.org.", dns.TypePTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Answer) != 0 {
+		t.Errorf("unexpected answer %v", resp.Answer)
+	}
+	if msg := entryMessage(t, env, "host"); !strings.Contains(msg, "PTR records require DNS names in a reverse zone (host.example.org.)") {
+		t.Errorf("unexpected entry message %q", msg)
+	}
+}
+
+// entryMessage returns the message of the server condition of an entry.
+func entryMessage(t *testing.T, env *testenv.Environment, name string) string {
+	t.Helper()
+	e, err := env.Client.CorednsV1alpha1().CoreDNSEntries(filesource.DefaultNamespace).Get(env.Context(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Status.Message != "" {
+		return e.Status.Message
+	}
+	for _, c := range e.Status.Conditions {
+		if c.Type == api.ServerConditionType {
+			return c.Message
+		}
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns_test
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/filesource"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/testenv"
+)
+
+var exampleSOA = test.SOA("example.org. 10 IN SOA ns.dns.example.org. hostmaster.example.org. 0 7200 1800 86400 10")
+
+func TestEmptyNonTerminal(t *testing.T) {
+	env := start(t, "kubedyndns example.org",
+		newEntry("host", api.CoreDNSSpec{DNSNames: []string{"host.ent.example.org"}, A: []string{"192.0.2.1"}}),
+	)
+
+	check(t, env,
+		test.Case{Qname: "host.ent.example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+			test.A("host.ent.example.org. 10 IN A 192.0.2.1"),
+		}},
+		// the empty non-terminal exists without records
+		test.Case{Qname: "ent.example.org.", Qtype: dns.TypeA, Ns: []dns.RR{exampleSOA}},
+		test.Case{Qname: "ent.example.org.", Qtype: dns.TypeTXT, Ns: []dns.RR{exampleSOA}},
+		// names below it do not exist
+		test.Case{Qname: "other.ent.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{exampleSOA}},
+		test.Case{Qname: "sub.host.ent.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{exampleSOA}},
+	)
+}
+
+func TestApexNS(t *testing.T) {
+	zone := &api.HostedZone{
+		ObjectMeta: metav1.ObjectMeta{Namespace: filesource.DefaultNamespace, Name: "test"},
+		Spec: api.HostedZoneSpec{
+			DomainNames: []string{"example.org"},
+			EMail:       "hostmaster@example.org",
+			Refresh:     7200,
+			Retry:       3600,
+			Expire:      1209600,
+			MinimumTTL:  600,
+		},
+		Status: api.HostedZoneStatus{NameServers: []string{"ns1.example.org", "ns.example.net"}},
+	}
+	env, err := testenv.New("kubedyndns . {\n mode Primary\n zoneobject test\n namespaces default\n}", zone,
+		newEntry("ns1", api.CoreDNSSpec{ZoneRef: "test", DNSNames: []string{"ns1"}, A: []string{"192.0.2.53"}}),
+		newEntry("www", api.CoreDNSSpec{ZoneRef: "test", DNSNames: []string{"www"}, A: []string{"192.0.2.1"}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer env.Stop()
+
+	soa := test.SOA("example.org. 600 IN SOA ns1.example.org. hostmaster.example.org. 0 7200 3600 1209600 600")
+	check(t, env,
+		// the name servers of the zone status with the glue of ns1
+		test.Case{Qname: "example.org.", Qtype: dns.TypeNS,
+			Answer: []dns.RR{
+				test.NS("example.org. 600 IN NS ns.example.net."),
+				test.NS("example.org. 600 IN NS ns1.example.org."),
+			},
+			Extra: []dns.RR{test.A("ns1.example.org. 10 IN A 192.0.2.53")},
+		},
+		test.Case{Qname: "example.org.", Qtype: dns.TypeSOA, Answer: []dns.RR{soa}},
+		// NS and SOA records only exist at the apex
+		test.Case{Qname: "www.example.org.", Qtype: dns.TypeNS, Ns: []dns.RR{soa}},
+		test.Case{Qname: "www.example.org.", Qtype: dns.TypeSOA, Ns: []dns.RR{soa}},
+		test.Case{Qname: "missing.example.org.", Qtype: dns.TypeNS, Rcode: dns.RcodeNameError, Ns: []dns.RR{soa}},
+	)
+}
@@ -20,9 +20,14 @@ package kubedyndns
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"net"
+	"net/mail"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -33,7 +38,7 @@ import (
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
-	"github.com/pkg/errors"
+	"github.com/miekg/dns"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"  // pull this in here, because we want it excluded if plugin.cfg doesn't have k8s
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc" // pull this in here, because we want it excluded if plugin.cfg doesn't have k8s
@@ -43,6 +48,8 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog"
 
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/clustersource"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/filesource"
 	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
 )
 
@@ -50,7 +57,12 @@ const pluginName = "kubedyndns"
 
 var Log = clog.NewWithPlugin(pluginName)
 
-func init() { objects.Log = Log; plugin.Register(pluginName, setup) }
+func init() {
+	objects.Log = Log
+	filesource.Log = Log
+	clustersource.Log = Log
+	plugin.Register(pluginName, setup)
+}
 
 func setup(c *caddy.Controller) error {
 	Log.Infof("setup kubedyndns plugin")
@@ -81,12 +93,18 @@ func setup(c *caddy.Controller) error {
 		}
 	}
 
-	err = ks[0].InitKubeCache(context.Background())
+	err = initKubeCaches(context.Background(), c, ks, (*K8SConfig).newDataSource)
 	if err != nil {
 		return plugin.Error(pluginName, err)
 	}
 
-	ks[0].RegisterKubeCache(c)
+	for i := range ks {
+		k := ks[i]
+		if k.exportAddr != "" {
+			c.OnStartup(func() error { return registerZoneServer(k.exportAddr, k) })
+			c.OnShutdown(func() error { return unregisterZoneServer(k.exportAddr, k) })
+		}
+	}
 
 	// get locally bound addresses
 	c.OnStartup(func() error {
@@ -100,6 +118,39 @@ func setup(c *caddy.Controller) error {
 	return nil
 }
 
+// initKubeCaches initializes the controllers of the plugin instances
+// of a server block. Instances with identical configurations share
+// a controller, instances using the same objects share the data source
+// created with newSource.
+func initKubeCaches(ctx context.Context, c *caddy.Controller, ks []*KubeDynDNS, newSource func(*K8SConfig) (DataSource, error)) error {
+	sources := map[string]DataSource{}
+	controllers := map[string]Controller{}
+	for _, k := range ks {
+		key := k.controllerKey()
+		if cntr := controllers[key]; cntr != nil {
+			Log.Infof("sharing controller for zones %v", k.Zones)
+			k.APIConn = cntr
+			continue
+		}
+		ckey := k.k8s.connectionKey()
+		src := sources[ckey]
+		if src == nil {
+			var err error
+			src, err = newSource(k.k8s)
+			if err != nil {
+				return err
+			}
+			sources[ckey] = src
+		}
+		if err := k.InitCache(ctx, src); err != nil {
+			return err
+		}
+		controllers[key] = k.APIConn
+		k.RegisterKubeCache(c)
+	}
+	return nil
+}
+
 // RegisterKubeCache registers KubeCache start and stop functions with Caddy
 func (k *KubeDynDNS) RegisterKubeCache(c *caddy.Controller) {
 	c.OnStartup(func() error {
@@ -124,38 +175,49 @@ func (k *KubeDynDNS) RegisterKubeCache(c *caddy.Controller) {
 	})
 }
 
+// Parse parses all kubedyndns stanzas of a server block.
+func Parse(c *caddy.Controller) ([]*KubeDynDNS, error) {
+	return parse(c)
+}
+
 func parse(c *caddy.Controller) ([]*KubeDynDNS, error) {
 	var (
 		kc *K8SConfig
 		r  []*KubeDynDNS
 	)
 
-	singleton := ""
-	i := 0
+	var errs []error
 	for c.Next() {
-		i++
-
 		k8s, err := ParseStanza(c, kc)
 		if err != nil {
-			return nil, err
+			if !isValidationError(err) {
+				return nil, errors.Join(append(errs, err)...)
+			}
+			errs = append(errs, err)
+			continue
 		}
-		if len(k8s.namespaces) == 0 {
-			singleton = "using at least one instance for all namespaces"
+		if kc == nil {
+			kc = k8s.assureK8SConfig()
 		}
-		kc = k8s.assureK8SConfig()
-		k8s.filtered = k8s.Mode == MODE_FILTER
 		r = append(r, k8s)
 	}
-	if singleton != "" && len(r) > 1 {
-		return nil, fmt.Errorf("this plugin can only be used once per Server Block: %s", singleton)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
 	return r, nil
 }
 
 // ParseStanza parses a kubedyndns stanza
+// A stanza without own access configuration uses the one of
+// the given config kc, typically the one of the first stanza
+// of the server block.
+// Consistency problems of the configuration are reported together
+// as ValidationError after the complete stanza has been parsed.
 func ParseStanza(c *caddy.Controller, kc *K8SConfig) (*KubeDynDNS, error) {
 	var err error
 
+	location := fmt.Sprintf("%s:%d", c.File(), c.Line())
+
 	k8s := New([]string{""})
 
 	zones := c.RemainingArgs()
@@ -182,6 +244,7 @@ func ParseStanza(c *caddy.Controller, kc *K8SConfig) (*KubeDynDNS, error) {
 
 	for _, z := range k8s.Zones {
 		if dnsutil.IsReverse("."+z) > 0 {
+			k8s.ReverseZones = append(k8s.ReverseZones, z)
 			continue
 		}
 		k8s.ServedZones = append(k8s.ServedZones, z)
@@ -216,15 +279,22 @@ func ParseStanza(c *caddy.Controller, kc *K8SConfig) (*KubeDynDNS, error) {
 				continue
 			}
 			return nil, c.ArgErr()
-		case "namespaces":
+		case "namespaces": // [NAMESPACE...] [-l EXPRESSION]
 			args := c.RemainingArgs()
-			if len(args) > 0 {
-				for _, a := range args {
-					k8s.namespaces[a] = struct{}{}
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			if i := slices.Index(args, "-l"); i >= 0 {
+				ls, err := labelSelector(c, args[i+1:])
+				if err != nil {
+					return nil, err
 				}
-				continue
+				k8s.assureK8SConfig().namespaceLabelSelector = ls
+				args = args[:i]
+			}
+			for _, a := range args {
+				k8s.namespaces[a] = struct{}{}
 			}
-			return nil, c.ArgErr()
 		case "endpoint":
 			args := c.RemainingArgs()
 			if len(args) > 0 {
@@ -259,6 +329,44 @@ func ParseStanza(c *caddy.Controller, kc *K8SConfig) (*KubeDynDNS, error) {
 			default:
 				return nil, c.ArgErr()
 			}
+		case "directory": // DIR [STATUSFILE]
+			args := c.RemainingArgs()
+			if len(args) < 1 || len(args) > 2 {
+				return nil, c.ArgErr()
+			}
+			kc := k8s.assureK8SConfig()
+			kc.Directory = configPath(c, args[0])
+			if len(args) > 1 {
+				kc.StatusFile = configPath(c, args[1])
+			}
+		case "cluster": // NAME [KUBECONFIG [CONTEXT]]
+			args := c.RemainingArgs()
+			if len(args) < 1 || len(args) > 3 {
+				return nil, c.ArgErr()
+			}
+			cluster := ClusterConfig{Name: args[0]}
+			if len(args) > 1 {
+				override := &clientcmd.ConfigOverrides{}
+				if len(args) > 2 {
+					override.CurrentContext = args[2]
+				}
+				cluster.kubeconfig, cluster.context = configPath(c, args[1]), override.CurrentContext
+				cluster.ClientConfig = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+					&clientcmd.ClientConfigLoadingRules{ExplicitPath: configPath(c, args[1])},
+					override,
+				)
+			}
+			kc := k8s.assureK8SConfig()
+			kc.Clusters = append(kc.Clusters, cluster)
+		case "conflicts": // precedence|merge|reject
+			args := c.RemainingArgs()
+			if len(args) != 1 {
+				return nil, c.ArgErr()
+			}
+			if !clustersource.ValidPolicy(args[0]) {
+				return nil, c.Errf("invalid conflict policy %q, use %s, %s or %s", args[0], clustersource.POLICY_PRECEDENCE, clustersource.POLICY_MERGE, clustersource.POLICY_REJECT)
+			}
+			k8s.assureK8SConfig().ConflictPolicy = args[0]
 		case "tls": // cert key cacertfile
 			args := c.RemainingArgs()
 			if len(args) == 3 {
@@ -268,17 +376,29 @@ func ParseStanza(c *caddy.Controller, kc *K8SConfig) (*KubeDynDNS, error) {
 			}
 			return nil, c.ArgErr()
 		case "labels":
-			args := c.RemainingArgs()
-			if len(args) > 0 {
-				labelSelectorString := strings.Join(args, " ")
-				ls, err := meta.ParseToLabelSelector(labelSelectorString)
-				if err != nil {
-					return nil, c.Errf("unable to parse label selector value: '%v': %v", labelSelectorString, err)
-				}
-				k8s.assureK8SConfig().labelSelector = ls
-				continue
+			ls, err := parseLabelSelector(c)
+			if err != nil {
+				return nil, err
 			}
-			return nil, c.ArgErr()
+			k8s.assureK8SConfig().labelSelector = ls
+		case "entrylabels":
+			ls, err := parseLabelSelector(c)
+			if err != nil {
+				return nil, err
+			}
+			k8s.assureK8SConfig().entryLabelSelector = ls
+		case "zonelabels":
+			ls, err := parseLabelSelector(c)
+			if err != nil {
+				return nil, err
+			}
+			k8s.assureK8SConfig().zoneLabelSelector = ls
+		case "namespacelabels":
+			ls, err := parseLabelSelector(c)
+			if err != nil {
+				return nil, err
+			}
+			k8s.assureK8SConfig().namespaceLabelSelector = ls
 		case "fallthrough":
 			k8s.Fall.SetZonesFromArgs(c.RemainingArgs())
 		case "ttl":
@@ -304,11 +424,14 @@ func ParseStanza(c *caddy.Controller, kc *K8SConfig) (*KubeDynDNS, error) {
 			default:
 				return nil, c.ArgErr()
 			}
+			path := configPath(c, args[0])
 			config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-				&clientcmd.ClientConfigLoadingRules{ExplicitPath: args[0]},
+				&clientcmd.ClientConfigLoadingRules{ExplicitPath: path},
 				override,
 			)
-			k8s.assureK8SConfig().ClientConfig = config
+			kc := k8s.assureK8SConfig()
+			kc.ClientConfig = config
+			kc.kubeconfig, kc.context = path, override.CurrentContext
 			continue
 		case "transitive":
 			args := c.RemainingArgs()
@@ -323,6 +446,154 @@ func ParseStanza(c *caddy.Controller, kc *K8SConfig) (*KubeDynDNS, error) {
 			default:
 				return nil, c.ArgErr()
 			}
+		case "upstream":
+			args := c.RemainingArgs()
+			switch len(args) {
+			case 0:
+				k8s.upstream = true
+			case 1:
+				k8s.upstream, err = strconv.ParseBool(args[0])
+				if err != nil {
+					return nil, err
+				}
+			default:
+				return nil, c.ArgErr()
+			}
+		case "soa": // mbox [ns [refresh [retry [expire [minttl]]]]]
+			args := c.RemainingArgs()
+			if len(args) == 0 || len(args) > 6 {
+				return nil, c.ArgErr()
+			}
+			k8s.soa.mbox = args[0]
+			if _, err := mail.ParseAddress(args[0]); err == nil {
+				comps := strings.Split(args[0], "@")
+				k8s.soa.mbox = dns.Fqdn(strings.Replace(comps[0], ".", "\\.", -1) + "." + comps[1])
+			}
+			if len(args) > 1 {
+				k8s.soa.ns = args[1]
+			}
+			values := []*uint32{&k8s.soa.refresh, &k8s.soa.retry, &k8s.soa.expire, &k8s.soa.minttl}
+			for i, a := range args[min(len(args), 2):] {
+				v, err := strconv.ParseUint(a, 10, 32)
+				if err != nil {
+					return nil, c.Errf("invalid soa value %q: %s", a, err)
+				}
+				*values[i] = uint32(v)
+			}
+		case "nameservers":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			k8s.nameServers = append(k8s.nameServers, args...)
+		case "acl": // [transfer] allow|deny CIDR...
+			args := c.RemainingArgs()
+			acl := &k8s.queryACL
+			if len(args) > 0 && args[0] == "transfer" {
+				acl, args = &k8s.transferACL, args[1:]
+			}
+			if len(args) < 2 {
+				return nil, c.ArgErr()
+			}
+			var allow, deny []string
+			switch args[0] {
+			case "allow":
+				allow = args[1:]
+			case "deny":
+				deny = args[1:]
+			default:
+				return nil, c.Errf("invalid acl mode %q, use allow or deny", args[0])
+			}
+			a, err := objects.ParseACL(allow, deny)
+			if err != nil {
+				return nil, c.Errf("invalid acl: %s", err)
+			}
+			if *acl == nil {
+				*acl = &objects.ACL{}
+			}
+			(*acl).Allow = append((*acl).Allow, a.Allow...)
+			(*acl).Deny = append((*acl).Deny, a.Deny...)
+		case "ratelimit": // RATE [SLIP]
+			args := c.RemainingArgs()
+			if len(args) == 0 || len(args) > 2 {
+				return nil, c.ArgErr()
+			}
+			values := []*int{&k8s.rrl.rate, &k8s.rrl.slip}
+			for i, a := range args {
+				v, err := strconv.Atoi(a)
+				if err != nil {
+					return nil, err
+				}
+				if v < 0 {
+					return nil, c.Errf("ratelimit values must not be negative: %d", v)
+				}
+				*values[i] = v
+			}
+		case "any": // MODE [TRUSTED...]
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			switch args[0] {
+			case ANY_FULL, ANY_MINIMAL, ANY_HINFO:
+				k8s.anyMode = args[0]
+			default:
+				return nil, c.Errf("invalid any mode %q, use %s, %s or %s", args[0], ANY_FULL, ANY_MINIMAL, ANY_HINFO)
+			}
+			if len(args) > 1 {
+				k8s.anyTrusted, err = objects.ParseACL(args[1:], nil)
+				if err != nil {
+					return nil, c.Errf("invalid trusted clients: %s", err)
+				}
+			}
+		case "order":
+			args := c.RemainingArgs()
+			if len(args) != 1 {
+				return nil, c.ArgErr()
+			}
+			if !objects.ValidOrder(args[0]) {
+				return nil, c.Errf("invalid order %q, use %s, %s, %s or %s", args[0], objects.ORDER_NONE, objects.ORDER_SORTED, objects.ORDER_ROTATE, objects.ORDER_SHUFFLE)
+			}
+			k8s.order = args[0]
+		case "nsid": // [DATA]
+			args := c.RemainingArgs()
+			switch len(args) {
+			case 0:
+				k8s.nsid, err = os.Hostname()
+				if err != nil {
+					return nil, c.Errf("cannot determine host name for nsid: %s", err)
+				}
+			case 1:
+				k8s.nsid = args[0]
+			default:
+				return nil, c.ArgErr()
+			}
+		case "cookies": // [SECRET]
+			args := c.RemainingArgs()
+			switch len(args) {
+			case 0:
+				k8s.cookieSecret = make([]byte, 32)
+				if _, err := rand.Read(k8s.cookieSecret); err != nil {
+					return nil, err
+				}
+			case 1:
+				k8s.cookieSecret = []byte(args[0])
+			default:
+				return nil, c.ArgErr()
+			}
+		case "export": // [ADDRESS]
+			args := c.RemainingArgs()
+			switch len(args) {
+			case 0:
+				k8s.exportAddr = defaultExportAddr
+			case 1:
+				if _, _, err := net.SplitHostPort(args[0]); err != nil {
+					return nil, c.Errf("invalid export address %q: %s", args[0], err)
+				}
+				k8s.exportAddr = args[0]
+			default:
+				return nil, c.ArgErr()
+			}
 		case "slave":
 			args := c.RemainingArgs()
 			switch len(args) {
@@ -341,41 +612,41 @@ func ParseStanza(c *caddy.Controller, kc *K8SConfig) (*KubeDynDNS, error) {
 		}
 	}
 
-	if k8s.Mode == MODE_PRIMARY {
-		if k8s.zoneObject == "" {
-			return nil, c.Errf("zoneObject required for mode %q", k8s.Mode)
-		}
-
-		if len(k8s.namespaces) != 1 {
-			return nil, c.Errf("one namespace required for zoneObject for mode %q", k8s.Mode)
-		}
+	if kc != nil && !k8s.k8s.hasConnection() {
+		k8s.assureK8SConfig().inheritConnection(kc)
+	}
+	problems := k8s.validate()
+	if len(problems) > 0 {
+		return nil, &ValidationError{Location: location, Problems: problems}
+	}
 
+	if k8s.Mode == MODE_PRIMARY {
 		ns := ""
 		for n := range k8s.namespaces {
 			ns = n
 		}
-
 		k8s.zoneRef = &cache.ObjectName{Name: k8s.zoneObject, Namespace: ns}
-		k8s.k8s.Namespaces[k8s.zoneRef.Namespace] = struct{}{}
-
-	} else {
-		if k8s.zoneObject != "" {
-			return nil, c.Errf("zoneObject requires mode %q", MODE_PRIMARY)
-		}
-	}
-
-	if kc != nil && k8s.k8s != nil {
-		return nil, c.Errf("kubernetes config is possible only once at first instance in server block")
 	}
+	k8s.filtered = k8s.Mode == MODE_FILTER
+	return k8s, nil
+}
 
-	k8s.assureK8SConfig().Namespaces[k8s.zoneRef.Namespace] = struct{}{}
+// parseLabelSelector parses the label selector given by the remaining arguments.
+func parseLabelSelector(c *caddy.Controller) (*meta.LabelSelector, error) {
+	return labelSelector(c, c.RemainingArgs())
+}
 
-	if k8s.Mode != MODE_FILTER {
-		if len(k8s.ServedZones) != 1 {
-			return nil, c.Errf("Mode %s requires one served zone as base domain", k8s.Mode)
-		}
+// labelSelector parses the label selector given by some arguments.
+func labelSelector(c *caddy.Controller, args []string) (*meta.LabelSelector, error) {
+	if len(args) == 0 {
+		return nil, c.ArgErr()
 	}
-	return k8s, nil
+	labelSelectorString := strings.Join(args, " ")
+	ls, err := meta.ParseToLabelSelector(labelSelectorString)
+	if err != nil {
+		return nil, c.Errf("unable to parse label selector value: '%v': %v", labelSelectorString, err)
+	}
+	return ls, nil
 }
 
 func isDirectory(path string) bool {
@@ -399,3 +670,11 @@ func fileExists(path string) bool {
 	// or another issue. In most cases, you treat this as false.
 	return false
 }
+
+// configPath resolves a path relative to the root of the server configuration.
+func configPath(c *caddy.Controller, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dnsserver.GetConfig(c).Root, path)
+}
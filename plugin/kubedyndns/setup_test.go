@@ -0,0 +1,885 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/mandelsoft/kubedyndns/client/clientset/versioned/fake"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+func parseTest(input string) ([]*KubeDynDNS, error) {
+	return parse(caddy.NewTestController("dns", input))
+}
+
+func selector(ls *meta.LabelSelector) string {
+	return meta.FormatLabelSelector(ls)
+}
+
+func allowed(acl *objects.ACL, ip string) bool {
+	return acl.Allowed(net.ParseIP(ip))
+}
+
+func TestParseDirectives(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+		check func(t *testing.T, k *KubeDynDNS)
+	}{
+		{
+			name:  "defaults",
+			input: `kubedyndns example.org 2.0.192.in-addr.arpa`,
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if !slices.Equal(k.ServedZones, []string{"example.org."}) {
+					t.Errorf("served zones %v", k.ServedZones)
+				}
+				if !slices.Equal(k.ReverseZones, []string{"2.0.192.in-addr.arpa."}) {
+					t.Errorf("reverse zones %v", k.ReverseZones)
+				}
+				if k.Mode != MODE_FILTER || !k.filtered || k.ttl != defaultTTL || k.soa != defaultSOA {
+					t.Errorf("unexpected defaults: mode %s, filtered %t, ttl %d, soa %+v", k.Mode, k.filtered, k.ttl, k.soa)
+				}
+				if k.anyMode != ANY_FULL || k.rrl.rate != 0 || k.rrl.slip != defaultSlip || k.exportAddr != "" {
+					t.Errorf("unexpected defaults: any %s, rate %d, slip %d, export %q", k.anyMode, k.rrl.rate, k.rrl.slip, k.exportAddr)
+				}
+				if k.k8s.hasConnection() {
+					t.Errorf("unexpected connection")
+				}
+			},
+		},
+
+		{
+			name: "mode",
+			input: `kubedyndns example.org {
+				mode Subdomains
+			}`,
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.Mode != MODE_SUBDOMAINS || k.filtered {
+					t.Errorf("mode %s, filtered %t", k.Mode, k.filtered)
+				}
+			},
+		},
+		{
+			name:  "invalid mode",
+			input: "kubedyndns example.org {\n mode Other\n}",
+			err:   `Invalid mode "Other"`,
+		},
+		{
+			name:  "multiple modes",
+			input: "kubedyndns example.org {\n mode Primary Subdomains\n}",
+			err:   "Multiple modes not possible",
+		},
+		{
+			name:  "mode without argument",
+			input: "kubedyndns example.org {\n mode\n}",
+			err:   "Wrong argument count",
+		},
+
+		{
+			name: "zoneobject",
+			input: `kubedyndns . {
+				mode Primary
+				zoneobject test
+				namespaces zones
+			}`,
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.zoneObject != "test" || k.zoneRef == nil || k.zoneRef.String() != "zones/test" {
+					t.Errorf("zone object %q, ref %v", k.zoneObject, k.zoneRef)
+				}
+				if !k.namespaces.Equal(sets.New("zones")) {
+					t.Errorf("namespaces %v", sets.List(k.namespaces))
+				}
+			},
+		},
+		{
+			name:  "zoneobject with multiple names",
+			input: "kubedyndns . {\n zoneobject a b\n}",
+			err:   "Zone Object requires name",
+		},
+
+		{
+			name: "namespaces",
+			input: `kubedyndns example.org {
+				namespaces a b
+				namespaces c -l team=dns
+			}`,
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if !k.namespaces.Equal(sets.New("a", "b", "c")) {
+					t.Errorf("namespaces %v", sets.List(k.namespaces))
+				}
+				if s := selector(k.k8s.namespaceLabelSelector); s != "team=dns" {
+					t.Errorf("namespace selector %s", s)
+				}
+			},
+		},
+		{
+			name:  "namespaces without argument",
+			input: "kubedyndns example.org {\n namespaces\n}",
+			err:   "Wrong argument count",
+		},
+		{
+			name:  "namespaces with invalid selector",
+			input: "kubedyndns example.org {\n namespaces a -l team in (a\n}",
+			err:   "unable to parse label selector",
+		},
+
+		{
+			name:  "endpoint",
+			input: "kubedyndns example.org {\n endpoint http://localhost:8080\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if !slices.Equal(k.k8s.APIServerList, []string{"http://localhost:8080"}) || !k.k8s.hasConnection() {
+					t.Errorf("endpoints %v", k.k8s.APIServerList)
+				}
+			},
+		},
+		{
+			name:  "multiple endpoints",
+			input: "kubedyndns example.org {\n endpoint http://a:8080 http://b:8080\n}",
+			err:   "Multiple endpoints not possible",
+		},
+
+		{
+			name:  "token",
+			input: "kubedyndns example.org {\n endpoint https://localhost:6443\n token secret ca.crt\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.k8s.APIToken != "secret" || k.k8s.APICertAuth != "ca.crt" {
+					t.Errorf("token %q, ca %q", k.k8s.APIToken, k.k8s.APICertAuth)
+				}
+			},
+		},
+		{
+			name:  "token without endpoint",
+			input: "kubedyndns example.org {\n token secret\n}",
+			err:   "API token requires endpoint",
+		},
+		{
+			name:  "token and kubeconfig",
+			input: "kubedyndns example.org {\n endpoint https://localhost:6443\n token secret\n kubeconfig config\n}",
+			err:   "only API token or kubeconfig possible",
+		},
+
+		{
+			name:  "tls",
+			input: "kubedyndns example.org {\n endpoint https://localhost:6443\n tls cert key ca\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.k8s.APIClientCert != "cert" || k.k8s.APIClientKey != "key" || k.k8s.APICertAuth != "ca" {
+					t.Errorf("cert %q, key %q, ca %q", k.k8s.APIClientCert, k.k8s.APIClientKey, k.k8s.APICertAuth)
+				}
+			},
+		},
+		{
+			name:  "tls with missing arguments",
+			input: "kubedyndns example.org {\n tls cert key\n}",
+			err:   "Wrong argument count",
+		},
+
+		{
+			name:  "kubeconfig",
+			input: "kubedyndns example.org {\n kubeconfig /etc/kubeconfig test\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.k8s.ClientConfig == nil || k.k8s.kubeconfig != "/etc/kubeconfig" || k.k8s.context != "test" {
+					t.Errorf("kubeconfig %q, context %q", k.k8s.kubeconfig, k.k8s.context)
+				}
+			},
+		},
+		{
+			name:  "kubeconfig and endpoint",
+			input: "kubedyndns example.org {\n kubeconfig /etc/kubeconfig\n endpoint http://localhost:8080\n}",
+			err:   "only endpoint or kubeconfig possible",
+		},
+
+		{
+			name:  "directory",
+			input: "kubedyndns example.org {\n directory /manifests /status.yaml\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.k8s.Directory != "/manifests" || k.k8s.StatusFile != "/status.yaml" || !k.k8s.hasConnection() {
+					t.Errorf("directory %q, status file %q", k.k8s.Directory, k.k8s.StatusFile)
+				}
+			},
+		},
+		{
+			name:  "directory with kubernetes connection",
+			input: "kubedyndns example.org {\n directory /manifests\n endpoint http://localhost:8080\n}",
+			err:   "directory cannot be combined with a kubernetes connection",
+		},
+		{
+			name:  "directory with namespacelabels",
+			input: "kubedyndns example.org {\n directory /manifests\n namespacelabels team=dns\n}",
+			err:   "namespacelabels requires a kubernetes connection",
+		},
+
+		{
+			name: "clusters",
+			input: `kubedyndns example.org {
+				cluster local
+				cluster remote /etc/kubeconfig remote
+				conflicts merge
+			}`,
+			check: func(t *testing.T, k *KubeDynDNS) {
+				c := k.k8s.Clusters
+				if len(c) != 2 || c[0].Name != "local" || c[0].ClientConfig != nil ||
+					c[1].Name != "remote" || c[1].ClientConfig == nil || c[1].kubeconfig != "/etc/kubeconfig" || c[1].context != "remote" {
+					t.Errorf("clusters %+v", c)
+				}
+				if k.k8s.ConflictPolicy != "merge" {
+					t.Errorf("conflict policy %q", k.k8s.ConflictPolicy)
+				}
+			},
+		},
+		{
+			name:  "duplicate clusters",
+			input: "kubedyndns example.org {\n cluster local\n cluster local\n}",
+			err:   `duplicate cluster name "local"`,
+		},
+		{
+			name:  "invalid cluster name",
+			input: "kubedyndns example.org {\n cluster Local_1\n}",
+			err:   `invalid cluster name "Local_1"`,
+		},
+		{
+			name:  "clusters with kubernetes connection",
+			input: "kubedyndns example.org {\n cluster local\n endpoint http://localhost:8080\n}",
+			err:   "clusters cannot be combined with a kubernetes connection",
+		},
+		{
+			name:  "invalid conflict policy",
+			input: "kubedyndns example.org {\n cluster local\n conflicts ignore\n}",
+			err:   `invalid conflict policy "ignore"`,
+		},
+		{
+			name:  "conflicts without clusters",
+			input: "kubedyndns example.org {\n conflicts merge\n}",
+			err:   "conflicts requires clusters",
+		},
+
+		{
+			name: "labels",
+			input: `kubedyndns example.org {
+				labels app=dns
+				entrylabels kind in (a, b)
+				zonelabels !test
+				namespacelabels team=dns
+			}`,
+			check: func(t *testing.T, k *KubeDynDNS) {
+				for _, c := range []struct {
+					ls       *meta.LabelSelector
+					expected string
+				}{
+					{k.k8s.labelSelector, "app=dns"},
+					{k.k8s.entryLabelSelector, "kind in (a,b)"},
+					{k.k8s.zoneLabelSelector, "!test"},
+					{k.k8s.namespaceLabelSelector, "team=dns"},
+				} {
+					if s := selector(c.ls); s != c.expected {
+						t.Errorf("selector %s, expected %s", s, c.expected)
+					}
+				}
+			},
+		},
+		{
+			name:  "labels without argument",
+			input: "kubedyndns example.org {\n labels\n}",
+			err:   "Wrong argument count",
+		},
+
+		{
+			name:  "fallthrough",
+			input: "kubedyndns example.org {\n fallthrough sub.example.org\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if !k.Fall.Through("a.sub.example.org.") || k.Fall.Through("www.example.org.") {
+					t.Errorf("fallthrough zones %v", k.Fall.Zones)
+				}
+			},
+		},
+
+		{
+			name:  "ttl",
+			input: "kubedyndns example.org {\n ttl 300\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.ttl != 300 {
+					t.Errorf("ttl %d", k.ttl)
+				}
+			},
+		},
+		{
+			name:  "ttl out of range",
+			input: "kubedyndns example.org {\n ttl 3601\n}",
+			err:   "ttl must be in range",
+		},
+		{
+			name:  "invalid ttl",
+			input: "kubedyndns example.org {\n ttl long\n}",
+			err:   "invalid syntax",
+		},
+
+		{
+			name:  "transitive",
+			input: "kubedyndns . {\n mode Primary\n zoneobject test\n namespaces default\n transitive\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if !k.transitive {
+					t.Errorf("transitive not set")
+				}
+			},
+		},
+		{
+			name:  "transitive without zone object",
+			input: "kubedyndns example.org {\n transitive true\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if !k.transitive {
+					t.Errorf("transitive not set")
+				}
+			},
+		},
+		{
+			name:  "invalid transitive",
+			input: "kubedyndns example.org {\n transitive maybe\n}",
+			err:   "invalid syntax",
+		},
+
+		{
+			name:  "upstream",
+			input: "kubedyndns example.org {\n upstream\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if !k.upstream {
+					t.Errorf("upstream not set")
+				}
+			},
+		},
+		{
+			name:  "upstream disabled",
+			input: "kubedyndns example.org {\n upstream false\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.upstream {
+					t.Errorf("upstream set")
+				}
+			},
+		},
+
+		{
+			name:  "soa",
+			input: "kubedyndns example.org {\n soa dns.admin@example.org ns1 3600 600 604800 300\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				expected := soaConfig{mbox: `dns\.admin.example.org.`, ns: "ns1", refresh: 3600, retry: 600, expire: 604800, minttl: 300}
+				if k.soa != expected {
+					t.Errorf("soa %+v", k.soa)
+				}
+			},
+		},
+		{
+			name:  "soa with mailbox name",
+			input: "kubedyndns example.org {\n soa admin\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				expected := defaultSOA
+				expected.mbox = "admin"
+				if k.soa != expected {
+					t.Errorf("soa %+v", k.soa)
+				}
+			},
+		},
+		{
+			name:  "invalid soa value",
+			input: "kubedyndns example.org {\n soa admin ns1 -1\n}",
+			err:   `invalid soa value "-1"`,
+		},
+		{
+			name:  "soa with too many arguments",
+			input: "kubedyndns example.org {\n soa admin ns1 1 2 3 4 5\n}",
+			err:   "Wrong argument count",
+		},
+		{
+			name:  "soa in mode Primary",
+			input: "kubedyndns . {\n mode Primary\n zoneobject test\n namespaces default\n soa admin\n}",
+			err:   `soa and nameservers not possible for mode "Primary"`,
+		},
+
+		{
+			name:  "nameservers",
+			input: "kubedyndns example.org {\n nameservers ns1 ns2.example.com.\n nameservers ns3\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if !slices.Equal(k.nameServers, []string{"ns1", "ns2.example.com.", "ns3"}) {
+					t.Errorf("nameservers %v", k.nameServers)
+				}
+			},
+		},
+
+		{
+			name: "acl",
+			input: `kubedyndns example.org {
+				acl allow 10.0.0.0/8
+				acl deny 10.1.0.0/16
+				acl transfer allow 192.0.2.1/32
+			}`,
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if !allowed(k.queryACL, "10.2.0.1") || allowed(k.queryACL, "10.1.0.1") || allowed(k.queryACL, "192.0.2.1") {
+					t.Errorf("query acl %+v", k.queryACL)
+				}
+				if !allowed(k.transferACL, "192.0.2.1") || allowed(k.transferACL, "10.2.0.1") {
+					t.Errorf("transfer acl %+v", k.transferACL)
+				}
+			},
+		},
+		{
+			name:  "invalid acl mode",
+			input: "kubedyndns example.org {\n acl permit 10.0.0.0/8\n}",
+			err:   `invalid acl mode "permit"`,
+		},
+		{
+			name:  "invalid acl",
+			input: "kubedyndns example.org {\n acl allow 10.0.0.0/33\n}",
+			err:   "invalid acl",
+		},
+		{
+			name:  "acl without networks",
+			input: "kubedyndns example.org {\n acl transfer allow\n}",
+			err:   "Wrong argument count",
+		},
+
+		{
+			name:  "ratelimit",
+			input: "kubedyndns example.org {\n ratelimit 10 3\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.rrl.rate != 10 || k.rrl.slip != 3 {
+					t.Errorf("rate %d, slip %d", k.rrl.rate, k.rrl.slip)
+				}
+			},
+		},
+		{
+			name:  "ratelimit with default slip",
+			input: "kubedyndns example.org {\n ratelimit 10\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.rrl.rate != 10 || k.rrl.slip != defaultSlip {
+					t.Errorf("rate %d, slip %d", k.rrl.rate, k.rrl.slip)
+				}
+			},
+		},
+		{
+			name:  "negative ratelimit",
+			input: "kubedyndns example.org {\n ratelimit -1\n}",
+			err:   "ratelimit values must not be negative",
+		},
+
+		{
+			name:  "any",
+			input: "kubedyndns example.org {\n any minimal 10.0.0.0/8\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.anyMode != ANY_MINIMAL || !allowed(k.anyTrusted, "10.0.0.1") || allowed(k.anyTrusted, "192.0.2.1") {
+					t.Errorf("any mode %s, trusted %+v", k.anyMode, k.anyTrusted)
+				}
+			},
+		},
+		{
+			name:  "any hinfo",
+			input: "kubedyndns example.org {\n any hinfo\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.anyMode != ANY_HINFO || k.anyTrusted != nil {
+					t.Errorf("any mode %s, trusted %+v", k.anyMode, k.anyTrusted)
+				}
+			},
+		},
+		{
+			name:  "invalid any mode",
+			input: "kubedyndns example.org {\n any none\n}",
+			err:   `invalid any mode "none"`,
+		},
+		{
+			name:  "invalid any trusted clients",
+			input: "kubedyndns example.org {\n any minimal localhost\n}",
+			err:   "invalid trusted clients",
+		},
+
+		{
+			name:  "order",
+			input: "kubedyndns example.org {\n order rotate\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.order != objects.ORDER_ROTATE {
+					t.Errorf("order %s", k.order)
+				}
+			},
+		},
+		{
+			name:  "invalid order",
+			input: "kubedyndns example.org {\n order random\n}",
+			err:   `invalid order "random"`,
+		},
+
+		{
+			name:  "nsid",
+			input: "kubedyndns example.org {\n nsid replica-1\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.nsid != "replica-1" {
+					t.Errorf("nsid %q", k.nsid)
+				}
+			},
+		},
+		{
+			name:  "nsid from host name",
+			input: "kubedyndns example.org {\n nsid\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if h, _ := os.Hostname(); k.nsid != h {
+					t.Errorf("nsid %q, expected %q", k.nsid, h)
+				}
+			},
+		},
+
+		{
+			name:  "cookies",
+			input: "kubedyndns example.org {\n cookies secret\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if string(k.cookieSecret) != "secret" {
+					t.Errorf("cookie secret %q", k.cookieSecret)
+				}
+			},
+		},
+		{
+			name:  "cookies with random secret",
+			input: "kubedyndns example.org {\n cookies\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if len(k.cookieSecret) != 32 {
+					t.Errorf("cookie secret length %d", len(k.cookieSecret))
+				}
+			},
+		},
+		{
+			name:  "cookies with too many arguments",
+			input: "kubedyndns example.org {\n cookies a b\n}",
+			err:   "Wrong argument count",
+		},
+
+		{
+			name:  "export",
+			input: "kubedyndns example.org {\n export\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.exportAddr != defaultExportAddr {
+					t.Errorf("export address %q", k.exportAddr)
+				}
+			},
+		},
+		{
+			name:  "export address",
+			input: "kubedyndns example.org {\n export :8054\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if k.exportAddr != ":8054" {
+					t.Errorf("export address %q", k.exportAddr)
+				}
+			},
+		},
+		{
+			name:  "invalid export address",
+			input: "kubedyndns example.org {\n export localhost\n}",
+			err:   `invalid export address "localhost"`,
+		},
+
+		{
+			name:  "slave",
+			input: "kubedyndns example.org {\n slave\n}",
+			check: func(t *testing.T, k *KubeDynDNS) {
+				if !k.slave {
+					t.Errorf("slave not set")
+				}
+			},
+		},
+		{
+			name:  "invalid slave",
+			input: "kubedyndns example.org {\n slave yes please\n}",
+			err:   "Wrong argument count",
+		},
+
+		{
+			name:  "unknown property",
+			input: "kubedyndns example.org {\n unknown\n}",
+			err:   "unknown property 'unknown'",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ks, err := parseTest(tc.input)
+			if tc.err != "" {
+				if err == nil {
+					t.Fatalf("expected error containing %q", tc.err)
+				}
+				if !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error containing %q, got %q", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(ks) != 1 {
+				t.Fatalf("expected one plugin instance, found %d", len(ks))
+			}
+			tc.check(t, ks[0])
+		})
+	}
+}
+
+func TestParseTokenDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"token", "ca.crt"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte(f), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ks, err := parseTest("kubedyndns example.org {\n endpoint https://localhost:6443\n token " + dir + "\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := ks[0].k8s; k.APIToken != filepath.Join(dir, "token") || k.APICertAuth != filepath.Join(dir, "ca.crt") {
+		t.Errorf("token %q, ca %q", k.APIToken, k.APICertAuth)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "token")); err != nil {
+		t.Fatal(err)
+	}
+	_, err = parseTest("kubedyndns example.org {\n endpoint https://localhost:6443\n token " + dir + "\n}")
+	if err == nil || !strings.Contains(err.Error(), "no token file found") {
+		t.Errorf("expected missing token file, got %v", err)
+	}
+}
+
+func TestParseServerBlockKeys(t *testing.T) {
+	c := caddy.NewTestController("dns", `kubedyndns`)
+	c.ServerBlockKeys = []string{"example.org:53", "2.0.192.in-addr.arpa:53"}
+	ks, err := parse(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := ks[0]; !slices.Equal(k.ServedZones, []string{"example.org."}) || !slices.Equal(k.ReverseZones, []string{"2.0.192.in-addr.arpa."}) {
+		t.Errorf("served zones %v, reverse zones %v", k.ServedZones, k.ReverseZones)
+	}
+}
+
+func TestParseRelativePaths(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		check func(k *K8SConfig) bool
+	}{
+		{
+			name:  "kubeconfig",
+			input: "kubedyndns example.org {\n kubeconfig config test\n}",
+			check: func(k *K8SConfig) bool { return k.kubeconfig == "/etc/coredns/config" },
+		},
+		{
+			name:  "cluster",
+			input: "kubedyndns example.org {\n cluster remote remote.config\n}",
+			check: func(k *K8SConfig) bool { return k.Clusters[0].kubeconfig == "/etc/coredns/remote.config" },
+		},
+		{
+			name:  "directory",
+			input: "kubedyndns example.org {\n directory manifests status.yaml\n}",
+			check: func(k *K8SConfig) bool {
+				return k.Directory == "/etc/coredns/manifests" && k.StatusFile == "/etc/coredns/status.yaml"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := caddy.NewTestController("dns", tt.input)
+			dnsserver.GetConfig(c).Root = "/etc/coredns"
+			ks, err := parse(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(ks[0].k8s) {
+				t.Errorf("path not resolved relative to the root: %+v", ks[0].k8s)
+			}
+		})
+	}
+}
+
+func TestParseInheritConnection(t *testing.T) {
+	ks, err := parseTest(`kubedyndns example.org {
+		kubeconfig /etc/kubeconfig test
+		namespaces a
+	}
+	kubedyndns example.com {
+		namespaces b
+	}
+	kubedyndns example.net {
+		endpoint http://localhost:8080
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ks) != 3 {
+		t.Fatalf("expected 3 plugin instances, found %d", len(ks))
+	}
+	if ks[0].k8s.connectionKey() != ks[1].k8s.connectionKey() || ks[1].k8s.kubeconfig != "/etc/kubeconfig" {
+		t.Errorf("connection not inherited: %s", ks[1].k8s.connectionKey())
+	}
+	if !ks[1].namespaces.Equal(sets.New("b")) {
+		t.Errorf("namespaces inherited: %v", sets.List(ks[1].namespaces))
+	}
+	if ks[2].k8s.ClientConfig != nil || !slices.Equal(ks[2].k8s.APIServerList, []string{"http://localhost:8080"}) {
+		t.Errorf("own connection overridden: %s", ks[2].k8s.connectionKey())
+	}
+}
+
+func TestParseValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		problems []string
+	}{
+		{
+			name:  "mode Primary",
+			input: "kubedyndns a.org b.org {\n mode Primary\n namespaces a b\n nameservers ns1\n}",
+			problems: []string{
+				`zoneobject required for mode "Primary"`,
+				`one namespace required for zoneobject for mode "Primary", found 2`,
+				`soa and nameservers not possible for mode "Primary", use the zone object`,
+				"mode Primary requires one served zone as base domain, found 2",
+			},
+		},
+		{
+			name:     "zoneobject without mode Primary",
+			input:    "kubedyndns example.org {\n zoneobject test\n}",
+			problems: []string{`zoneobject requires mode "Primary"`},
+		},
+		{
+			name:     "mode Subdomains",
+			input:    "kubedyndns a.org b.org {\n mode Subdomains\n}",
+			problems: []string{"mode Subdomains requires one served zone as base domain, found 2"},
+		},
+		{
+			name:  "connection",
+			input: "kubedyndns example.org {\n directory /manifests\n token secret\n conflicts merge\n}",
+			problems: []string{
+				"API token requires endpoint",
+				"directory cannot be combined with a kubernetes connection",
+				"conflicts requires clusters",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseTest(tc.input)
+			if err == nil {
+				t.Fatal("expected validation error")
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected validation error, got %T: %s", err, err)
+			}
+			var problems []string
+			for _, p := range verr.Problems {
+				problems = append(problems, p.Error())
+			}
+			if !slices.Equal(problems, tc.problems) {
+				t.Errorf("problems:\n%s\nexpected:\n%s", strings.Join(problems, "\n"), strings.Join(tc.problems, "\n"))
+			}
+		})
+	}
+}
+
+func TestParseReportsAllStanzas(t *testing.T) {
+	_, err := parseTest(`kubedyndns a.org {
+		zoneobject test
+	}
+	kubedyndns b.org {
+		conflicts merge
+	}`)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, s := range []string{`zoneobject requires mode "Primary"`, "conflicts requires clusters"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("error does not contain %q: %s", s, err)
+		}
+	}
+}
+
+func TestInitKubeCaches(t *testing.T) {
+	ks, err := parseTest(`kubedyndns example.org {
+		labels team=dns
+	}
+	kubedyndns example.com {
+		labels team=dns
+	}
+	kubedyndns example.net {
+		labels team=web
+	}
+	kubedyndns example.info {
+		labels team=dns
+		namespaces a
+	}
+	kubedyndns . {
+		mode Primary
+		zoneobject test
+		namespaces a
+	}
+	kubedyndns . {
+		mode Primary
+		zoneobject other
+		namespaces a
+	}
+	kubedyndns . {
+		mode Primary
+		zoneobject test
+		namespaces a
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := 0
+	newSource := func(*K8SConfig) (DataSource, error) {
+		sources++
+		return NewClusterSource(kubefake.NewSimpleClientset(), fake.NewSimpleClientset()), nil
+	}
+	if err := initKubeCaches(context.Background(), caddy.NewTestController("dns", ""), ks, newSource); err != nil {
+		t.Fatal(err)
+	}
+	if sources != 1 {
+		t.Errorf("expected one shared data source, found %d", sources)
+	}
+
+	// instances with equal settings share their controller
+	shared := [][2]int{{0, 1}, {4, 6}}
+	for _, p := range shared {
+		if ks[p[0]].APIConn != ks[p[1]].APIConn {
+			t.Errorf("instances %d and %d do not share their controller", p[0], p[1])
+		}
+	}
+	// different selectors, namespaces or zone objects require own controllers
+	own := [][2]int{{0, 2}, {0, 3}, {4, 5}, {3, 4}}
+	for _, p := range own {
+		if ks[p[0]].APIConn == ks[p[1]].APIConn {
+			t.Errorf("instances %d and %d share their controller", p[0], p[1])
+		}
+	}
+}
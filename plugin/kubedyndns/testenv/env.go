@@ -0,0 +1,160 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+// Package testenv provides an environment to run the kubedyndns plugin
+// without a kubernetes cluster. It uses fake clientsets filled with
+// CoreDNSEntry and HostedZone objects and serves DNS requests
+// directly via the ServeDNS method of the plugin.
+package testenv
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/mandelsoft/kubedyndns/client/clientset/versioned/fake"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns"
+)
+
+// DefaultTimeout is the maximum time to wait for the controller to be synced.
+const DefaultTimeout = 5 * time.Second
+
+// Environment is a kubedyndns plugin instance
+// running on fake clientsets.
+type Environment struct {
+	Plugin     *kubedyndns.KubeDynDNS
+	Client     *fake.Clientset
+	KubeClient *kubefake.Clientset
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New creates an environment for the given kubedyndns stanza,
+// for example
+//
+//	kubedyndns test.mandelsoft.org {
+//	  mode FilterByZones
+//	}
+//
+// The fake clientset is filled with the given objects. Further objects
+// can be added with Load or Apply before the environment is started.
+func New(stanza string, objs ...runtime.Object) (*Environment, error) {
+	c := caddy.NewTestController("dns", stanza)
+	if !c.Next() {
+		return nil, fmt.Errorf("no stanza found")
+	}
+	k, err := kubedyndns.ParseStanza(c, nil)
+	if err != nil {
+		return nil, err
+	}
+	k.Next = test.NextHandler(dns.RcodeRefused, nil)
+
+	client, err := newClientset(objs...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Environment{
+		Plugin:     k,
+		Client:     client,
+		KubeClient: kubefake.NewSimpleClientset(),
+		ctx:        ctx,
+		cancel:     cancel,
+	}, nil
+}
+
+// Context returns the context of the environment.
+func (e *Environment) Context() context.Context {
+	return e.ctx
+}
+
+// Start initializes the controller of the plugin and waits
+// until it is synced.
+func (e *Environment) Start() error {
+	return e.StartWithTimeout(DefaultTimeout)
+}
+
+// StartWithTimeout initializes the controller of the plugin and waits
+// until it is synced or the timeout is reached.
+func (e *Environment) StartWithTimeout(timeout time.Duration) error {
+	err := e.Plugin.InitKubeCacheForClients(e.ctx, e.KubeClient, e.Client)
+	if err != nil {
+		return err
+	}
+	go e.Plugin.APIConn.Run()
+	return e.WaitForSync(timeout)
+}
+
+// WaitForSync waits until the controller is synced and
+// all pending changes are processed.
+func (e *Environment) WaitForSync(timeout time.Duration) error {
+	return e.Plugin.WaitForSync(timeout)
+}
+
+// Stop stops the controller of the plugin.
+func (e *Environment) Stop() error {
+	e.cancel()
+	if e.Plugin.APIConn == nil {
+		return nil
+	}
+	return e.Plugin.APIConn.Stop()
+}
+
+// Query issues a request for the given name and type
+// from the default client address.
+func (e *Environment) Query(name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	return e.Exchange(m, &test.ResponseWriter{})
+}
+
+// Exchange serves the given request with the given response writer
+// and returns the recorded answer. The response writer determines
+// the client address and protocol.
+func (e *Environment) Exchange(m *dns.Msg, w dns.ResponseWriter) (*dns.Msg, error) {
+	rec := dnstest.NewRecorder(w)
+	rcode, err := e.Plugin.ServeDNS(e.ctx, rec, m)
+	if err != nil {
+		return rec.Msg, err
+	}
+	if rec.Msg == nil {
+		return nil, fmt.Errorf("no answer written (rcode %s)", dns.RcodeToString[rcode])
+	}
+	return rec.Msg, nil
+}
+
+// Check runs the given test case and compares the answer.
+func (e *Environment) Check(tc test.Case) error {
+	resp, err := e.Exchange(tc.Msg(), &test.ResponseWriter{})
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", tc.Qname, dns.TypeToString[tc.Qtype], err)
+	}
+	if err := test.SortAndCheck(resp, tc); err != nil {
+		return fmt.Errorf("%s %s: %w", tc.Qname, dns.TypeToString[tc.Qtype], err)
+	}
+	return nil
+}
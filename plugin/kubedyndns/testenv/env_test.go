@@ -0,0 +1,80 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package testenv
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/filesource"
+)
+
+func entry(address string) *api.CoreDNSEntry {
+	return &api.CoreDNSEntry{
+		ObjectMeta: metav1.ObjectMeta{Namespace: filesource.DefaultNamespace, Name: "www"},
+		Spec:       api.CoreDNSSpec{DNSNames: []string{"www.example.org"}, A: []string{address}},
+	}
+}
+
+func TestWaitForSync(t *testing.T) {
+	env, err := New(`kubedyndns example.org`, entry("192.0.2.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer env.Stop()
+
+	check := func(rcode int, answer, ns []dns.RR) {
+		t.Helper()
+		if err := env.Check(test.Case{Qname: "www.example.org.", Qtype: dns.TypeA, Rcode: rcode, Answer: answer, Ns: ns}); err != nil {
+			t.Error(err)
+		}
+	}
+	a := func(address string) []dns.RR {
+		return []dns.RR{test.A("www.example.org. 10 IN A " + address)}
+	}
+	check(dns.RcodeSuccess, a("192.0.2.1"), nil)
+
+	// changes are visible immediately after the next synchronization
+	for _, address := range []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"} {
+		if err := env.Apply(entry(address)); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.WaitForSync(DefaultTimeout); err != nil {
+			t.Fatal(err)
+		}
+		check(dns.RcodeSuccess, a(address), nil)
+	}
+
+	if err := env.Delete(entry("")); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.WaitForSync(DefaultTimeout); err != nil {
+		t.Fatal(err)
+	}
+	check(dns.RcodeNameError, nil, []dns.RR{
+		test.SOA("example.org. 10 IN SOA ns.dns.example.org. hostmaster.example.org. 0 7200 1800 86400 10"),
+	})
+}
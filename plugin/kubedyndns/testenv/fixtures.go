@@ -0,0 +1,98 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package testenv
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/filesource"
+)
+
+// Load reads the objects from the given manifest files or directories
+// and adds them to the fake clientset.
+func (e *Environment) Load(paths ...string) error {
+	objs, err := filesource.ReadObjects(paths...)
+	if err != nil {
+		return err
+	}
+	return e.Apply(objs...)
+}
+
+// Apply adds or updates the given objects in the fake clientsets.
+// Namespaces are kept in the fake kubernetes clientset.
+// After the environment is started, WaitForSync can be used to
+// wait for the changes to be processed.
+func (e *Environment) Apply(objs ...runtime.Object) error {
+	for _, o := range objs {
+		var err error
+		switch obj := o.(type) {
+		case *api.CoreDNSEntry:
+			c := e.Client.CorednsV1alpha1().CoreDNSEntries(obj.Namespace)
+			_, err = c.Create(e.ctx, obj, meta.CreateOptions{})
+			if err != nil && apierrors.IsAlreadyExists(err) {
+				_, err = c.Update(e.ctx, obj, meta.UpdateOptions{})
+			}
+		case *api.HostedZone:
+			c := e.Client.CorednsV1alpha1().HostedZones(obj.Namespace)
+			_, err = c.Create(e.ctx, obj, meta.CreateOptions{})
+			if err != nil && apierrors.IsAlreadyExists(err) {
+				_, err = c.Update(e.ctx, obj, meta.UpdateOptions{})
+			}
+		case *corev1.Namespace:
+			c := e.KubeClient.CoreV1().Namespaces()
+			_, err = c.Create(e.ctx, obj, meta.CreateOptions{})
+			if err != nil && apierrors.IsAlreadyExists(err) {
+				_, err = c.Update(e.ctx, obj, meta.UpdateOptions{})
+			}
+		default:
+			err = fmt.Errorf("unexpected object type %T", o)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the given objects from the fake clientsets.
+func (e *Environment) Delete(objs ...runtime.Object) error {
+	for _, o := range objs {
+		var err error
+		switch obj := o.(type) {
+		case *api.CoreDNSEntry:
+			err = e.Client.CorednsV1alpha1().CoreDNSEntries(obj.Namespace).Delete(e.ctx, obj.Name, meta.DeleteOptions{})
+		case *api.HostedZone:
+			err = e.Client.CorednsV1alpha1().HostedZones(obj.Namespace).Delete(e.ctx, obj.Name, meta.DeleteOptions{})
+		case *corev1.Namespace:
+			err = e.KubeClient.CoreV1().Namespaces().Delete(e.ctx, obj.Name, meta.DeleteOptions{})
+		default:
+			err = fmt.Errorf("unexpected object type %T", o)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package testenv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/miekg/dns"
+)
+
+// Golden compares the textual representation of the given answer
+// with the content of a golden file. If update is set, the golden
+// file is (re-)written instead. The message id and the serial numbers
+// of SOA records, which are derived from the modification time, are ignored.
+func Golden(path string, m *dns.Msg, update bool) error {
+	c := m.Copy()
+	c.Id = 0
+	for _, section := range [][]dns.RR{c.Answer, c.Ns, c.Extra} {
+		for _, rr := range section {
+			if soa, ok := rr.(*dns.SOA); ok {
+				soa.Serial = 0
+			}
+		}
+	}
+	data := []byte(c.String())
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0o644)
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("golden file %s not found", path)
+		}
+		return err
+	}
+	if !bytes.Equal(expected, data) {
+		return fmt.Errorf("answer does not match golden file %s:\n--- expected\n%s\n--- found\n%s", path, expected, data)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package testenv
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/testing"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/client/clientset/versioned/fake"
+)
+
+// newClientset creates a fake clientset filled with the given objects,
+// which emulates the resource versions and the status sub resource
+// of the API server. The plugin ignores updates without new resource
+// version and only sends the status for status updates.
+func newClientset(objs ...runtime.Object) (*fake.Clientset, error) {
+	client := fake.NewSimpleClientset()
+	r := &reactor{tracker: client.Tracker()}
+	client.PrependReactor("create", "*", r.create)
+	client.PrependReactor("update", "*", r.update)
+
+	for _, o := range objs {
+		o = o.DeepCopyObject()
+		if err := r.setVersion(o); err != nil {
+			return nil, err
+		}
+		if err := client.Tracker().Add(o); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+type reactor struct {
+	tracker testing.ObjectTracker
+	version atomic.Uint64
+}
+
+func (r *reactor) setVersion(obj runtime.Object) error {
+	acc, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	acc.SetResourceVersion(strconv.FormatUint(r.version.Add(1), 10))
+	return nil
+}
+
+func (r *reactor) create(action testing.Action) (bool, runtime.Object, error) {
+	c := action.(testing.CreateAction)
+	if c.GetSubresource() != "" {
+		return false, nil, nil
+	}
+	obj := c.GetObject().DeepCopyObject()
+	if err := r.setVersion(obj); err != nil {
+		return true, nil, err
+	}
+	if err := r.tracker.Create(action.GetResource(), obj, action.GetNamespace()); err != nil {
+		return true, nil, err
+	}
+	return true, obj, nil
+}
+
+// update emulates the API server: the status of an object can only
+// be changed via the status sub resource, which does not change
+// the rest of the object.
+func (r *reactor) update(action testing.Action) (bool, runtime.Object, error) {
+	u := action.(testing.UpdateAction)
+	acc, err := meta.Accessor(u.GetObject())
+	if err != nil {
+		return true, nil, err
+	}
+	old, err := r.tracker.Get(action.GetResource(), action.GetNamespace(), acc.GetName())
+	if err != nil {
+		return true, nil, err
+	}
+	oacc, err := meta.Accessor(old)
+	if err != nil {
+		return true, nil, err
+	}
+	if v := acc.GetResourceVersion(); v != "" && v != oacc.GetResourceVersion() {
+		return true, nil, apierrors.NewConflict(action.GetResource().GroupResource(), acc.GetName(), fmt.Errorf("object has been modified"))
+	}
+
+	statusUpdate := u.GetSubresource() == "status"
+	var obj runtime.Object
+	switch o := u.GetObject().DeepCopyObject().(type) {
+	case *api.CoreDNSEntry:
+		if statusUpdate {
+			n := old.DeepCopyObject().(*api.CoreDNSEntry)
+			n.Status = o.Status
+			obj = n
+		} else {
+			o.Status = old.(*api.CoreDNSEntry).Status
+			obj = o
+		}
+	case *api.HostedZone:
+		if statusUpdate {
+			n := old.DeepCopyObject().(*api.HostedZone)
+			n.Status = o.Status
+			obj = n
+		} else {
+			o.Status = old.(*api.HostedZone).Status
+			obj = o
+		}
+	default:
+		return true, nil, fmt.Errorf("unexpected object type %T", old)
+	}
+	if err := r.setVersion(obj); err != nil {
+		return true, nil, err
+	}
+	if err := r.tracker.Update(action.GetResource(), obj, action.GetNamespace()); err != nil {
+		return true, nil, err
+	}
+	return true, obj, nil
+}
@@ -20,9 +20,11 @@ package utils
 
 import (
 	"context"
+	"sync"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
@@ -36,6 +38,9 @@ type ListWatch interface {
 type FilteringListWatch struct {
 	lw     *cache.ListWatch
 	filter func(obj runtime.Object) bool
+
+	lock  sync.Mutex
+	reset chan struct{}
 }
 
 func NewFilteringListWatch(lw *cache.ListWatch, filter func(obj runtime.Object) bool) ListWatch {
@@ -43,10 +48,30 @@ func NewFilteringListWatch(lw *cache.ListWatch, filter func(obj runtime.Object)
 		return lw
 	}
 	return &FilteringListWatch{
-		lw, filter,
+		lw:     lw,
+		filter: filter,
+		reset:  make(chan struct{}),
+	}
+}
+
+// Resync requests a new listing of the objects by the consumers
+// of a filtering list watch. It must be called if the result of
+// the filter changes for already processed objects.
+func Resync(lw ListWatch) {
+	if f, ok := lw.(*FilteringListWatch); ok {
+		f.Resync()
 	}
 }
 
+// Resync terminates the active watches with an expiration error,
+// which causes reflectors to list the objects again.
+func (f *FilteringListWatch) Resync() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	close(f.reset)
+	f.reset = make(chan struct{})
+}
+
 func (f *FilteringListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
 	return f.ListWithContext(context.Background(), options)
 }
@@ -57,15 +82,19 @@ func (f *FilteringListWatch) ListWithContext(ctx context.Context, options metav1
 		return nil, err
 	}
 
-	filteredItems := []unstructured.Unstructured{}
-	for _, item := range list.(*unstructured.UnstructuredList).Items {
-		obj := item.DeepCopy()
+	items, err := apimeta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	filteredItems := []runtime.Object{}
+	for _, obj := range items {
 		if f.filter(obj) {
-			filteredItems = append(filteredItems, *obj)
+			filteredItems = append(filteredItems, obj)
 		}
 	}
-
-	list.(*unstructured.UnstructuredList).Items = filteredItems
+	if err := apimeta.SetList(list, filteredItems); err != nil {
+		return nil, err
+	}
 	return list, nil
 }
 
@@ -74,13 +103,17 @@ func (f *FilteringListWatch) Watch(options metav1.ListOptions) (watch.Interface,
 }
 
 func (f *FilteringListWatch) WatchWithContext(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+	f.lock.Lock()
+	reset := f.reset
+	f.lock.Unlock()
+
 	w, err := f.lw.WatchWithContext(ctx, options)
 	if err != nil {
 		return nil, err
 	}
 
 	// Wrap the watcher so you can drop events
-	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+	w = watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
 		if event.Type == watch.Bookmark || event.Type == watch.Error {
 			return event, true
 		}
@@ -89,5 +122,35 @@ func (f *FilteringListWatch) WatchWithContext(ctx context.Context, options metav
 			return watch.Event{watch.Deleted, event.Object}, true
 		}
 		return event, true
-	}), nil
+	})
+
+	ch := make(chan watch.Event)
+	proxy := watch.NewProxyWatcher(ch)
+	go func() {
+		defer close(ch)
+		defer w.Stop()
+		for {
+			var event watch.Event
+			select {
+			case e, ok := <-w.ResultChan():
+				if !ok {
+					return
+				}
+				event = e
+			case <-reset:
+				event = watch.Event{Type: watch.Error, Object: &apierrors.NewResourceExpired("filter changed").ErrStatus}
+			case <-proxy.StopChan():
+				return
+			}
+			select {
+			case ch <- event:
+			case <-proxy.StopChan():
+				return
+			}
+			if event.Type == watch.Error {
+				return
+			}
+		}
+	}()
+	return proxy, nil
 }
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package utils
+
+import (
+	"sync"
+)
+
+// SharedRunner runs a function shared by several users. It is started
+// for the first user and stopped when the last user has been stopped.
+// It is never started again.
+type SharedRunner struct {
+	lock    sync.Mutex
+	users   int
+	started bool
+	stop    chan struct{}
+}
+
+// Run runs f for a user until the given stop channel is closed.
+// f is called with a stop channel closed when the last user stops.
+func (r *SharedRunner) Run(stop <-chan struct{}, f func(stop <-chan struct{})) {
+	r.lock.Lock()
+	if r.started && r.users == 0 {
+		// all users have already been stopped
+		r.lock.Unlock()
+		return
+	}
+	r.users++
+	if !r.started {
+		r.started = true
+		r.stop = make(chan struct{})
+		go f(r.stop)
+	}
+	r.lock.Unlock()
+
+	<-stop
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.users--
+	if r.users == 0 {
+		close(r.stop)
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ValidationError reports all consistency problems
+// found for the configuration of a plugin instance.
+type ValidationError struct {
+	Location string
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	if e.Location != "" {
+		fmt.Fprintf(&b, "%s: ", e.Location)
+	}
+	fmt.Fprintf(&b, "invalid %s configuration:", pluginName)
+	for _, p := range e.Problems {
+		fmt.Fprintf(&b, "\n  - %s", p)
+	}
+	return b.String()
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// Validate checks the consistency of the configuration of a plugin instance.
+// In contrast to the parsing of the stanza, it reports all problems at once.
+func (k *KubeDynDNS) Validate() error {
+	problems := k.validate()
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+func (k *KubeDynDNS) validate() []error {
+	var problems []error
+
+	add := func(msg string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(msg, args...))
+	}
+
+	if k.Mode == MODE_PRIMARY {
+		if k.zoneObject == "" {
+			add("zoneobject required for mode %q", k.Mode)
+		}
+		if len(k.namespaces) != 1 {
+			add("one namespace required for zoneobject for mode %q, found %d", k.Mode, len(k.namespaces))
+		}
+		if k.soa != defaultSOA || len(k.nameServers) > 0 {
+			add("soa and nameservers not possible for mode %q, use the zone object", k.Mode)
+		}
+	} else {
+		if k.zoneObject != "" {
+			add("zoneobject requires mode %q", MODE_PRIMARY)
+		}
+	}
+
+	if k.Mode != MODE_FILTER && len(k.ServedZones) != 1 {
+		add("mode %s requires one served zone as base domain, found %d", k.Mode, len(k.ServedZones))
+	}
+
+	return append(problems, k.k8s.validate()...)
+}
+
+// validate checks the consistency of the cluster access configuration.
+func (k *K8SConfig) validate() []error {
+	if k == nil {
+		return nil
+	}
+	var problems []error
+	if k.ClientConfig != nil && k.APIToken != "" {
+		problems = append(problems, fmt.Errorf("only API token or kubeconfig possible"))
+	}
+	if k.ClientConfig != nil && len(k.APIServerList) > 0 {
+		problems = append(problems, fmt.Errorf("only endpoint or kubeconfig possible"))
+	}
+	if k.APIToken != "" && len(k.APIServerList) == 0 {
+		problems = append(problems, fmt.Errorf("API token requires endpoint"))
+	}
+	connection := k.ClientConfig != nil || k.APIToken != "" || len(k.APIServerList) > 0 || k.APIClientCert != ""
+	if k.Directory != "" && (connection || len(k.Clusters) > 0) {
+		problems = append(problems, fmt.Errorf("directory cannot be combined with a kubernetes connection"))
+	}
+	if len(k.Clusters) > 0 && connection {
+		problems = append(problems, fmt.Errorf("clusters cannot be combined with a kubernetes connection"))
+	}
+	if k.namespaceLabelSelector != nil && (k.Directory != "" || len(k.Clusters) > 0) {
+		problems = append(problems, fmt.Errorf("namespacelabels requires a kubernetes connection"))
+	}
+	if k.ConflictPolicy != "" && len(k.Clusters) == 0 {
+		problems = append(problems, fmt.Errorf("conflicts requires clusters"))
+	}
+	names := sets.New[string]()
+	for _, c := range k.Clusters {
+		if errs := validation.IsDNS1123Label(c.Name); len(errs) > 0 {
+			problems = append(problems, fmt.Errorf("invalid cluster name %q: %s", c.Name, strings.Join(errs, ", ")))
+		}
+		if names.Has(c.Name) {
+			problems = append(problems, fmt.Errorf("duplicate cluster name %q", c.Name))
+		}
+		names.Insert(c.Name)
+	}
+	return problems
+}
+
+// isValidationError checks whether err reports configuration problems,
+// which do not prevent the parsing of further stanzas.
+func isValidationError(err error) bool {
+	var verr *ValidationError
+	return errors.As(err, &verr)
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package validation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/miekg/dns"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns"
+)
+
+// zoneConflicts detects records, which cannot be served as
+// intended for a served zone.
+func zoneConflicts(k *kubedyndns.KubeDynDNS, zi *kubedyndns.ZoneInfo) []string {
+	var result []string
+
+	tree := k.APIConn.NameTree(zi)
+	tree.Walk(func(name string, n *kubedyndns.NameNode) bool {
+		owner := name + zi.DomainName
+		var (
+			valid   []string
+			cnames  []string
+			aliases []string
+			other   []string
+		)
+		for _, e := range n.Entries {
+			if e.Error != nil {
+				continue
+			}
+			key := e.Namespace + "/" + e.Name
+			valid = append(valid, key)
+			if e.CNAME != "" {
+				cnames = append(cnames, key)
+			}
+			if e.Alias != "" {
+				aliases = append(aliases, key)
+			}
+			if len(e.A) > 0 || len(e.AAAA) > 0 || len(e.Text) > 0 || len(e.NS) > 0 || len(e.PTR) > 0 {
+				other = append(other, key)
+			}
+		}
+		if len(valid) == 0 {
+			return true
+		}
+		for _, keys := range [][]string{valid, cnames, aliases, other} {
+			slices.Sort(keys)
+		}
+		if l := tree.Lookup(name); l.Cut != nil && l.CutName != name {
+			if len(other) == len(valid) && isGlue(owner, zi, l.Cut, n) {
+				return true
+			}
+			result = append(result, fmt.Sprintf("%s: occluded by delegation %s (%s)", owner, l.CutFqdn(zi.DomainName), strings.Join(valid, ", ")))
+			return true
+		}
+		if len(cnames) > 1 {
+			result = append(result, fmt.Sprintf("%s: multiple CNAME records (%s)", owner, strings.Join(cnames, ", ")))
+		}
+		if len(cnames) > 0 && len(valid) > 1 || len(cnames) > 0 && len(other) > 0 {
+			result = append(result, fmt.Sprintf("%s: CNAME and other data (%s)", owner, strings.Join(valid, ", ")))
+		}
+		if len(aliases) > 1 {
+			result = append(result, fmt.Sprintf("%s: multiple ALIAS records (%s)", owner, strings.Join(aliases, ", ")))
+		}
+		if name == "" && len(cnames) > 0 {
+			result = append(result, fmt.Sprintf("%s: CNAME at zone apex (%s)", owner, strings.Join(cnames, ", ")))
+		}
+		return true
+	})
+	return result
+}
+
+// isGlue reports whether the address entries of a node
+// below a delegation point are glue records for the
+// name servers of the delegation.
+func isGlue(owner string, zi *kubedyndns.ZoneInfo, cut, n *kubedyndns.NameNode) bool {
+	for _, e := range n.Entries {
+		if e.CNAME != "" || e.Alias != "" || len(e.Text) > 0 || len(e.NS) > 0 || len(e.PTR) > 0 || e.Service != nil {
+			return false
+		}
+	}
+	for _, d := range cut.Delegations() {
+		for _, s := range d.NS {
+			if !dns.IsFqdn(s) {
+				s = dnsutil.Join(s, zi.DomainName)
+			}
+			if strings.EqualFold(s, owner) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+// Package validation runs kubedyndns plugin instances without cluster
+// for a set of CoreDNSEntry and HostedZone objects and reports the
+// effective domain names, the problems and the resulting zones.
+package validation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/filesource"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+// SyncTimeout is the maximum time to wait for the objects to be processed.
+const SyncTimeout = 5 * time.Second
+
+// Check runs the given plugin instance for the given objects and writes
+// the states of the objects, the effective domain names of the entries,
+// the detected conflicts and optionally the served zones to w. The objects
+// are processed by the regular plugin code on an in-memory store, so the
+// results match the behaviour in a cluster. It returns the number of
+// found problems.
+func Check(w io.Writer, no int, k *kubedyndns.KubeDynDNS, objs []runtime.Object, zones bool) (int, error) {
+	src, err := filesource.NewStatic(nil, objs...)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := k.InitCache(ctx, src); err != nil {
+		return 0, err
+	}
+	go k.APIConn.Run()
+	defer k.APIConn.Stop()
+	if err := k.WaitForSync(SyncTimeout); err != nil {
+		return 0, err
+	}
+
+	store := src.Store()
+	problems := 0
+
+	fmt.Fprintf(w, "kubedyndns instance %d (mode %s, zones %s):\n", no, k.Mode, strings.Join(k.Zones, ", "))
+
+	served := map[string]*objects.Entry{}
+	for _, e := range k.APIConn.EntryList() {
+		served[e.Namespace+"/"+e.Name] = e
+	}
+	entries, err := store.ListEntries(ctx, "", metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+	slices.SortFunc(entries.Items, func(a, b api.CoreDNSEntry) int { return compareKey(&a.ObjectMeta, &b.ObjectMeta) })
+	fmt.Fprintf(w, "  entries:\n")
+	for _, e := range entries.Items {
+		msg, ok := state(e.Status.State, e.Status.Message, e.Status.Conditions)
+		if !ok {
+			problems++
+		}
+		fmt.Fprintf(w, "    %s/%s: %s\n", e.Namespace, e.Name, msg)
+		if s := served[e.Namespace+"/"+e.Name]; s != nil {
+			for _, n := range k.EntryDomainNames(s) {
+				fmt.Fprintf(w, "      %s\n", n)
+			}
+		}
+	}
+
+	hosted, err := store.ListZones(ctx, "", metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+	if len(hosted.Items) > 0 {
+		slices.SortFunc(hosted.Items, func(a, b api.HostedZone) int { return compareKey(&a.ObjectMeta, &b.ObjectMeta) })
+		fmt.Fprintf(w, "  hosted zones:\n")
+		for _, z := range hosted.Items {
+			msg, ok := state(z.Status.State, z.Status.Message, z.Status.Conditions)
+			if !ok {
+				problems++
+			}
+			fmt.Fprintf(w, "    %s/%s: %s\n", z.Namespace, z.Name, msg)
+		}
+	}
+
+	servedZones := k.ExportedZones()
+	var conflicts []string
+	for _, zi := range servedZones {
+		conflicts = append(conflicts, zoneConflicts(k, zi)...)
+	}
+	if len(conflicts) > 0 {
+		problems += len(conflicts)
+		fmt.Fprintf(w, "  conflicts:\n")
+		for _, c := range conflicts {
+			fmt.Fprintf(w, "    %s\n", c)
+		}
+	}
+	fmt.Fprintln(w)
+
+	if zones {
+		for _, zi := range servedZones {
+			if err := k.Export(ctx, zi, w); err != nil {
+				return problems, err
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	return problems, nil
+}
+
+func compareKey(a, b *metav1.ObjectMeta) int {
+	return strings.Compare(a.Namespace+"/"+a.Name, b.Namespace+"/"+b.Name)
+}
+
+// state describes the status of an object and reports whether it is ok.
+// Depending on the status format of the zone, the status is
+// described by state and message or by a condition.
+func state(state, msg string, conditions []metav1.Condition) (string, bool) {
+	if c := meta.FindStatusCondition(conditions, api.ServerConditionType); c != nil {
+		return fmt.Sprintf("%s: %s", c.Reason, c.Message), c.Status == metav1.ConditionTrue
+	}
+	switch state {
+	case "":
+		return "not handled", true
+	case "Ok", "Ready":
+		return state, true
+	}
+	return fmt.Sprintf("%s: %s", state, msg), false
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coredns/caddy"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/filesource"
+)
+
+const manifests = `
+kind: CoreDNSEntry
+apiVersion: coredns.mandelsoft.org/v1alpha1
+metadata:
+  name: www
+spec:
+  dnsNames:
+  - www.example.org
+  A:
+  - 192.0.2.1
+---
+kind: CoreDNSEntry
+apiVersion: coredns.mandelsoft.org/v1alpha1
+metadata:
+  name: alias
+spec:
+  dnsNames:
+  - www.example.org
+  CNAME: host.example.org.
+---
+kind: CoreDNSEntry
+apiVersion: coredns.mandelsoft.org/v1alpha1
+metadata:
+  name: other
+spec:
+  dnsNames:
+  - www.other.org
+  A:
+  - 192.0.2.2
+`
+
+func plugin(t *testing.T, stanza string) *kubedyndns.KubeDynDNS {
+	t.Helper()
+	c := caddy.NewTestController("dns", stanza)
+	if !c.Next() {
+		t.Fatal("no stanza found")
+	}
+	k, err := kubedyndns.ParseStanza(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestCheck(t *testing.T) {
+	clog.Discard()
+	objs, err := filesource.DecodeObjects([]byte(manifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	problems, err := Check(&out, 1, plugin(t, `kubedyndns example.org`), objs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems != 1 {
+		t.Errorf("expected 1 problem, found %d:\n%s", problems, out.String())
+	}
+	for _, s := range []string{
+		"kubedyndns instance 1 (mode FilterByZones, zones example.org.):",
+		"    default/www: Ok\n      www.example.org.\n",
+		"    default/alias: Ok\n      www.example.org.\n",
+		"    default/other: Ok\n",
+		"  conflicts:\n    www.example.org.: CNAME and other data (default/alias, default/www)\n",
+		"www.example.org.\t10\tIN\tA\t192.0.2.1\n",
+	} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("output does not contain %q:\n%s", s, out.String())
+		}
+	}
+}
+
+func TestCheckObjectsUnchanged(t *testing.T) {
+	clog.Discard()
+	objs, err := filesource.DecodeObjects([]byte(manifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same objects can be checked for several plugin instances
+	for i, stanza := range []string{`kubedyndns example.org`, `kubedyndns other.org`} {
+		var out strings.Builder
+		problems, err := Check(&out, i+1, plugin(t, stanza), objs, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && problems != 0 {
+			t.Errorf("expected no problems, found %d:\n%s", problems, out.String())
+		}
+		if strings.Contains(out.String(), "$ORIGIN") {
+			t.Errorf("unexpected zone output:\n%s", out.String())
+		}
+	}
+}
@@ -0,0 +1,372 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+// Package zonefile converts RFC 1035 master files into
+// HostedZone and CoreDNSEntry objects.
+package zonefile
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+// minimumTTL is the lowest MinimumTTL accepted for a HostedZone.
+const minimumTTL = 10
+
+// Options controls the generation of the objects.
+type Options struct {
+	// Origin is the origin of the master file. If not set,
+	// the owner of the SOA record is used.
+	Origin string
+	// Namespace is the namespace of the generated objects.
+	Namespace string
+	// ZoneName is the name of the generated HostedZone.
+	// If not set, it is derived from the origin.
+	ZoneName string
+	// DomainName is the domain name of the HostedZone, by default the
+	// origin. For nested zones it must be relative to the parent zone.
+	DomainName string
+	// ParentRef is the parent zone of the generated HostedZone.
+	ParentRef string
+	// Filter generates entries with absolute domain names
+	// for the FilterByZones mode without HostedZone.
+	Filter bool
+}
+
+// Result contains the objects generated for a master file.
+type Result struct {
+	// Zone is the HostedZone for the SOA record, it is nil in Filter mode.
+	Zone    *api.HostedZone
+	Entries []*api.CoreDNSEntry
+	// Warnings describe the records, which could not be converted.
+	Warnings []string
+}
+
+// group collects the records of an entry to generate.
+type group struct {
+	owner   string
+	service string
+	spec    api.CoreDNSSpec
+}
+
+// Import parses a master file and generates a HostedZone for the SOA record and
+// a CoreDNSEntry for every owner name. SRV records are grouped by service
+// in separate entries. The file name is used for error messages, only.
+// The character strings of a TXT record are joined, because entries
+// keep a single text per record, which is split into strings of 255
+// bytes when served. Other string boundaries are lost and reported
+// as warning.
+func Import(r io.Reader, file string, opts Options) (*Result, error) {
+	var (
+		soa    *dns.SOA
+		rrs    []dns.RR
+		result = &Result{}
+	)
+
+	origin := "."
+	if opts.Origin != "" {
+		origin = dns.Fqdn(opts.Origin)
+	}
+	zp := dns.NewZoneParser(r, origin, file)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if s, ok := rr.(*dns.SOA); ok && soa == nil && (opts.Origin == "" || strings.EqualFold(s.Hdr.Name, origin)) {
+			soa = s
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+
+	if opts.Origin == "" {
+		if soa == nil {
+			return nil, fmt.Errorf("no SOA record found, origin required")
+		}
+		origin = soa.Hdr.Name
+	}
+	origin = strings.ToLower(origin)
+
+	if !opts.Filter {
+		if soa == nil {
+			return nil, fmt.Errorf("no SOA record found for %s", origin)
+		}
+		result.Zone = hostedZone(soa, origin, opts)
+	}
+
+	var groups []*group
+	find := func(owner, service string) *group {
+		for _, g := range groups {
+			if g.owner == owner && g.service == service {
+				return g
+			}
+		}
+		g := &group{owner: owner, service: service}
+		groups = append(groups, g)
+		return g
+	}
+
+	warn := func(rr dns.RR, msg string, args ...interface{}) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %s", strings.ReplaceAll(rr.String(), "\t", " "), fmt.Sprintf(msg, args...)))
+	}
+
+	for _, rr := range rrs {
+		owner := strings.ToLower(rr.Header().Name)
+		if !dns.IsSubDomain(origin, owner) {
+			warn(rr, "not in zone %s", origin)
+			continue
+		}
+		apex := owner == origin
+
+		switch rec := rr.(type) {
+		case *dns.A:
+			g := find(owner, "")
+			g.spec.A = append(g.spec.A, rec.A.String())
+		case *dns.AAAA:
+			g := find(owner, "")
+			g.spec.AAAA = append(g.spec.AAAA, rec.AAAA.String())
+		case *dns.TXT:
+			g := find(owner, "")
+			g.spec.TXT = append(g.spec.TXT, strings.Join(rec.Txt, ""))
+			if !splitText(rec.Txt) {
+				warn(rr, "character strings joined, their boundaries are lost")
+			}
+		case *dns.CNAME:
+			if apex {
+				warn(rr, "CNAME not possible at zone apex")
+				continue
+			}
+			g := find(owner, "")
+			if g.spec.CNAME != "" {
+				warn(rr, "multiple CNAME records")
+				continue
+			}
+			g.spec.CNAME = rec.Target
+		case *dns.NS:
+			if apex {
+				if !opts.Filter {
+					warn(rr, "apex name servers are taken from the status of the HostedZone")
+				} else {
+					warn(rr, "apex name servers are configured for the plugin")
+				}
+				continue
+			}
+			g := find(owner, "")
+			g.spec.NS = append(g.spec.NS, rec.Ns)
+		case *dns.PTR:
+			g := find(owner, "")
+			g.spec.PTR = append(g.spec.PTR, rec.Ptr)
+		case *dns.SRV:
+			labels := dns.SplitDomainName(owner)
+			if len(labels) < 2 || !strings.HasPrefix(labels[0], "_") || (labels[1] != "_tcp" && labels[1] != "_udp") {
+				warn(rr, "SRV records require an owner of the form _service._tcp|_udp.name")
+				continue
+			}
+			name := dns.Fqdn(strings.Join(labels[2:], "."))
+			if len(labels) == 2 {
+				name = origin
+			}
+			if !dns.IsSubDomain(origin, name) {
+				warn(rr, "not in zone %s", origin)
+				continue
+			}
+			service := labels[0][1:]
+			g := find(name, service)
+			if g.spec.SRV == nil {
+				g.spec.SRV = &api.ServiceSpec{Service: service}
+			}
+			g.spec.SRV.Records = append(g.spec.SRV.Records, api.SRVRecord{
+				Protocol: strings.ToUpper(labels[1][1:]),
+				Priority: int(rec.Priority),
+				Weight:   int(rec.Weight),
+				Port:     int(rec.Port),
+				Host:     rec.Target,
+			})
+		case *dns.SOA:
+			warn(rr, "nested zones must be imported separately")
+		default:
+			warn(rr, "record type %s not supported", dns.TypeToString[rr.Header().Rrtype])
+		}
+	}
+
+	names := map[string]bool{}
+	for _, g := range groups {
+		e := &api.CoreDNSEntry{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: api.SchemeGroupVersion.String(),
+				Kind:       "CoreDNSEntry",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      uniqueName(names, objectName(opts, origin, g)),
+				Namespace: opts.Namespace,
+			},
+			Spec: g.spec,
+		}
+		if result.Zone != nil {
+			e.Spec.ZoneRef = result.Zone.Name
+			e.Spec.DNSNames = []string{relativeName(g.owner, origin)}
+			e.Spec.CNAME = relativeTarget(e.Spec.CNAME, origin)
+			e.Spec.NS = relativeTargets(e.Spec.NS, origin)
+			if e.Spec.SRV != nil {
+				for i := range e.Spec.SRV.Records {
+					e.Spec.SRV.Records[i].Host = relativeTarget(e.Spec.SRV.Records[i].Host, origin)
+				}
+			}
+		} else {
+			e.Spec.DNSNames = []string{strings.TrimSuffix(g.owner, ".")}
+		}
+		result.Entries = append(result.Entries, e)
+	}
+	return result, nil
+}
+
+// splitText reports whether the character strings of a TXT record
+// are restored by splitting the joined text into strings of 255 bytes.
+func splitText(txt []string) bool {
+	for i := 0; i < len(txt)-1; i++ {
+		if len(txt[i]) != 255 {
+			return false
+		}
+	}
+	return true
+}
+
+// hostedZone generates the HostedZone for the SOA record.
+// The TTL of the SOA record is used as minimum TTL and the
+// MINIMUM field as negative TTL.
+func hostedZone(soa *dns.SOA, origin string, opts Options) *api.HostedZone {
+	name := opts.ZoneName
+	if name == "" {
+		name = sanitize(strings.TrimSuffix(origin, "."))
+	}
+	domain := opts.DomainName
+	if domain == "" {
+		domain = strings.TrimSuffix(origin, ".")
+	}
+
+	z := &api.HostedZone{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: api.SchemeGroupVersion.String(),
+			Kind:       "HostedZone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: opts.Namespace,
+		},
+		Spec: api.HostedZoneSpec{
+			DomainNames: []string{domain},
+			EMail:       email(soa.Mbox),
+			Refresh:     int(soa.Refresh),
+			Retry:       int(soa.Retry),
+			Expire:      int(soa.Expire),
+			MinimumTTL:  max(int(soa.Hdr.Ttl), minimumTTL),
+			ParentRef:   opts.ParentRef,
+		},
+	}
+	if int(soa.Minttl) != z.Spec.MinimumTTL {
+		negative := int(soa.Minttl)
+		z.Spec.NegativeTTL = &negative
+	}
+	return z
+}
+
+// email converts the mailbox of a SOA record into a mail address.
+// The first unescaped dot separates the local part.
+func email(mbox string) string {
+	mbox = strings.TrimSuffix(mbox, ".")
+	for i := 0; i < len(mbox); i++ {
+		switch mbox[i] {
+		case '\\':
+			i++
+		case '.':
+			return strings.ReplaceAll(mbox[:i], "\\.", ".") + "@" + mbox[i+1:]
+		}
+	}
+	return mbox
+}
+
+// relativeName returns a domain name relative to the origin
+// using the entry notation for the zone apex.
+func relativeName(name, origin string) string {
+	if name == origin {
+		return objects.ApexName
+	}
+	return strings.TrimSuffix(name, "."+origin)
+}
+
+// relativeTarget returns a target domain name relative to the origin,
+// if it is located in the zone. Other names are kept absolute.
+func relativeTarget(name, origin string) string {
+	if name == "" || strings.EqualFold(name, origin) || !dns.IsSubDomain(origin, strings.ToLower(name)) {
+		return name
+	}
+	return name[:len(name)-len(origin)-1]
+}
+
+func relativeTargets(names []string, origin string) []string {
+	var result []string
+	for _, n := range names {
+		result = append(result, relativeTarget(n, origin))
+	}
+	return result
+}
+
+// objectName derives the name of the entry object for a group.
+func objectName(opts Options, origin string, g *group) string {
+	prefix := opts.ZoneName
+	if prefix == "" {
+		prefix = sanitize(strings.TrimSuffix(origin, "."))
+	}
+	name := "apex"
+	if g.owner != origin {
+		name = sanitize(strings.TrimSuffix(g.owner, "."+origin))
+	}
+	if g.service != "" {
+		name += "-srv-" + sanitize(g.service)
+	}
+	return prefix + "-" + name
+}
+
+var invalid = regexp.MustCompile("[^a-z0-9-]+")
+
+// sanitize converts a domain name into a valid object name.
+func sanitize(name string) string {
+	name = strings.ToLower(name)
+	name = strings.ReplaceAll(name, "*", "wildcard")
+	name = strings.ReplaceAll(name, ".", "-")
+	name = invalid.ReplaceAllString(name, "")
+	return strings.Trim(name, "-")
+}
+
+// uniqueName returns a name not used before.
+func uniqueName(used map[string]bool, name string) string {
+	n := name
+	for i := 2; used[n]; i++ {
+		n = fmt.Sprintf("%s-%d", name, i)
+	}
+	used[n] = true
+	return n
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package zonefile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	api "github.com/mandelsoft/kubedyndns/apis/coredns/v1alpha1"
+)
+
+const zone = `$ORIGIN example.org.
+$TTL 300
+@	3600 IN SOA ns1.example.org. host\.master.example.org. 2024010101 7200 1800 86400 600
+@	IN NS	ns1.example.org.
+@	IN NS	ns2.other.net.
+@	IN A	192.0.2.1
+ns1	IN A	192.0.2.53
+www	IN A	192.0.2.1
+www	IN AAAA	2001:db8::1
+www	IN TXT	"hello"
+alias	IN CNAME www
+ext	IN CNAME www.other.net.
+sub	IN NS	ns.sub
+sub	IN NS	ns.other.net.
+_sip._tcp	IN SRV	10 60 5060 sip
+_sip._udp	IN SRV	20 40 5060 sip.other.net.
+_ldap._tcp.dir	IN SRV	0 0 389 ldap.dir
+www	IN MX	10 mail
+other.net.	IN A	192.0.2.9
+`
+
+// specs returns the specs of the entries by object name.
+func specs(r *Result) map[string]api.CoreDNSSpec {
+	result := map[string]api.CoreDNSSpec{}
+	for _, e := range r.Entries {
+		result[e.Name] = e.Spec
+	}
+	return result
+}
+
+func importZone(t *testing.T, data string, opts Options) *Result {
+	t.Helper()
+	r, err := Import(strings.NewReader(data), "test.zone", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestImportZone(t *testing.T) {
+	r := importZone(t, zone, Options{Namespace: "dns"})
+
+	negative := 600
+	expected := api.HostedZoneSpec{
+		DomainNames: []string{"example.org"},
+		EMail:       "host.master@example.org",
+		Refresh:     7200,
+		Retry:       1800,
+		Expire:      86400,
+		MinimumTTL:  3600,
+		NegativeTTL: &negative,
+	}
+	if r.Zone == nil || r.Zone.Name != "example-org" || r.Zone.Namespace != "dns" {
+		t.Fatalf("unexpected zone %v", r.Zone)
+	}
+	if !reflect.DeepEqual(r.Zone.Spec, expected) {
+		t.Errorf("unexpected zone spec %+v", r.Zone.Spec)
+	}
+
+	entries := map[string]api.CoreDNSSpec{
+		"example-org-apex": {ZoneRef: "example-org", DNSNames: []string{"@"}, A: []string{"192.0.2.1"}},
+		"example-org-ns1":  {ZoneRef: "example-org", DNSNames: []string{"ns1"}, A: []string{"192.0.2.53"}},
+		"example-org-www": {ZoneRef: "example-org", DNSNames: []string{"www"},
+			A: []string{"192.0.2.1"}, AAAA: []string{"2001:db8::1"}, TXT: []string{"hello"}},
+		// targets in the zone are relative
+		"example-org-alias": {ZoneRef: "example-org", DNSNames: []string{"alias"}, CNAME: "www"},
+		"example-org-ext":   {ZoneRef: "example-org", DNSNames: []string{"ext"}, CNAME: "www.other.net."},
+		"example-org-sub":   {ZoneRef: "example-org", DNSNames: []string{"sub"}, NS: []string{"ns.sub", "ns.other.net."}},
+		// SRV records are grouped by service
+		"example-org-apex-srv-sip": {ZoneRef: "example-org", DNSNames: []string{"@"}, SRV: &api.ServiceSpec{
+			Service: "sip",
+			Records: []api.SRVRecord{
+				{Protocol: "TCP", Priority: 10, Weight: 60, Port: 5060, Host: "sip"},
+				{Protocol: "UDP", Priority: 20, Weight: 40, Port: 5060, Host: "sip.other.net."},
+			},
+		}},
+		"example-org-dir-srv-ldap": {ZoneRef: "example-org", DNSNames: []string{"dir"}, SRV: &api.ServiceSpec{
+			Service: "ldap",
+			Records: []api.SRVRecord{{Protocol: "TCP", Port: 389, Host: "ldap.dir"}},
+		}},
+	}
+	if got := specs(r); !reflect.DeepEqual(got, entries) {
+		t.Errorf("unexpected entries\n%+v\nexpected\n%+v", got, entries)
+	}
+	for _, e := range r.Entries {
+		if e.Namespace != "dns" || e.Kind != "CoreDNSEntry" {
+			t.Errorf("unexpected object meta for %s: %s/%s", e.Name, e.Kind, e.Namespace)
+		}
+	}
+
+	warnings := []string{
+		"example.org. 300 IN NS ns1.example.org.: apex name servers are taken from the status of the HostedZone",
+		"example.org. 300 IN NS ns2.other.net.: apex name servers are taken from the status of the HostedZone",
+		"www.example.org. 300 IN MX 10 mail.example.org.: record type MX not supported",
+		"other.net. 300 IN A 192.0.2.9: not in zone example.org.",
+	}
+	if !reflect.DeepEqual(r.Warnings, warnings) {
+		t.Errorf("unexpected warnings\n%s", strings.Join(r.Warnings, "\n"))
+	}
+}
+
+func TestImportFilter(t *testing.T) {
+	r := importZone(t, zone, Options{Filter: true})
+
+	if r.Zone != nil {
+		t.Errorf("unexpected zone %v", r.Zone)
+	}
+	s := specs(r)
+	if got := s["example-org-apex"]; !reflect.DeepEqual(got.DNSNames, []string{"example.org"}) {
+		t.Errorf("unexpected apex names %v", got.DNSNames)
+	}
+	// targets are kept absolute
+	if got := s["example-org-alias"]; got.CNAME != "www.example.org." || got.ZoneRef != "" {
+		t.Errorf("unexpected alias %+v", got)
+	}
+	if got := s["example-org-sub"]; !reflect.DeepEqual(got.NS, []string{"ns.sub.example.org.", "ns.other.net."}) {
+		t.Errorf("unexpected delegation %v", got.NS)
+	}
+	if len(r.Warnings) == 0 || !strings.HasSuffix(r.Warnings[0], "apex name servers are configured for the plugin") {
+		t.Errorf("unexpected warnings\n%s", strings.Join(r.Warnings, "\n"))
+	}
+}
+
+func TestImportNested(t *testing.T) {
+	r := importZone(t, zone, Options{ZoneName: "org", DomainName: "example", ParentRef: "root"})
+
+	if r.Zone.Name != "org" || r.Zone.Spec.ParentRef != "root" || !reflect.DeepEqual(r.Zone.Spec.DomainNames, []string{"example"}) {
+		t.Errorf("unexpected zone %+v", r.Zone)
+	}
+	if _, ok := specs(r)["org-www"]; !ok {
+		t.Errorf("entry names must use the zone name")
+	}
+}
+
+func TestImportTXT(t *testing.T) {
+	long := strings.Repeat("a", 255)
+	data := `$ORIGIN example.org.
+$TTL 300
+@	IN SOA ns1 hostmaster 1 7200 1800 86400 600
+split	IN TXT	"` + long + `" "rest"
+joined	IN TXT	"v=spf1 " "-all"
+multi	IN TXT	"first"
+multi	IN TXT	"second"
+`
+	r := importZone(t, data, Options{})
+
+	s := specs(r)
+	if got := s["example-org-split"].TXT; !reflect.DeepEqual(got, []string{long + "rest"}) {
+		t.Errorf("unexpected split text %v", got)
+	}
+	if got := s["example-org-joined"].TXT; !reflect.DeepEqual(got, []string{"v=spf1 -all"}) {
+		t.Errorf("unexpected joined text %v", got)
+	}
+	if got := s["example-org-multi"].TXT; !reflect.DeepEqual(got, []string{"first", "second"}) {
+		t.Errorf("unexpected multiple texts %v", got)
+	}
+	// only the strings not restored when served are reported
+	warnings := []string{`joined.example.org. 300 IN TXT "v=spf1 " "-all": character strings joined, their boundaries are lost`}
+	if !reflect.DeepEqual(r.Warnings, warnings) {
+		t.Errorf("unexpected warnings\n%s", strings.Join(r.Warnings, "\n"))
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	records := "www IN A 192.0.2.1\n"
+	if _, err := Import(strings.NewReader(records), "test.zone", Options{}); err == nil || err.Error() != "no SOA record found, origin required" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := Import(strings.NewReader(records), "test.zone", Options{Origin: "example.org"}); err == nil || err.Error() != "no SOA record found for example.org." {
+		t.Errorf("unexpected error %v", err)
+	}
+	// without zone, the origin is sufficient
+	r, err := Import(strings.NewReader(records), "test.zone", Options{Origin: "example.org", Filter: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Entries) != 1 || !reflect.DeepEqual(r.Entries[0].Spec.DNSNames, []string{"www.example.org"}) {
+		t.Errorf("unexpected entries %v", r.Entries)
+	}
+}
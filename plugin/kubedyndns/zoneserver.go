@@ -0,0 +1,183 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+const defaultExportAddr = "127.0.0.1:8054"
+
+// zoneServer is an HTTP server providing the zones of the
+// registered plugin instances as master files.
+//
+//	GET /zones         lists the exported zones
+//	GET /zones/<zone>  provides the master file of a zone
+//
+// Only zones the client is allowed to transfer are provided
+// (see transferAllowed). Servers are shared by all plugin instances
+// using the same address, so that a reload can take over a running server.
+type zoneServer struct {
+	addr    string
+	lock    sync.RWMutex
+	plugins []*KubeDynDNS
+	ln      net.Listener
+	srv     *http.Server
+}
+
+var (
+	zoneServersLock sync.Mutex
+	zoneServers     = map[string]*zoneServer{}
+)
+
+// registerZoneServer adds a plugin instance to the zone server
+// for the given address and starts the server, if required.
+func registerZoneServer(addr string, k *KubeDynDNS) error {
+	zoneServersLock.Lock()
+	defer zoneServersLock.Unlock()
+
+	s := zoneServers[addr]
+	if s == nil {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		s = &zoneServer{addr: addr, ln: ln}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/zones", s.list)
+		mux.HandleFunc("/zones/", s.zone)
+		s.srv = &http.Server{Handler: mux}
+		zoneServers[addr] = s
+		go s.srv.Serve(ln)
+		Log.Infof("zone export listening on %s", ln.Addr())
+	}
+	s.lock.Lock()
+	s.plugins = append(s.plugins, k)
+	s.lock.Unlock()
+	return nil
+}
+
+// unregisterZoneServer removes a plugin instance from the zone server
+// for the given address and stops the server if it is not used anymore.
+func unregisterZoneServer(addr string, k *KubeDynDNS) error {
+	zoneServersLock.Lock()
+	defer zoneServersLock.Unlock()
+
+	s := zoneServers[addr]
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	for i, p := range s.plugins {
+		if p == k {
+			s.plugins = append(s.plugins[:i], s.plugins[i+1:]...)
+			break
+		}
+	}
+	n := len(s.plugins)
+	s.lock.Unlock()
+	if n > 0 {
+		return nil
+	}
+	delete(zoneServers, addr)
+	Log.Infof("zone export on %s stopped", s.ln.Addr())
+	return s.srv.Close()
+}
+
+// zones returns the exported zones of all registered plugin
+// instances the client is allowed to transfer. A zone served
+// by several instances is provided by the first one.
+func (s *zoneServer) zones(ip net.IP) ([]*KubeDynDNS, []*ZoneInfo) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var (
+		plugins []*KubeDynDNS
+		zones   []*ZoneInfo
+	)
+	found := map[string]bool{}
+	for _, k := range s.plugins {
+		for _, zi := range k.ExportedZones() {
+			name := strings.ToLower(zi.DomainName)
+			if !found[name] {
+				found[name] = true
+				if !k.transferAllowed(zi, ip) {
+					continue
+				}
+				plugins = append(plugins, k)
+				zones = append(zones, zi)
+			}
+		}
+	}
+	return plugins, zones
+}
+
+func (s *zoneServer) list(w http.ResponseWriter, r *http.Request) {
+	if !allowedMethod(w, r) {
+		return
+	}
+	_, zones := s.zones(clientIP(r))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, zi := range zones {
+		if _, err := w.Write([]byte(zi.DomainName + "\n")); err != nil {
+			return
+		}
+	}
+}
+
+func (s *zoneServer) zone(w http.ResponseWriter, r *http.Request) {
+	if !allowedMethod(w, r) {
+		return
+	}
+	name := dns.Fqdn(strings.ToLower(strings.TrimPrefix(r.URL.Path, "/zones/")))
+	plugins, zones := s.zones(clientIP(r))
+	for i, zi := range zones {
+		if strings.EqualFold(zi.DomainName, name) {
+			w.Header().Set("Content-Type", "text/dns")
+			if err := plugins[i].Export(r.Context(), zi, w); err != nil {
+				Log.Errorf("export of zone %s failed: %s", zi.DomainName, err)
+			}
+			return
+		}
+	}
+	http.Error(w, "zone "+name+" not found", http.StatusNotFound)
+}
+
+func allowedMethod(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// clientIP returns the address of the client of an HTTP request.
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
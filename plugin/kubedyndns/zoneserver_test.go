@@ -0,0 +1,87 @@
+/*
+ * Copyright 2025 Mandelsoft. All rights reserved.
+ *  This file is licensed under the Apache Software License, v. 2 except as noted
+ *  otherwise in the LICENSE file
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package kubedyndns
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/mandelsoft/kubedyndns/plugin/kubedyndns/objects"
+)
+
+func TestTransferAllowed(t *testing.T) {
+	acl, err := objects.ParseACL([]string{"10.0.0.0/8"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		plugin  *KubeDynDNS
+		zone    *objects.Zone
+		ip      string
+		allowed bool
+	}{
+		// without transfer ACL only loopback clients are allowed
+		{&KubeDynDNS{}, &objects.Zone{}, "127.0.0.1", true},
+		{&KubeDynDNS{}, &objects.Zone{}, "::1", true},
+		{&KubeDynDNS{}, &objects.Zone{}, "10.0.0.1", false},
+		{&KubeDynDNS{}, &objects.Zone{}, "", false},
+		{&KubeDynDNS{}, &objects.Zone{QueryAccess: objects.DenyAll}, "127.0.0.1", false},
+		// plugin transfer ACL
+		{&KubeDynDNS{transferACL: acl}, &objects.Zone{}, "10.0.0.1", true},
+		{&KubeDynDNS{transferACL: acl}, &objects.Zone{}, "127.0.0.1", false},
+		{&KubeDynDNS{transferACL: acl, queryACL: objects.DenyAll}, &objects.Zone{}, "10.0.0.1", false},
+		// zone transfer ACL
+		{&KubeDynDNS{}, &objects.Zone{TransferAccess: acl}, "10.0.0.1", true},
+		{&KubeDynDNS{}, &objects.Zone{TransferAccess: acl}, "192.168.0.1", false},
+		{&KubeDynDNS{transferACL: acl}, &objects.Zone{TransferAccess: objects.DenyAll}, "10.0.0.1", false},
+	}
+	for i, tc := range tests {
+		zi := NewZoneInfo("example.org.", tc.zone)
+		if got := tc.plugin.transferAllowed(zi, net.ParseIP(tc.ip)); got != tc.allowed {
+			t.Errorf("test %d: expected %t for %q, got %t", i, tc.allowed, tc.ip, got)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		addr string
+		ip   net.IP
+	}{
+		{"10.0.0.1:4711", net.ParseIP("10.0.0.1")},
+		{"[fd00::1]:4711", net.ParseIP("fd00::1")},
+		{"garbage", nil},
+	}
+	for _, tc := range tests {
+		if got := clientIP(&http.Request{RemoteAddr: tc.addr}); !got.Equal(tc.ip) {
+			t.Errorf("expected %s for %q, got %s", tc.ip, tc.addr, got)
+		}
+	}
+}
+
+func TestDefaultExportAddrLoopback(t *testing.T) {
+	host, _, err := net.SplitHostPort(defaultExportAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		t.Errorf("default export address %q is not a loopback address", defaultExportAddr)
+	}
+}